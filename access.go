@@ -0,0 +1,62 @@
+package robot
+
+import (
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// accessVerbs are the verbs informers need on resources.
+var accessVerbs = []string{"list", "watch"}
+
+// checkAccess reviews whether the identity of the member can list and watch
+// each resource, and reports an error listing all missing permissions.
+// It returns the denied resources, none if the reviews fail.
+func (m *member) checkAccess(client kubernetes.Interface, served map[Resource]metav1.APIResource, report func(error)) map[RN]bool {
+	denied := make(map[RN]bool)
+	var missing []string
+	for _, r := range m.Resources {
+		reviewed := m.alternativeIn(served, r.RType)
+		var verbs []string
+		for _, verb := range accessVerbs {
+			review, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(&authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+					ResourceAttributes: &authorizationv1.ResourceAttributes{
+						Namespace: r.Namespace,
+						Verb:      verb,
+						Group:     reviewed.Group,
+						Version:   reviewed.Version,
+						Resource:  reviewed.Resource,
+					},
+				},
+			})
+			if err != nil {
+				// Let reflectors find out.
+				report(fmt.Errorf("robot: review access of cluster %s: %v", m, err))
+				return nil
+			}
+			if !review.Status.Allowed {
+				verbs = append(verbs, verb)
+			}
+		}
+		if len(verbs) == 0 {
+			continue
+		}
+
+		denied[r] = true
+		scope := "all namespaces"
+		if r.Namespace != "" {
+			scope = "namespace " + r.Namespace
+		} else if !m.namespacedIn(served, r.RType) {
+			scope = "the cluster"
+		}
+		missing = append(missing, fmt.Sprintf("%s %s in %s", strings.Join(verbs, ","), reviewed, scope))
+	}
+	if len(missing) > 0 {
+		report(fmt.Errorf("robot: cluster %s lacks permissions, resources skipped: %s", m, strings.Join(missing, "; ")))
+	}
+	return denied
+}
@@ -0,0 +1,76 @@
+package robot
+
+import (
+	"errors"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestBuildSkipsDeniedResources(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		review.Status.Allowed = attrs.Resource == "services" || attrs.Resource == "pods" && attrs.Verb == "list"
+		return true, review, nil
+	})
+	client.Resources = []*metav1.APIResourceList{{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{{Name: "services", Namespaced: true}, {Name: "pods", Namespaced: true}, {Name: "nodes"}},
+	}}
+
+	m := &member{
+		Cluster:         Cluster{Name: "one", Resources: []RN{{RType: Services}, {RType: Pods, Namespace: "default"}, {RType: Nodes}}},
+		preflightAccess: true,
+	}
+	for range m.Resources {
+		m.indexers = append(m.indexers, cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, cache.Indexers{}))
+	}
+
+	var errs []error
+	emitter := func(RN) emitFunc {
+		return func(QueueObject, interface{}) {}
+	}
+	m.build(client, emitter, func(err error) {
+		errs = append(errs, err)
+	})
+
+	if e, a := 1, len(m.informers); e != a {
+		t.Fatalf("expected %v informers, got %v", e, a)
+	}
+	if e, a := Services, m.informers[0].resource; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("expected an error, got %v", errs)
+	}
+	e := "robot: cluster one lacks permissions, resources skipped: watch pods in namespace default; list,watch nodes in the cluster"
+	if a := errs[0].Error(); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestCheckAccessFailed(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &authorizationv1.SelfSubjectAccessReview{}, errors.New("unreachable")
+	})
+
+	m := &member{Cluster: Cluster{Name: "one", Resources: []RN{{RType: Services}}}}
+	var errs []error
+	denied := m.checkAccess(client, nil, func(err error) {
+		errs = append(errs, err)
+	})
+	if e, a := 0, len(denied); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := 1, len(errs); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
@@ -0,0 +1,240 @@
+package robot
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// objectsPath is the path of the objects API of the debug server,
+// followed by a resource, e.g. /objects/v1/pods or /objects/all.
+const objectsPath = "/objects/"
+
+// apiObject is an object listed by the objects API.
+type apiObject struct {
+	Cluster string      `json:"cluster"`
+	Key     string      `json:"key"`
+	Object  interface{} `json:"object"`
+}
+
+// modifiedTimes records the time of the last event of each resource of each
+// cluster, a nil one records nothing.
+type modifiedTimes struct {
+	mu    sync.Mutex
+	times map[modifiedKey]time.Time
+}
+
+type modifiedKey struct {
+	cluster  string
+	resource Resource
+}
+
+func newModifiedTimes() *modifiedTimes {
+	return &modifiedTimes{times: make(map[modifiedKey]time.Time)}
+}
+
+func (t *modifiedTimes) touch(cluster string, r Resource, now time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.times[modifiedKey{cluster, r}] = now
+}
+
+// get returns the time of the last event of the resource in the clusters, the
+// latest one of all resources for All, and of all clusters if clusters is nil.
+// It's zero if there is no event.
+func (t *modifiedTimes) get(r Resource, clusters map[string]bool) (last time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for k, one := range t.times {
+		if r != All && k.resource != r {
+			continue
+		}
+		if clusters != nil && !clusters[k.cluster] {
+			continue
+		}
+		if one.After(last) {
+			last = one
+		}
+	}
+	return
+}
+
+// serveObjects lists cached objects of a resource, of the cluster in the query
+// if given, Secrets are redacted by redactSecret. Responses carry an ETag of the resourceVersions of the objects and
+// the Last-Modified time of the last event of the resource in the clusters, so
+// pollers get 304 Not Modified by If-None-Match, or If-Modified-Since without
+// it, if nothing changed.
+func (c *controller) serveObjects(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r := All
+	if path := strings.TrimPrefix(req.URL.Path, objectsPath); path != "all" {
+		var err error
+		if r, err = ParseResource(path); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	cluster := req.URL.Query().Get("cluster")
+	objects, err := c.objects(r, cluster)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	etag := objectsETag(objects)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	modified := c.modified.get(r, c.clustersOf(cluster)).UTC().Truncate(time.Second)
+	// Last-Modified is of a whole second, so it's only sent once the second
+	// is over, or a later change in it would be taken as not modified.
+	if !modified.IsZero() && time.Now().UTC().Truncate(time.Second).After(modified) {
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+	}
+	if notModified(req, etag, modified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if req.Method == http.MethodHead {
+		return
+	}
+	if err := json.NewEncoder(w).Encode(objects); err != nil {
+		c.report(fmt.Errorf("robot: write objects of %s: %v", r, err))
+	}
+}
+
+// clustersOf returns the names of the clusters of the name or URL, nil for all
+// clusters if it's empty.
+func (c *controller) clustersOf(cluster string) map[string]bool {
+	if cluster == "" {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make(map[string]bool)
+	for _, m := range c.clusters {
+		if m.is(cluster) {
+			names[m.String()] = true
+		}
+	}
+	return names
+}
+
+// objects returns cached objects of the resource in the cluster, or in all
+// clusters if it's empty, sorted by cluster and key.
+func (c *controller) objects(r Resource, cluster string) ([]apiObject, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	objects := []apiObject{}
+	for _, m := range c.clusters {
+		if cluster != "" && !m.is(cluster) {
+			continue
+		}
+		for i, rn := range m.Resources {
+			if r != All && rn.RType != r {
+				continue
+			}
+			for _, obj := range m.indexers[i].List() {
+				key, err := m.key(obj)
+				if err != nil {
+					return nil, err
+				}
+				if rn.RType == Secrets {
+					obj = redactSecret(obj)
+				}
+				objects = append(objects, apiObject{Cluster: m.String(), Key: key, Object: obj})
+			}
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		if objects[i].Cluster != objects[j].Cluster {
+			return objects[i].Cluster < objects[j].Cluster
+		}
+		return objects[i].Key < objects[j].Key
+	})
+	return objects, nil
+}
+
+// objectsETag returns an ETag of the clusters, keys and resourceVersions of objects.
+func objectsETag(objects []apiObject) string {
+	h := fnv.New64a()
+	for _, one := range objects {
+		var rv string
+		if accessor, err := meta.Accessor(one.Object); err == nil {
+			rv = accessor.GetResourceVersion()
+		}
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00", one.Cluster, one.Key, rv)
+	}
+	return fmt.Sprintf(`"%x"`, h.Sum64())
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since without it.
+func notModified(req *http.Request, etag string, modified time.Time) bool {
+	if match := req.Header.Get("If-None-Match"); match != "" {
+		for _, one := range strings.Split(match, ",") {
+			one = strings.TrimPrefix(strings.TrimSpace(one), "W/")
+			if one == etag || one == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if modified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !modified.After(since)
+}
+
+// redactSecret returns a copy of the Secret, whose values of data and
+// stringData are emptied, and keys kept. Other objects are returned as is.
+func redactSecret(obj interface{}) interface{} {
+	switch secret := obj.(type) {
+	case *v1.Secret:
+		secret = secret.DeepCopy()
+		for k := range secret.Data {
+			secret.Data[k] = nil
+		}
+		for k := range secret.StringData {
+			secret.StringData[k] = ""
+		}
+		return secret
+	case *unstructured.Unstructured:
+		secret = secret.DeepCopy()
+		for _, field := range []string{"data", "stringData"} {
+			values, _ := secret.Object[field].(map[string]interface{})
+			for k := range values {
+				values[k] = ""
+			}
+		}
+		return secret
+	}
+	return obj
+}
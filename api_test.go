@@ -0,0 +1,139 @@
+package robot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestServeObjects(t *testing.T) {
+	pods := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	pods.Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "one", ResourceVersion: "1"}})
+	c := &controller{
+		clusters: []*member{{
+			Cluster:  Cluster{Name: "one", Resources: []RN{{RType: Pods}}},
+			indexers: []cache.Indexer{pods},
+		}},
+		modified: newModifiedTimes(),
+	}
+	c.modified.touch("one", Pods, time.Now().Add(-time.Minute))
+	server := httptest.NewServer(http.HandlerFunc(c.serveObjects))
+	defer server.Close()
+
+	get := func(path string, header map[string]string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := get("/objects/v1/pods?cluster=one", nil)
+	var objects []apiObject
+	if err := json.NewDecoder(resp.Body).Decode(&objects); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if e, a := 1, len(objects); e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	if e, a := "default/one", objects[0].Key; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	etag, modified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if etag == "" || modified == "" {
+		t.Fatalf("expected ETag and Last-Modified, got %v", resp.Header)
+	}
+
+	for _, test := range []struct {
+		path   string
+		header map[string]string
+		status int
+	}{
+		{path: "/objects/v1/pods", header: map[string]string{"If-None-Match": etag}, status: http.StatusNotModified},
+		{path: "/objects/v1/pods", header: map[string]string{"If-None-Match": `W/"0", ` + etag}, status: http.StatusNotModified},
+		{path: "/objects/v1/pods", header: map[string]string{"If-None-Match": `"0"`, "If-Modified-Since": modified}, status: http.StatusOK},
+		{path: "/objects/v1/pods", header: map[string]string{"If-Modified-Since": modified}, status: http.StatusNotModified},
+		{path: "/objects/all", header: map[string]string{"If-None-Match": etag}, status: http.StatusNotModified},
+		{path: "/objects/v1/configmaps", header: map[string]string{"If-None-Match": etag}, status: http.StatusOK},
+		{path: "/objects/pods", status: http.StatusBadRequest},
+	} {
+		resp := get(test.path, test.header)
+		resp.Body.Close()
+		if e, a := test.status, resp.StatusCode; e != a {
+			t.Errorf("expected %v of %s %v, got %v", e, test.path, test.header, a)
+		}
+	}
+
+	pods.Update(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "one", ResourceVersion: "2"}})
+	c.modified.touch("one", Pods, time.Now().Add(-time.Second))
+	resp = get("/objects/v1/pods", map[string]string{"If-None-Match": etag})
+	resp.Body.Close()
+	if e, a := http.StatusOK, resp.StatusCode; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	resp = get("/objects/v1/pods", map[string]string{"If-Modified-Since": modified})
+	resp.Body.Close()
+	if e, a := http.StatusOK, resp.StatusCode; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	// Changes of other clusters don't modify the objects of the cluster.
+	c.modified.touch("two", Pods, time.Now().Add(time.Hour))
+	resp = get("/objects/v1/pods?cluster=one", nil)
+	resp.Body.Close()
+	modified = resp.Header.Get("Last-Modified")
+	resp = get("/objects/v1/pods?cluster=one", map[string]string{"If-Modified-Since": modified})
+	resp.Body.Close()
+	if e, a := http.StatusNotModified, resp.StatusCode; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	// Last-Modified isn't sent until the second of the last change is over.
+	c.modified.touch("one", Pods, time.Now())
+	resp = get("/objects/v1/pods?cluster=one", nil)
+	resp.Body.Close()
+	if a := resp.Header.Get("Last-Modified"); a != "" {
+		t.Errorf("expected no Last-Modified, got %v", a)
+	}
+}
+
+func TestServeObjectsRedactsSecrets(t *testing.T) {
+	secrets := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "token"},
+		Data:       map[string][]byte{"token": []byte("hunter2")},
+		StringData: map[string]string{"password": "hunter2"},
+	}
+	secrets.Add(secret)
+	c := &controller{
+		clusters: []*member{{
+			Cluster:  Cluster{Name: "one", Resources: []RN{{RType: Secrets}}},
+			indexers: []cache.Indexer{secrets},
+		}},
+	}
+
+	rec := httptest.NewRecorder()
+	c.serveObjects(rec, httptest.NewRequest(http.MethodGet, "/objects/v1/secrets", nil))
+	body := rec.Body.String()
+	if strings.Contains(body, "hunter2") || strings.Contains(body, "aHVudGVyMg") {
+		t.Errorf("expected the data of the Secret redacted, got %s", body)
+	}
+	if !strings.Contains(body, `"token"`) || !strings.Contains(body, `"password"`) {
+		t.Errorf("expected the keys of the Secret kept, got %s", body)
+	}
+	if e, a := "hunter2", string(secret.Data["token"]); e != a {
+		t.Errorf("expected the cached Secret untouched, got %v", a)
+	}
+}
@@ -0,0 +1,176 @@
+package robot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/cache"
+)
+
+const defaultAuditLogMaxSize = 100 << 20
+
+// auditRecord is a line of the audit log.
+type auditRecord struct {
+	Cluster         string    `json:"cluster"`
+	Resource        string    `json:"resource"`
+	Event           string    `json:"event"`
+	Key             string    `json:"key"`
+	Timestamp       time.Time `json:"timestamp"`
+	ResourceVersion string    `json:"resourceVersion,omitempty"`
+
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// auditLog is an append-only JSON lines file of emitted events, with rotation.
+type auditLog struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	mu sync.Mutex
+	// file is nil if it failed to open, it's opened again by the next write.
+	file   *os.File
+	size   int64
+	opened time.Time
+	closed bool
+}
+
+func newAuditLog(opts Options) (*auditLog, error) {
+	l := &auditLog{
+		path:       opts.AuditLog,
+		maxSize:    opts.AuditLogMaxSize,
+		maxAge:     opts.AuditLogMaxAge,
+		maxBackups: opts.AuditLogMaxBackups,
+	}
+	if l.maxSize <= 0 {
+		l.maxSize = defaultAuditLogMaxSize
+	}
+
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *auditLog) open() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("robot: open audit log: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("robot: open audit log: %v", err)
+	}
+
+	l.file = file
+	l.size = info.Size()
+	l.opened = time.Now()
+	return nil
+}
+
+func (l *auditLog) write(item QueueObject, obj interface{}) error {
+	record := auditRecord{
+		Cluster:   item.Cluster,
+		Resource:  item.RType.String(),
+		Event:     item.Event.String(),
+		Key:       item.Key,
+		Timestamp: item.CreateAt,
+		Labels:    item.ClusterLabels(),
+	}
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if m, err := meta.Accessor(obj); err == nil {
+		record.ResourceVersion = m.GetResourceVersion()
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("robot: write audit log: %v", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return fmt.Errorf("robot: write audit log: closed")
+	}
+	if l.file == nil {
+		if err := l.open(); err != nil {
+			return err
+		}
+	}
+	var rotateErr error
+	if l.size+int64(len(line)) > l.maxSize || (l.maxAge > 0 && time.Since(l.opened) > l.maxAge) {
+		// The record is still written if the current file is open after
+		// a failed rotation, which is retried by the next write.
+		if rotateErr = l.rotate(); l.file == nil {
+			return rotateErr
+		}
+	}
+
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("robot: write audit log: %v", err)
+	}
+	return rotateErr
+}
+
+// rotate renames the current file with a timestamp suffix and opens a new one,
+// l.mu must be held. The current file is opened again if the rename fails,
+// and l.file is nil if no file could be opened.
+func (l *auditLog) rotate() error {
+	err := l.file.Close()
+	l.file = nil
+	if err != nil {
+		return fmt.Errorf("robot: rotate audit log: %v", err)
+	}
+
+	backup := l.path + "." + time.Now().Format("20060102T150405.000000000")
+	if err := os.Rename(l.path, backup); err != nil {
+		if err := l.open(); err != nil {
+			return err
+		}
+		return fmt.Errorf("robot: rotate audit log: %v", err)
+	}
+	if err := l.open(); err != nil {
+		return err
+	}
+
+	if l.maxBackups > 0 {
+		backups, err := filepath.Glob(l.path + ".*")
+		if err != nil {
+			return fmt.Errorf("robot: rotate audit log: %v", err)
+		}
+		// the timestamp suffixes sort in time order
+		sort.Strings(backups)
+		for len(backups) > l.maxBackups {
+			if err := os.Remove(backups[0]); err != nil {
+				return fmt.Errorf("robot: rotate audit log: %v", err)
+			}
+			backups = backups[1:]
+		}
+	}
+	return nil
+}
+
+func (l *auditLog) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.closed = true
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+}
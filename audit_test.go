@@ -0,0 +1,105 @@
+package robot
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "robot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	l, err := newAuditLog(Options{AuditLog: path, AuditLogMaxSize: 300, AuditLogMaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.close()
+
+	item := QueueObject{Event: EventAdd, RType: Pods, Key: "default/one", CreateAt: time.Now(), Cluster: "one", Labels: "env=prod,region=eu"}
+	obj := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "one", ResourceVersion: "7"}}
+	for i := 0; i < 10; i++ {
+		if err := l.write(item, obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, _ := filepath.Glob(path + ".*")
+	if e, a := 2, len(backups); e != a {
+		t.Errorf("expected %v backups, got %v", e, a)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+
+	var record auditRecord
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "7", record.ResourceVersion; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := "pods", record.Resource; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := "eu", record.Labels["region"]; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestAuditLogFailedRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "robot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logs := filepath.Join(dir, "logs")
+	if err := os.Mkdir(logs, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(logs, "audit.log")
+	l, err := newAuditLog(Options{AuditLog: path, AuditLogMaxSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.close()
+
+	item := QueueObject{Event: EventAdd, RType: Pods, Key: "default/one", CreateAt: time.Now(), Cluster: "one"}
+	obj := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "one", ResourceVersion: "7"}}
+
+	// Neither rename nor reopen works without the directory.
+	if err := os.RemoveAll(logs); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := l.write(item, obj); err == nil {
+			t.Errorf("expected an error of a failed rotation")
+		}
+	}
+
+	// The file is opened again once it can be.
+	if err := os.Mkdir(logs, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.write(item, obj); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, _ := ioutil.ReadFile(path); !strings.Contains(string(data), `"default/one"`) {
+		t.Errorf("expected the record written after recovery, got %q", data)
+	}
+}
@@ -0,0 +1,148 @@
+package robot
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	defaultBreakerWindow   = time.Minute
+	defaultBreakerCoolDown = time.Minute
+)
+
+// CircuitBreaker opens when list and watch requests to a cluster fail
+// Failures times in Window, which pauses watching the cluster for CoolDown.
+// The cluster is probed before watching is resumed.
+type CircuitBreaker struct {
+	// Failures opening the breaker, disabled if zero.
+	Failures int
+
+	// Window counting failures, a minute if zero.
+	Window time.Duration
+
+	// CoolDown before probing the cluster, a minute if zero.
+	CoolDown time.Duration
+}
+
+// breaker is the circuit breaker of a cluster, a nil breaker never opens.
+type breaker struct {
+	CircuitBreaker
+
+	// trip is called once the breaker opens.
+	trip func()
+
+	mu       sync.Mutex
+	start    time.Time
+	failures int
+	open     bool
+}
+
+func newBreaker(cb CircuitBreaker, trip func()) *breaker {
+	if cb.Failures <= 0 {
+		return nil
+	}
+	if cb.Window <= 0 {
+		cb.Window = defaultBreakerWindow
+	}
+	if cb.CoolDown <= 0 {
+		cb.CoolDown = defaultBreakerCoolDown
+	}
+	return &breaker{CircuitBreaker: cb, trip: trip, start: time.Now()}
+}
+
+func (b *breaker) record(err error) {
+	if b == nil || err == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(b.start) > b.Window {
+		b.start = now
+		b.failures = 0
+	}
+	b.failures++
+
+	if !b.open && b.failures >= b.Failures {
+		b.open = true
+		go b.trip()
+	}
+}
+
+func (b *breaker) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.open = false
+	b.start = time.Now()
+	b.failures = 0
+}
+
+// tripper returns the function pausing the member when its breaker opens.
+func (c *controller) tripper(m *member) func() {
+	return func() {
+		c.degrade(m)
+	}
+}
+
+// degrade pauses the member, and resumes it once a probe succeeds after cool-down.
+func (c *controller) degrade(m *member) {
+	coolDown := m.breaker.CoolDown
+
+	c.mu.Lock()
+	m.degraded = true
+	m.halt()
+	c.mu.Unlock()
+
+	c.report(fmt.Errorf("robot: cluster %s is degraded, watching is paused for %v", m, coolDown))
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-time.After(coolDown):
+		}
+
+		c.mu.Lock()
+		client := m.client
+		c.mu.Unlock()
+
+		_, err := client.Discovery().ServerVersion()
+		if err == nil {
+			break
+		}
+		c.report(&ErrClusterUnreachable{Cluster: m.String(), Err: err})
+	}
+
+	// Discovery may be slow, so it's done without c.mu held.
+	c.mu.Lock()
+	client := m.client
+	c.mu.Unlock()
+	var d *discovered
+	if c.opts.Sharding == nil {
+		d = m.discover(client, c.report)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.stop:
+		return
+	default:
+	}
+
+	m.degraded = false
+	m.breaker.reset()
+	// A sharded cluster is resumed by rebalancing if it's still owned.
+	if c.opts.Sharding == nil {
+		m.install(client, d, c.emitter(m), c.report)
+		m.run(c.report)
+	}
+	klog.Infof("robot: cluster %s recovered, watching is resumed", m)
+}
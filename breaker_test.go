@@ -0,0 +1,76 @@
+package robot
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestBreaker(t *testing.T) {
+	trips := make(chan struct{}, 10)
+	b := newBreaker(CircuitBreaker{Failures: 3}, func() {
+		trips <- struct{}{}
+	})
+
+	for i := 0; i < 5; i++ {
+		b.record(errors.New("unavailable"))
+		b.record(nil)
+	}
+
+	select {
+	case <-trips:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the breaker to open")
+	}
+	time.Sleep(10 * time.Millisecond)
+	if e, a := 0, len(trips); e != a {
+		t.Errorf("expected to trip once, tripped %v more times", a)
+	}
+
+	if newBreaker(CircuitBreaker{}, nil) != nil {
+		t.Errorf("expected no breaker without Failures")
+	}
+}
+
+func TestDegrade(t *testing.T) {
+	c := &controller{
+		queue: newWorkQueue(),
+		stop:  make(chan struct{}),
+		errs:  make(chan error, 10),
+	}
+	defer close(c.stop)
+
+	m := &member{
+		Cluster:  Cluster{MasterUrl: "https://one.example.com"},
+		client:   fake.NewSimpleClientset(),
+		observed: newEventCounter(),
+	}
+	m.breaker = newBreaker(CircuitBreaker{Failures: 1, CoolDown: 50 * time.Millisecond}, nil)
+	m.informers = informerSet{newInformer(Pods, fakeInformer{})}
+	m.stop = make(chan struct{})
+	m.run(c.report)
+	c.clusters = []*member{m}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.degrade(m)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	if s := c.Status()[0]; !s.Degraded {
+		t.Errorf("expected the cluster to be degraded")
+	}
+
+	<-done
+	if s := c.Status()[0]; s.Degraded {
+		t.Errorf("expected the cluster to recover")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !m.running {
+		t.Errorf("expected the cluster to be resumed")
+	}
+}
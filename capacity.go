@@ -0,0 +1,116 @@
+package robot
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Capacity is the capacity of the nodes of a cluster, aggregated from the
+// cached Nodes and Pods.
+type Capacity struct {
+	Nodes int
+
+	// Unschedulable is the number of nodes cordoned.
+	Unschedulable int
+
+	// Allocatable is the sum of the allocatable resources of the nodes.
+	Allocatable v1.ResourceList
+
+	// Requested is the sum of the requests of pods on the nodes which are
+	// not terminated, nil if Pods are not watched.
+	Requested v1.ResourceList
+
+	// Conditions are the numbers of nodes of each condition which is true,
+	// e.g. Ready or MemoryPressure.
+	Conditions map[v1.NodeConditionType]int
+}
+
+// nodeOf returns obj as a node, false if it isn't one.
+func nodeOf(obj interface{}) (*v1.Node, bool) {
+	switch o := obj.(type) {
+	case *v1.Node:
+		return o, true
+	case *unstructured.Unstructured:
+		node := &v1.Node{}
+		return node, fromUnstructured(o, "Node", node)
+	}
+	return nil, false
+}
+
+// capacity aggregates the capacity of the member, nil if Nodes are not watched.
+func (m *member) capacity() *Capacity {
+	var capacity *Capacity
+	for i, r := range m.Resources {
+		if r.RType != Nodes {
+			continue
+		}
+		if capacity == nil {
+			capacity = &Capacity{Allocatable: v1.ResourceList{}, Conditions: make(map[v1.NodeConditionType]int)}
+		}
+		for _, obj := range m.indexers[i].List() {
+			node, ok := nodeOf(obj)
+			if !ok {
+				continue
+			}
+			capacity.Nodes++
+			if node.Spec.Unschedulable {
+				capacity.Unschedulable++
+			}
+			addResources(capacity.Allocatable, node.Status.Allocatable)
+			for _, condition := range node.Status.Conditions {
+				if condition.Status == v1.ConditionTrue {
+					capacity.Conditions[condition.Type]++
+				}
+			}
+		}
+	}
+	if capacity == nil {
+		return nil
+	}
+
+	for i, r := range m.Resources {
+		if r.RType != Pods {
+			continue
+		}
+		if capacity.Requested == nil {
+			capacity.Requested = v1.ResourceList{}
+		}
+		for _, obj := range m.indexers[i].List() {
+			pod, ok := podOf(obj)
+			if !ok || pod.Spec.NodeName == "" || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+				continue
+			}
+			addResources(capacity.Requested, podRequests(pod))
+		}
+	}
+	return capacity
+}
+
+// podRequests returns the requests of the pod, which are the sums of requests
+// of its containers, or the max of its init containers if larger.
+func podRequests(pod *v1.Pod) v1.ResourceList {
+	requests := v1.ResourceList{}
+	for _, c := range pod.Spec.Containers {
+		addResources(requests, c.Resources.Requests)
+	}
+	for _, c := range pod.Spec.InitContainers {
+		for name, quantity := range c.Resources.Requests {
+			if current, ok := requests[name]; !ok || quantity.Cmp(current) > 0 {
+				requests[name] = quantity.DeepCopy()
+			}
+		}
+	}
+	return requests
+}
+
+// addResources adds the quantities of resources to sum.
+func addResources(sum, resources v1.ResourceList) {
+	for name, quantity := range resources {
+		if current, ok := sum[name]; ok {
+			current.Add(quantity)
+			sum[name] = current
+		} else {
+			sum[name] = quantity.DeepCopy()
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package robot
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestCapacity(t *testing.T) {
+	m := &member{Cluster: Cluster{Name: "one", Resources: []RN{{RType: Nodes}, {RType: Pods}}}}
+	m.indexers = []cache.Indexer{m.newIndexer(), m.newIndexer()}
+
+	node := func(name string, cpu string, unschedulable bool, conditions ...v1.NodeConditionType) *v1.Node {
+		n := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		n.Spec.Unschedulable = unschedulable
+		n.Status.Allocatable = v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu)}
+		for _, c := range conditions {
+			n.Status.Conditions = append(n.Status.Conditions, v1.NodeCondition{Type: c, Status: v1.ConditionTrue})
+		}
+		n.Status.Conditions = append(n.Status.Conditions, v1.NodeCondition{Type: v1.NodeDiskPressure, Status: v1.ConditionFalse})
+		return n
+	}
+	m.indexers[0].Add(node("a", "4", false, v1.NodeReady))
+	m.indexers[0].Add(node("b", "2", true, v1.NodeReady, v1.NodeMemoryPressure))
+
+	pod := func(name, nodeName, cpu string, phase v1.PodPhase) *v1.Pod {
+		p := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}}
+		p.Spec.NodeName = nodeName
+		p.Spec.Containers = []v1.Container{{Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu)}}}}
+		p.Status.Phase = phase
+		return p
+	}
+	m.indexers[1].Add(pod("one", "a", "500m", v1.PodRunning))
+	m.indexers[1].Add(pod("two", "b", "1", v1.PodRunning))
+	m.indexers[1].Add(pod("done", "b", "1", v1.PodSucceeded))
+	m.indexers[1].Add(pod("pending", "", "1", v1.PodPending))
+
+	capacity := m.capacity()
+	if capacity == nil {
+		t.Fatalf("expected capacity")
+	}
+	if e, a := 2, capacity.Nodes; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := 1, capacity.Unschedulable; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := resource.MustParse("6"), capacity.Allocatable[v1.ResourceCPU]; e.Cmp(a) != 0 {
+		t.Errorf("expected %v, got %v", e.String(), a.String())
+	}
+	if e, a := resource.MustParse("1500m"), capacity.Requested[v1.ResourceCPU]; e.Cmp(a) != 0 {
+		t.Errorf("expected %v, got %v", e.String(), a.String())
+	}
+	for condition, e := range map[v1.NodeConditionType]int{v1.NodeReady: 2, v1.NodeMemoryPressure: 1, v1.NodeDiskPressure: 0} {
+		if a := capacity.Conditions[condition]; e != a {
+			t.Errorf("expected %v %v, got %v", e, condition, a)
+		}
+	}
+
+	if capacity := (&member{Cluster: Cluster{Resources: []RN{{RType: Pods}}}}).capacity(); capacity != nil {
+		t.Errorf("expected no capacity without nodes, got %v", capacity)
+	}
+}
@@ -0,0 +1,316 @@
+package robot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+const defaultCheckpointInterval = 10 * time.Second
+
+// Checkpointer persists the resourceVersions of resources in clusters up to
+// which consumers finished all events.
+//
+// Objects not newer than the checkpoint are not sent as EventAdd after restart,
+// consumers are expected to keep their own state of them. Resources are still
+// listed after restart to fill the store, a checkpoint saves consumers from
+// processing every object again, not the relist. Note that objects deleted
+// while the robot is down can't be noticed.
+type Checkpointer interface {
+	// Load returns the saved resourceVersion of the resource, empty if none.
+	Load(cluster string, r RN) (string, error)
+
+	Save(cluster string, r RN, resourceVersion string) error
+}
+
+// fileCheckpointer keeps resourceVersions in a JSON file.
+type fileCheckpointer struct {
+	path string
+
+	mu       sync.Mutex
+	versions map[string]string
+}
+
+// NewFileCheckpointer returns a Checkpointer keeping resourceVersions in the file at path.
+func NewFileCheckpointer(path string) (Checkpointer, error) {
+	c := &fileCheckpointer{path: path, versions: make(map[string]string)}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.versions); err != nil {
+		return nil, fmt.Errorf("robot: invalid checkpoint file %s: %v", path, err)
+	}
+	return c, nil
+}
+
+func checkpointKey(cluster string, r RN) string {
+	return cluster + "|" + r.RType.String() + "|" + r.Namespace
+}
+
+func (c *fileCheckpointer) Load(cluster string, r RN) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.versions[checkpointKey(cluster, r)], nil
+}
+
+func (c *fileCheckpointer) Save(cluster string, r RN, resourceVersion string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := checkpointKey(cluster, r)
+	if c.versions[key] == resourceVersion {
+		return nil
+	}
+	c.versions[key] = resourceVersion
+
+	data, err := json.Marshal(c.versions)
+	if err != nil {
+		return err
+	}
+	// write then rename, so the file is never partially written
+	tmp := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// loadCheckpoints loads resourceVersions of the resources of the member.
+func (c *controller) loadCheckpoints(m *member) error {
+	if c.opts.Checkpointer == nil {
+		return nil
+	}
+
+	m.checkpoints = make(map[RN]string)
+	m.progress = make(map[RN]*progress)
+	for _, r := range m.Resources {
+		m.progress[r] = newProgress()
+		version, err := c.opts.Checkpointer.Load(m.String(), r)
+		if err != nil {
+			return fmt.Errorf("robot: load checkpoint of %s in cluster %s: %v", r.RType, m, err)
+		}
+		m.checkpoints[r] = version
+	}
+	return nil
+}
+
+// saveCheckpoints saves resourceVersions periodically until the robot stops.
+func (c *controller) saveCheckpoints() {
+	if c.opts.Checkpointer == nil {
+		return
+	}
+
+	interval := c.opts.CheckpointInterval
+	if interval <= 0 {
+		interval = defaultCheckpointInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+			c.checkpoint()
+			c.mu.Unlock()
+		}
+	}
+}
+
+// checkpoint saves resourceVersions up to which consumers finished all events
+// of running informers, c.mu must be held.
+func (c *controller) checkpoint() {
+	if c.opts.Checkpointer == nil {
+		return
+	}
+
+	for _, m := range c.clusters {
+		if !m.running {
+			continue
+		}
+		for _, one := range m.informers {
+			version := m.progress[one.rn].checkpoint()
+			if version == "" {
+				continue
+			}
+			if err := c.opts.Checkpointer.Save(m.String(), one.rn, version); err != nil {
+				c.report(fmt.Errorf("robot: save checkpoint of %s in cluster %s: %v", one.resource, m, err))
+			}
+		}
+	}
+}
+
+// ReQueue requeues the object, see Robot. Objects given up are done for checkpoints.
+func (c *controller) ReQueue(obj QueueObject) error {
+	err := c.queue.ReQueue(obj)
+	if err != nil {
+		c.finished(obj)
+	}
+	return err
+}
+
+// finished marks the event of obj done for checkpoints.
+func (c *controller) finished(obj QueueObject) {
+	if c.opts.Checkpointer == nil {
+		return
+	}
+	c.mu.Lock()
+	m := c.memberOf(obj.Cluster)
+	c.mu.Unlock()
+	m.finished(obj)
+}
+
+// finished marks the event of obj done in the progress of each RN of its
+// resource, as they may overlap. A nil member finishes nothing.
+func (m *member) finished(obj QueueObject) {
+	if m == nil {
+		return
+	}
+	for r, p := range m.progress {
+		if r.RType == obj.RType {
+			p.finished(obj)
+		}
+	}
+}
+
+// progress tracks events of a resource of a cluster not finished by consumers,
+// so that only resourceVersions whose events they finished are checkpointed.
+// A nil one tracks nothing.
+type progress struct {
+	mu sync.Mutex
+	// pending are the oldest resourceVersion of each object whose events
+	// delivered aren't finished, and when the first of them was delivered.
+	pending map[string]pendingVersion
+	// last is the last resourceVersion observed, including events dropped,
+	// e.g. by sampling or rate limits, which consumers never finish.
+	last uint64
+}
+
+type pendingVersion struct {
+	version uint64
+	at      time.Time
+}
+
+func newProgress() *progress {
+	return &progress{pending: make(map[string]pendingVersion)}
+}
+
+// observed advances the checkpoint to obj, unless events are pending.
+func (p *progress) observed(obj interface{}) {
+	if p == nil {
+		return
+	}
+	version, ok := resourceVersionOf(obj)
+	if !ok {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if version > p.last {
+		p.last = version
+	}
+}
+
+// delivered tracks the event of obj delivered to consumers until an event of
+// the object delivered at or after it is finished.
+func (p *progress) delivered(item QueueObject, obj interface{}) {
+	if p == nil {
+		return
+	}
+	version, ok := resourceVersionOf(obj)
+	if !ok {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if version > p.last {
+		p.last = version
+	}
+	if _, ok := p.pending[item.Key]; !ok {
+		p.pending[item.Key] = pendingVersion{version, item.CreateAt}
+	}
+}
+
+// finished marks events of the object delivered at or before obj done, as the
+// consumer got the object from the store after they were emitted.
+func (p *progress) finished(obj QueueObject) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if pending, ok := p.pending[obj.Key]; ok && !pending.at.After(obj.CreateAt) {
+		delete(p.pending, obj.Key)
+	}
+}
+
+// checkpoint returns the last resourceVersion up to which all events are
+// finished, empty if none.
+func (p *progress) checkpoint() string {
+	if p == nil {
+		return ""
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	version := p.last
+	for _, pending := range p.pending {
+		if pending.version <= version {
+			version = pending.version - 1
+		}
+	}
+	if version == 0 {
+		return ""
+	}
+	return strconv.FormatUint(version, 10)
+}
+
+// resourceVersionOf returns the resourceVersion of obj as a number.
+func resourceVersionOf(obj interface{}) (uint64, bool) {
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return 0, false
+	}
+	version, err := strconv.ParseUint(m.GetResourceVersion(), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return version, true
+}
+
+// newerThan reports whether the resourceVersion of obj is newer than version.
+// resourceVersions are compared as numbers, which holds for etcd based api servers,
+// anything not comparable is considered newer.
+func newerThan(obj interface{}, version string) bool {
+	if version == "" {
+		return true
+	}
+	checkpoint, err := strconv.ParseUint(version, 10, 64)
+	if err != nil {
+		return true
+	}
+
+	current, ok := resourceVersionOf(obj)
+	if !ok {
+		return true
+	}
+	return current > checkpoint
+}
@@ -0,0 +1,121 @@
+package robot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestFileCheckpointer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "robot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "checkpoint")
+	c, err := NewFileCheckpointer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Save("one", RN{RType: Pods}, "42"); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = NewFileCheckpointer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := c.Load("one", RN{RType: Pods}); version != "42" {
+		t.Errorf("expected %v, got %v", "42", version)
+	}
+	if version, _ := c.Load("one", RN{RType: Pods, Namespace: "default"}); version != "" {
+		t.Errorf("expected no version, got %v", version)
+	}
+}
+
+func TestCheckpointSkipsUnchangedAdds(t *testing.T) {
+	var sent []QueueObject
+	c := &controller{queue: &recordQueue{sent: &sent}}
+	m := &member{checkpoints: map[RN]string{{RType: Pods}: "10"}}
+
+	emit := c.emit(m, RN{RType: Pods})
+	for _, version := range []string{"9", "10", "11", ""} {
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "one", ResourceVersion: version}}
+		emit(QueueObject{Event: EventAdd, RType: Pods, Key: "default/one"}, pod)
+	}
+	emit(QueueObject{Event: EventUpdate, RType: Pods, Key: "default/one"}, &v1.Pod{})
+
+	if e, a := 3, len(sent); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestCheckpointFinishedOnly(t *testing.T) {
+	q := newWorkQueue()
+	m := &member{Cluster: Cluster{Name: "one"}, progress: map[RN]*progress{{RType: Pods}: newProgress()}}
+	c := &controller{opts: Options{Checkpointer: &fileCheckpointer{}}, queue: q, clusters: []*member{m}}
+
+	emit := c.emit(m, RN{RType: Pods})
+	for i, version := range []string{"5", "7"} {
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: version, ResourceVersion: version}}
+		emit(QueueObject{Event: EventAdd, RType: Pods, Key: "default/" + version, CreateAt: time.Now().Add(time.Duration(i))}, pod)
+	}
+	progress := m.progress[RN{RType: Pods}]
+	if e, a := "4", progress.checkpoint(); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	// The event 7 is finished, but 5 isn't yet.
+	first, _ := c.Pop()
+	second, _ := c.Pop()
+	if first.Key != "default/5" {
+		first, second = second, first
+	}
+	c.Finish(second)
+	if e, a := "4", progress.checkpoint(); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	// Objects given up are done too.
+	for c.ReQueue(first) == nil {
+		first, _ = c.Pop()
+	}
+	if e, a := "7", progress.checkpoint(); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestCheckpointDroppedEvents(t *testing.T) {
+	q := newWorkQueue()
+	r := RN{RType: Pods, SampleUpdates: 2}
+	other := RN{RType: Pods, Namespace: "other"}
+	m := &member{Cluster: Cluster{Name: "one"}, progress: map[RN]*progress{r: newProgress(), other: newProgress()}}
+	c := &controller{opts: Options{Checkpointer: &fileCheckpointer{}}, queue: q, clusters: []*member{m}}
+
+	emit := c.emit(m, r)
+	pod := func(version string) *v1.Pod {
+		return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", ResourceVersion: version}}
+	}
+	emit(QueueObject{Event: EventAdd, RType: Pods, Key: "default/web", CreateAt: time.Now()}, pod("5"))
+	item, _ := c.Pop()
+	c.Finish(item)
+
+	// The first update is sent, the second is dropped by sampling.
+	emit(QueueObject{Event: EventUpdate, RType: Pods, Key: "default/web", CreateAt: time.Now()}, pod("6"))
+	emit(QueueObject{Event: EventUpdate, RType: Pods, Key: "default/web", CreateAt: time.Now()}, pod("7"))
+	item, _ = c.Pop()
+	c.Finish(item)
+
+	if e, a := "7", m.progress[r].checkpoint(); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := "", m.progress[other].checkpoint(); e != a {
+		t.Errorf("expected the progress of another RN untouched, got %v", a)
+	}
+}
@@ -0,0 +1,74 @@
+package robot
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// clockSmoothing is the weight of each new sample of the skew of a clock.
+const clockSmoothing = 0.2
+
+// clockSkew estimates how far the clock of the api server of a cluster is
+// ahead of the local clock, by the Date headers of its responses. A nil one
+// estimates nothing.
+type clockSkew struct {
+	mu      sync.Mutex
+	skew    time.Duration
+	sampled bool
+}
+
+func newClockSkew() *clockSkew {
+	return &clockSkew{}
+}
+
+// observe samples the skew by the Date header of a response to a request
+// sent at start and received at end.
+func (s *clockSkew) observe(header http.Header, start, end time.Time) {
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		return
+	}
+	// Date is in seconds, the server time was in the second of it, and the
+	// response was sent halfway through the round trip.
+	server := date.Add(500 * time.Millisecond)
+	local := start.Add(end.Sub(start) / 2)
+	sample := server.Sub(local)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.sampled {
+		s.skew, s.sampled = sample, true
+		return
+	}
+	s.skew += time.Duration(clockSmoothing * float64(sample-s.skew))
+}
+
+// get returns the skew estimated, zero if none is sampled yet.
+func (s *clockSkew) get() time.Duration {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.skew
+}
+
+// wrap returns a transport sampling the skew by the responses of rt.
+func (s *clockSkew) wrap(rt http.RoundTripper) http.RoundTripper {
+	return &clockTransport{rt: rt, skew: s}
+}
+
+type clockTransport struct {
+	rt   http.RoundTripper
+	skew *clockSkew
+}
+
+func (t *clockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.rt.RoundTrip(req)
+	if err == nil {
+		t.skew.observe(resp.Header, start, time.Now())
+	}
+	return resp, err
+}
@@ -0,0 +1,72 @@
+package robot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestClockSkew(t *testing.T) {
+	s := newClockSkew()
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	header := http.Header{"Date": {start.Add(10 * time.Second).Format(http.TimeFormat)}}
+
+	s.observe(header, start, start.Add(time.Second))
+	if e, a := 10*time.Second, s.get(); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	// Samples are smoothed.
+	s.observe(http.Header{"Date": {start.Format(http.TimeFormat)}}, start, start.Add(time.Second))
+	if e, a := 8*time.Second, s.get(); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	s.observe(http.Header{}, start, start)
+	if e, a := 8*time.Second, s.get(); e != a {
+		t.Errorf("expected %v without Date, got %v", e, a)
+	}
+
+	var none *clockSkew
+	if a := none.get(); a != 0 {
+		t.Errorf("expected no skew, got %v", a)
+	}
+}
+
+func TestClockTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Date", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	}))
+	defer server.Close()
+
+	s := newClockSkew()
+	client := &http.Client{Transport: s.wrap(http.DefaultTransport)}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if skew := s.get(); skew < time.Hour-2*time.Second || skew > time.Hour+2*time.Second {
+		t.Errorf("expected a skew of about an hour, got %v", skew)
+	}
+}
+
+func TestEmitClockSkew(t *testing.T) {
+	var sent []QueueObject
+	c := &controller{queue: &recordQueue{sent: &sent}}
+	m := &member{Cluster: Cluster{Name: "east", clock: newClockSkew()}}
+	start := time.Now()
+	m.clock.observe(http.Header{"Date": {start.Add(time.Minute).UTC().Format(http.TimeFormat)}}, start, start)
+
+	c.emit(m, RN{RType: Pods})(QueueObject{Event: EventAdd, RType: Pods, Key: "default/one"}, &v1.Pod{})
+	if e, a := 1, len(sent); e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	if skew := sent[0].ClockSkew; skew < time.Minute-time.Second || skew > time.Minute+time.Second {
+		t.Errorf("expected a skew of about a minute, got %v", skew)
+	}
+}
@@ -0,0 +1,606 @@
+package robot
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+type Cluster struct {
+	// Name identifies the cluster in events and the API of the robot. It's derived
+	// from the context of the kubeconfig file if empty, see deriveName.
+	Name string
+
+	ConfigPath string
+	MasterUrl  string
+	Resources  []RN
+
+	// Kubeconfig is the content of a kubeconfig file, used instead of ConfigPath,
+	// e.g. credentials pulled from Vault or a Secret.
+	Kubeconfig []byte
+
+	// Context is the context of the kubeconfig file to use,
+	// the current context if empty.
+	Context string
+
+	// DryRun only counts and logs events of the cluster without sending them
+	// to consumers, to estimate the volume of events before enabling it.
+	DryRun bool
+
+	// RateLimit limits events of the cluster, in addition to RateLimit of each resource.
+	RateLimit RateLimit
+
+	// Labels are user defined metadata of the cluster, e.g. region=eu and env=prod,
+	// which are stamped onto every event of the cluster.
+	Labels map[string]string
+
+	// Primary makes this cluster a standby of the primary cluster, as in
+	// ClusterStatus. Events of a standby are suppressed while its primary is
+	// healthy, and sent once the primary becomes unreachable.
+	Primary string
+
+	// HotReload rebuild the client when the file of ConfigPath changes,
+	// e.g. credentials rotated, and restart the informers of this cluster
+	// without losing the store.
+	HotReload bool
+
+	// Interactive allow auth plugins to prompt for input on stdin,
+	// it's disabled by default since the robot usually runs unattended.
+	// Note that cloud auth providers (gcp, azure, oidc) must be registered by
+	// importing k8s.io/client-go/plugin/pkg/client/auth in the main package.
+	Interactive bool
+
+	// ExecTimeout bounds each run of the exec credential plugin of the kubeconfig,
+	// e.g. aws-iam-authenticator, so that a hanging plugin fails requests instead
+	// of blocking them. Plugins must print tokens then, client certificates aren't
+	// supported. Zero means the plugin is run by client-go without a bound.
+	ExecTimeout time.Duration
+
+	// Impersonate the user, groups and extra of requests to the cluster,
+	// so the robot can observe it under a constrained RBAC persona.
+	// It overrides the impersonation of the kubeconfig if UserName is set.
+	Impersonate rest.ImpersonationConfig
+
+	// BearerToken, CAData, CertData, KeyData and Insecure override the
+	// credentials of the kubeconfig, so that credentials from a vault can be
+	// used with MasterUrl directly, without files on disk.
+	BearerToken string
+	CAData      []byte
+	CertData    []byte
+	KeyData     []byte
+	Insecure    bool
+
+	// ProxyURL is the HTTP(S) or SOCKS5 proxy to access the cluster through,
+	// e.g. a bastion. The proxy of the environment is used if empty.
+	ProxyURL string
+
+	// DialTimeout bounds establishing a connection to the cluster, 30s if zero.
+	DialTimeout time.Duration
+
+	// WrapTransport wraps the transport of requests to the cluster,
+	// e.g. for request logging or SPIFFE mTLS.
+	WrapTransport func(http.RoundTripper) http.RoundTripper
+
+	// clock estimates the clock skew of the api server by responses.
+	clock *clockSkew
+}
+
+// String returns the name of the cluster,
+// or where it's accessed from if it's unnamed.
+func (c *Cluster) String() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	if c.ConfigPath != "" {
+		return c.ConfigPath
+	}
+	return c.MasterUrl
+}
+
+// is returns whether the cluster is identified by name,
+// which is its name or where it's accessed from.
+func (c *Cluster) is(name string) bool {
+	return name != "" && (name == c.Name || name == c.ConfigPath || name == c.MasterUrl)
+}
+
+// deriveName returns the name of the context used in the kubeconfig,
+// empty if there isn't one.
+func (c *Cluster) deriveName() string {
+	var config *clientcmdapi.Config
+	var err error
+	switch {
+	case c.Kubeconfig != nil:
+		config, err = clientcmd.Load(c.Kubeconfig)
+	case c.ConfigPath != "":
+		config, err = clientcmd.LoadFromFile(c.ConfigPath)
+	default:
+		return ""
+	}
+	if err != nil {
+		return ""
+	}
+	context := c.Context
+	if context == "" {
+		context = config.CurrentContext
+	}
+	if _, ok := config.Contexts[context]; !ok {
+		return ""
+	}
+	return context
+}
+
+func (c *Cluster) newClient() (*kubernetes.Clientset, error) {
+	config, err := c.restConfig()
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return clientset, nil
+}
+
+func (c *Cluster) newDynamicClient() (dynamic.Interface, error) {
+	config, err := c.restConfig()
+	if err != nil {
+		return nil, err
+	}
+	return dynamic.NewForConfig(config)
+}
+
+func (c *Cluster) restConfig() (*rest.Config, error) {
+	if c.ConfigPath == "" && c.MasterUrl == "" && c.Kubeconfig == nil {
+		return nil, ErrNoClusterConfig
+	}
+
+	rules := &clientcmd.ClientConfigLoadingRules{ExplicitPath: c.ConfigPath}
+	overrides := &clientcmd.ConfigOverrides{
+		ClusterInfo: clientcmdapi.Cluster{
+			Server:                   c.MasterUrl,
+			CertificateAuthorityData: c.CAData,
+			InsecureSkipTLSVerify:    c.Insecure,
+		},
+		AuthInfo: clientcmdapi.AuthInfo{
+			Token:                 c.BearerToken,
+			ClientCertificateData: c.CertData,
+			ClientKeyData:         c.KeyData,
+		},
+		CurrentContext: c.Context,
+	}
+
+	var loader clientcmd.ClientConfig
+	switch {
+	case c.Kubeconfig != nil:
+		config, err := clientcmd.Load(c.Kubeconfig)
+		if err != nil {
+			return nil, err
+		}
+		if c.Interactive {
+			loader = clientcmd.NewInteractiveClientConfig(*config, c.Context, overrides, os.Stdin, nil)
+		} else {
+			loader = clientcmd.NewNonInteractiveClientConfig(*config, c.Context, overrides, nil)
+		}
+	case c.Interactive:
+		loader = clientcmd.NewInteractiveDeferredLoadingClientConfig(rules, overrides, os.Stdin)
+	default:
+		loader = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+	}
+
+	config, err := loader.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.setTransport(config); err != nil {
+		return nil, err
+	}
+
+	if c.Impersonate.UserName != "" {
+		config.Impersonate = c.Impersonate
+	}
+
+	if config.ExecProvider != nil && c.ExecTimeout > 0 {
+		credentials := &execCredentials{plugin: config.ExecProvider, timeout: c.ExecTimeout}
+		config.ExecProvider = nil
+		wrap := config.WrapTransport
+		config.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+			if wrap != nil {
+				rt = wrap(rt)
+			}
+			return credentials.wrap(rt)
+		}
+	}
+
+	return config, nil
+}
+
+// setTransport applies the proxy, dial timeout and transport wrapper of the cluster to config.
+func (c *Cluster) setTransport(config *rest.Config) error {
+	if c.DialTimeout > 0 {
+		config.Dial = (&net.Dialer{
+			Timeout:   c.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+
+	var wrappers []func(http.RoundTripper) http.RoundTripper
+	if c.ProxyURL != "" {
+		proxy, err := url.Parse(c.ProxyURL)
+		if err != nil {
+			return fmt.Errorf("robot: invalid proxy url %s: %v", c.ProxyURL, err)
+		}
+		wrappers = append(wrappers, func(rt http.RoundTripper) http.RoundTripper {
+			return proxyTransport(rt, http.ProxyURL(proxy))
+		})
+	}
+	if c.clock != nil {
+		wrappers = append(wrappers, c.clock.wrap)
+	}
+	if config.WrapTransport != nil {
+		wrappers = append(wrappers, config.WrapTransport)
+	}
+	if c.WrapTransport != nil {
+		wrappers = append(wrappers, c.WrapTransport)
+	}
+	if len(wrappers) == 0 {
+		return nil
+	}
+
+	config.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		for _, wrap := range wrappers {
+			rt = wrap(rt)
+		}
+		return rt
+	}
+	return nil
+}
+
+// proxyTransport returns a copy of the transport rt which uses proxy. The transports
+// are shared by clients with the same TLS options, so rt must not be modified.
+func proxyTransport(rt http.RoundTripper, proxy func(*http.Request) (*url.URL, error)) http.RoundTripper {
+	base, ok := rt.(*http.Transport)
+	if !ok {
+		return rt
+	}
+	return utilnet.SetTransportDefaults(&http.Transport{
+		Proxy:               proxy,
+		TLSHandshakeTimeout: base.TLSHandshakeTimeout,
+		TLSClientConfig:     base.TLSClientConfig,
+		MaxIdleConnsPerHost: base.MaxIdleConnsPerHost,
+		DialContext:         base.DialContext,
+	})
+}
+
+// member is a cluster monitored by the robot.
+type member struct {
+	Cluster
+
+	// indexers are kept across client rebuilds, one per Resources.
+	indexers []cache.Indexer
+
+	client    kubernetes.Interface
+	informers informerSet
+	stop      chan struct{}
+	running   bool
+
+	// version of the api server, nil if unknown.
+	version *version.Info
+
+	// served are the resources served by the cluster, nil if unknown.
+	served map[Resource]metav1.APIResource
+
+	// history keeps the last versions of objects, nil if disabled.
+	history *history
+
+	// latency records latencies of events until finished.
+	latency *latencyRecorder
+
+	// observed counts events in dry run mode.
+	observed *eventCounter
+
+	// limiter limits events of the cluster, and limiters of each resource.
+	limiter  *limiter
+	limiters map[RN]*limiter
+
+	// primary of a standby member.
+	primary *member
+
+	// unhealthy is set when health checks of a primary fail, accessed atomically.
+	unhealthy int32
+
+	// breaker pauses the member when its api server fails heavily,
+	// the member is degraded meanwhile.
+	breaker  *breaker
+	degraded bool
+
+	// checkpoints are resourceVersions of each resource loaded at start.
+	checkpoints map[RN]string
+	// progress tracks events of each resource not finished, for checkpoints.
+	progress map[RN]*progress
+
+	// streamingList lists resources by watch streams if supported, see Options.
+	streamingList bool
+
+	// syncNamespaceDeletes deletes cached objects of deleted namespaces, see Options.
+	syncNamespaceDeletes bool
+
+	// preflightAccess skips resources the member can't list or watch, see Options.
+	preflightAccess bool
+
+	// keyFunc keys objects in the queue and indexers, see Options.
+	keyFunc KeyFunc
+
+	// dependsOn orders the start of informers of resources, see Options.
+	dependsOn map[Resource][]Resource
+
+	// versions are alternative versions of resources, and converter converts
+	// objects of them, see Options.
+	versions  map[Resource][]string
+	converter Converter
+
+	// onSnapshot receives objects of each resource once synced, see Options.
+	onSnapshot SnapshotFunc
+
+	// scheme types objects of resources not built in, see Options.
+	scheme *runtime.Scheme
+
+	// unstructured caches and emits all objects as unstructured, see Options.
+	unstructured bool
+
+	// handlers override handlers of events of resources, see Options.
+	handlers map[Resource]HandlerFunc
+
+	// namespaceDeletes is the informer of namespaces if syncNamespaceDeletes,
+	// and tombstones are the namespaces it deleted.
+	namespaceDeletes cache.Controller
+	tombstones       *namespaceTombstones
+
+	// dynamic is the client of resources which aren't built in,
+	// nil if there are none.
+	dynamic dynamic.Interface
+}
+
+func (m *member) isHealthy() bool {
+	return atomic.LoadInt32(&m.unhealthy) == 0
+}
+
+// build creates informers of the member with client, resources
+// not served by the cluster are skipped.
+func (m *member) build(client kubernetes.Interface, emitter func(RN) emitFunc, report func(error)) {
+	m.install(client, m.discover(client, report), emitter, report)
+}
+
+// discovered is what the member learns of its cluster by requests, see discover.
+type discovered struct {
+	served  map[Resource]metav1.APIResource
+	version *version.Info
+	dynamic dynamic.Interface
+	denied  map[RN]bool
+}
+
+// discover makes the requests of build to the cluster, without changing the
+// member, so that it's called without c.mu held, and a slow or failing api
+// server doesn't block the robot. Its result is installed by install.
+func (m *member) discover(client kubernetes.Interface, report func(error)) *discovered {
+	d := &discovered{}
+	resources := make([]Resource, 0, len(m.Resources))
+	for _, r := range m.Resources {
+		resources = append(resources, r.RType)
+		for _, version := range m.versions[r.RType] {
+			alt := r.RType
+			alt.Version = version
+			resources = append(resources, alt)
+		}
+	}
+	var err error
+	d.served, err = servedResources(client.Discovery(), resources)
+	if err != nil {
+		// The cluster may be unreachable for now, let reflectors retry.
+		report(fmt.Errorf("robot: discover resources of cluster %s: %v", m, err))
+	}
+
+	d.version, err = client.Discovery().ServerVersion()
+	if err != nil {
+		report(fmt.Errorf("robot: discover version of cluster %s: %v", m, err))
+	}
+
+	for _, r := range m.Resources {
+		if !m.typed(r.RType) {
+			if d.dynamic, err = m.newDynamicClient(); err != nil {
+				report(fmt.Errorf("robot: create dynamic client of cluster %s: %v", m, err))
+			}
+			break
+		}
+	}
+
+	if m.preflightAccess {
+		d.denied = m.checkAccess(client, d.served, report)
+	}
+	return d
+}
+
+// install creates informers of the member with client and what's discovered,
+// without requests to the cluster.
+func (m *member) install(client kubernetes.Interface, d *discovered, emitter func(RN) emitFunc, report func(error)) {
+	m.served = d.served
+	m.version = d.version
+	m.dynamic = d.dynamic
+	served, denied := d.served, d.denied
+
+	if m.syncNamespaceDeletes && m.tombstones == nil {
+		m.tombstones = newNamespaceTombstones()
+	}
+
+	informers := make(informerSet, 0, len(m.Resources))
+	for i, r := range m.Resources {
+		if _, ok := served[m.alternative(r.RType)]; served != nil && !ok {
+			report(fmt.Errorf("robot: cluster %s doesn't serve %s, skipped", m, r.RType))
+			continue
+		}
+		if denied[r] {
+			continue
+		}
+		if !m.typed(r.RType) && m.dynamic == nil {
+			continue
+		}
+		if !m.namespaced(r.RType) && (r.Namespace != "" || r.NamespaceSelector != "") {
+			report(fmt.Errorf("robot: %s is cluster scoped in cluster %s, namespace can't be set, skipped", r.RType, m))
+			continue
+		}
+		var controller cache.Controller
+		if r.NamespaceSelector != "" {
+			controller = m.newNamespaceScope(client, r, m.indexers[i], emitter(r), report)
+		} else {
+			controller = r.createInformer(m.listWatch(client, r), m.object(r.RType), m.key, m.tombstones.indexer(m.indexers[i]), m.handler(r, emitter(r), report))
+		}
+		one := newInformer(r.RType, controller)
+		one.cluster = m.String()
+		one.rn = r
+		one.indexer = m.indexers[i]
+		informers = append(informers, one)
+	}
+
+	m.namespaceDeletes = nil
+	if m.syncNamespaceDeletes {
+		m.namespaceDeletes = m.newNamespaceDeletes(client, emitter, report)
+	}
+
+	informers.order(m.dependsOn)
+
+	m.client = client
+	m.informers = informers
+	m.stop = make(chan struct{})
+}
+
+// listWatch returns the ListerWatcher of the resource,
+// which records failures to the breaker of the member and counts watches.
+func (m *member) listWatch(client kubernetes.Interface, r RN) cache.ListerWatcher {
+	var lw cache.ListerWatcher
+	if m.typed(r.RType) {
+		lw = cache.NewListWatchFromClient(client.CoreV1().RESTClient(), r.RType.Resource, r.Namespace, fields.Everything())
+		if m.streamingList && supportsStreamingList(m.version) {
+			lw = newStreamingListWatch(client.CoreV1().RESTClient(), r, lw)
+		}
+	} else if alt := m.alternative(r.RType); alt != r.RType {
+		watched := r
+		watched.RType = alt
+		lw = m.convertingListWatch(dynamicListWatch(m.dynamic, watched), r.RType)
+	} else {
+		lw = dynamicListWatch(m.dynamic, r)
+	}
+	if gvk, ok := m.schemeKind(r.RType); ok {
+		lw = m.typedListWatch(lw, gvk)
+	}
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			obj, err := lw.List(options)
+			m.breaker.record(err)
+			return obj, err
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			w, err := lw.Watch(options)
+			m.breaker.record(err)
+			metrics.Add(metricReconnects, 1)
+			return w, err
+		},
+	}
+}
+
+// typed reports whether objects of the resource are typed in the member.
+func (m *member) typed(r Resource) bool {
+	return r.typed() && !m.unstructured
+}
+
+// namespaced reports whether the resource is namespaced in the member,
+// by discovery if served, otherwise by built in scopes.
+func (m *member) namespaced(r Resource) bool {
+	return m.namespacedIn(m.served, r)
+}
+
+// namespacedIn is namespaced by the resources served.
+func (m *member) namespacedIn(served map[Resource]metav1.APIResource, r Resource) bool {
+	if resource, ok := served[m.alternativeIn(served, r)]; ok {
+		return resource.Namespaced
+	}
+	return !r.clusterScoped()
+}
+
+// object returns an empty object of the resource in the member.
+func (m *member) object(r Resource) runtime.Object {
+	if gvk, ok := m.schemeKind(r); ok {
+		if obj, err := m.scheme.New(gvk); err == nil {
+			return obj
+		}
+	}
+	if m.unstructured && r != All {
+		return &unstructured.Unstructured{}
+	}
+	return r.object()
+}
+
+// dynamicListWatch returns the ListerWatcher of a resource which isn't built in.
+func dynamicListWatch(client dynamic.Interface, r RN) cache.ListerWatcher {
+	resource := client.Resource(r.RType.GroupVersionResource()).Namespace(r.Namespace)
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return resource.List(options)
+		},
+		WatchFunc: resource.Watch,
+	}
+}
+
+func (m *member) run(report func(error)) {
+	m.running = true
+	m.informers.run(m.stop, report)
+
+	if m.onSnapshot != nil {
+		for _, one := range m.informers {
+			go m.snapshot(one, m.stop, report)
+		}
+	}
+
+	if m.namespaceDeletes != nil {
+		go func(stop chan struct{}) {
+			defer handleCrash(report, "namespace informer of cluster %s", m)
+
+			m.namespaceDeletes.Run(stop)
+		}(m.stop)
+	}
+}
+
+// halt stops the informers of the member if running,
+// they must be built again before the next run.
+func (m *member) halt() {
+	if m.running {
+		close(m.stop)
+		m.running = false
+		m.informers = nil
+	}
+}
+
+// haltResources stops the informers of the resources alone,
+// the other informers of the member keep running.
+func (m *member) haltResources(resources ...Resource) {
+	if m.running {
+		m.informers = m.informers.halt(resources...)
+	}
+}
@@ -0,0 +1,181 @@
+package robot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: one
+  cluster:
+    server: https://one.example.com
+- name: two
+  cluster:
+    server: https://two.example.com
+users:
+- name: plain
+  user:
+    token: secret
+- name: exec
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: sleep
+      args: ["5"]
+contexts:
+- name: one
+  context:
+    cluster: one
+    user: plain
+- name: two
+  context:
+    cluster: two
+    user: exec
+current-context: one
+`
+
+func writeKubeconfig(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "robot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(path, []byte(testKubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestClusterRestConfig(t *testing.T) {
+	path, cleanup := writeKubeconfig(t)
+	defer cleanup()
+
+	config, err := (&Cluster{ConfigPath: path}).restConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := "https://one.example.com", config.Host; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	config, err = (&Cluster{ConfigPath: path, Context: "two"}).restConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.ExecProvider == nil {
+		t.Errorf("expected exec provider of context two")
+	}
+
+	config, err = (&Cluster{ConfigPath: path, Context: "two", ExecTimeout: 100 * time.Millisecond}).restConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.ExecProvider != nil || config.WrapTransport == nil {
+		t.Errorf("expected the exec plugin run by the robot with a timeout")
+	}
+
+	config, err = (&Cluster{
+		ConfigPath:  path,
+		Impersonate: rest.ImpersonationConfig{UserName: "robot", Groups: []string{"observers"}},
+	}).restConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := "robot", config.Impersonate.UserName; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := 1, len(config.Impersonate.Groups); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	config, err = (&Cluster{MasterUrl: "https://three.example.com", BearerToken: "token", Insecure: true}).restConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := "https://three.example.com", config.Host; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := "token", config.BearerToken; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if !config.Insecure {
+		t.Errorf("expected insecure config")
+	}
+
+	wrapped := false
+	config, err = (&Cluster{
+		ConfigPath: path,
+		ProxyURL:   "socks5://bastion.example.com:1080",
+		WrapTransport: func(rt http.RoundTripper) http.RoundTripper {
+			wrapped = true
+			return rt
+		},
+	}).restConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rt, ok := config.WrapTransport(&http.Transport{}).(*http.Transport)
+	if !ok || rt.Proxy == nil {
+		t.Errorf("expected a transport with proxy")
+	}
+	if !wrapped {
+		t.Errorf("expected the transport to be wrapped")
+	}
+
+	if _, err := (&Cluster{}).restConfig(); err == nil {
+		t.Errorf("expected an error without ConfigPath and MasterUrl")
+	}
+}
+
+func TestClusterDeriveName(t *testing.T) {
+	path, cleanup := writeKubeconfig(t)
+	defer cleanup()
+
+	for _, test := range []struct {
+		cluster Cluster
+		name    string
+	}{
+		{Cluster{ConfigPath: path}, "one"},
+		{Cluster{ConfigPath: path, Context: "two"}, "two"},
+		{Cluster{ConfigPath: path, Context: "three"}, ""},
+		{Cluster{MasterUrl: "https://one.example.com"}, ""},
+	} {
+		if e, a := test.name, test.cluster.deriveName(); e != a {
+			t.Errorf("expected %v, got %v", e, a)
+		}
+	}
+
+	c := &Cluster{Name: "one", ConfigPath: path}
+	if e, a := "one", c.String(); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if !c.is(path) || !c.is("one") || c.is("two") {
+		t.Errorf("expected the cluster to be identified by its name and path")
+	}
+}
+
+func TestClusterKubeconfigBytes(t *testing.T) {
+	c := &Cluster{Kubeconfig: []byte(testKubeconfig), Context: "two"}
+	config, err := c.restConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.ExecProvider == nil {
+		t.Errorf("expected exec provider of context two")
+	}
+	if e, a := "two", c.deriveName(); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if _, err := (&Cluster{Kubeconfig: []byte("{")}).restConfig(); err == nil {
+		t.Errorf("expected error of invalid kubeconfig")
+	}
+}
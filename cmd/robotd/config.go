@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"gitlab.mfwdev.com/servicemesh/robot"
+)
+
+// config is the YAML config of the daemon, e.g.
+//
+//	debugAddr: ":8080"
+//	eventLog:
+//	  path: stdout
+//	  payload: jsonpatch
+//	audit:
+//	  path: /var/log/robot/audit.log
+//	  maxBackups: 5
+//	checkpoint:
+//	  path: /var/lib/robot/checkpoint.json
+//	sharding:
+//	  namespace: robot
+//	  group: robotd
+//	clusters:
+//	- name: east
+//	  configPath: /etc/robot/east.kubeconfig
+//	  labels: {region: us-east}
+//	  resources:
+//	  - resource: v1/pods
+//	  - resource: apps/v1/deployments
+//	    namespace: default
+type config struct {
+	// DebugAddr serves pprof, expvar metrics at /debug/vars, objects, costs
+	// and the event stream, see robot.Options.DebugAddr.
+	DebugAddr string `yaml:"debugAddr"`
+	// GraphQLAddr and XDSAddr serve queries of objects, and the xDS API, over
+	// HTTP. There is no gRPC server, as the robot serves none.
+	GraphQLAddr string `yaml:"graphqlAddr"`
+	XDSAddr     string `yaml:"xdsAddr"`
+
+	EventLog    *eventLogConfig    `yaml:"eventLog"`
+	EventStream *eventStreamConfig `yaml:"eventStream"`
+	Audit       *auditConfig       `yaml:"audit"`
+	Checkpoint  *checkpointConfig  `yaml:"checkpoint"`
+	Sharding    *shardingConfig    `yaml:"sharding"`
+
+	CostLabel   string `yaml:"costLabel"`
+	HistorySize int    `yaml:"historySize"`
+
+	Clusters []clusterConfig `yaml:"clusters"`
+}
+
+// eventLogConfig is the event log, the sink of events of all resources.
+type eventLogConfig struct {
+	// Path is the file written, or stdout.
+	Path string `yaml:"path"`
+
+	// Payload is none, object, jsonpatch or mergepatch.
+	Payload string `yaml:"payload"`
+
+	// Codec is json, protobuf or avro, which needs SchemaRegistry.
+	Codec          string `yaml:"codec"`
+	SchemaRegistry string `yaml:"schemaRegistry"`
+
+	// Compression is none or gzip.
+	Compression string `yaml:"compression"`
+}
+
+type eventStreamConfig struct {
+	Size        int    `yaml:"size"`
+	Payload     string `yaml:"payload"`
+	OffsetsPath string `yaml:"offsetsPath"`
+}
+
+// auditConfig is the audit log, see robot.Options.AuditLog.
+type auditConfig struct {
+	Path       string        `yaml:"path"`
+	MaxSize    int64         `yaml:"maxSize"`
+	MaxAge     time.Duration `yaml:"maxAge"`
+	MaxBackups int           `yaml:"maxBackups"`
+}
+
+// checkpointConfig is the file of checkpoints, see robot.Options.Checkpointer.
+type checkpointConfig struct {
+	Path     string        `yaml:"path"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+// shardingConfig splits clusters among instances, see robot.Sharding.
+type shardingConfig struct {
+	// ConfigPath is the kubeconfig of the cluster of Leases, in cluster if empty.
+	ConfigPath    string        `yaml:"configPath"`
+	Namespace     string        `yaml:"namespace"`
+	Group         string        `yaml:"group"`
+	Identity      string        `yaml:"identity"`
+	LeaseDuration time.Duration `yaml:"leaseDuration"`
+}
+
+type clusterConfig struct {
+	Name        string            `yaml:"name"`
+	ConfigPath  string            `yaml:"configPath"`
+	MasterURL   string            `yaml:"masterUrl"`
+	Context     string            `yaml:"context"`
+	BearerToken string            `yaml:"bearerToken"`
+	Labels      map[string]string `yaml:"labels"`
+	HotReload   bool              `yaml:"hotReload"`
+	Resources   []resourceConfig  `yaml:"resources"`
+}
+
+type resourceConfig struct {
+	// Resource is version/resource or group/version/resource, e.g. v1/pods.
+	Resource          string `yaml:"resource"`
+	Namespace         string `yaml:"namespace"`
+	NamespaceSelector string `yaml:"namespaceSelector"`
+}
+
+// loadConfig loads the config of the file.
+func loadConfig(path string) (*config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &config{}
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %v", path, err)
+	}
+	if len(c.Clusters) == 0 {
+		return nil, fmt.Errorf("invalid config %s: no cluster", path)
+	}
+	return c, nil
+}
+
+// options returns the options and clusters of the robot, and the event log
+// opened, which must be closed once the robot stops.
+func (c *config) options() (robot.Options, []robot.Cluster, io.Closer, error) {
+	opts := robot.Options{
+		DebugAddr:   c.DebugAddr,
+		GraphQLAddr: c.GraphQLAddr,
+		XDSAddr:     c.XDSAddr,
+		CostLabel:   c.CostLabel,
+		HistorySize: c.HistorySize,
+	}
+
+	if c.EventLog != nil {
+		var err error
+		if opts.EventLogPayload, err = parsePayload(c.EventLog.Payload); err != nil {
+			return opts, nil, nil, err
+		}
+		if opts.EventLogCodec, err = parseCodec(c.EventLog.Codec, c.EventLog.SchemaRegistry); err != nil {
+			return opts, nil, nil, err
+		}
+		if opts.EventLogCompression, err = parseCompression(c.EventLog.Compression); err != nil {
+			return opts, nil, nil, err
+		}
+	}
+	if c.EventStream != nil {
+		payload, err := parsePayload(c.EventStream.Payload)
+		if err != nil {
+			return opts, nil, nil, err
+		}
+		opts.EventStream = &robot.EventStreamOptions{
+			Size:        c.EventStream.Size,
+			Payload:     payload,
+			OffsetsPath: c.EventStream.OffsetsPath,
+		}
+	}
+	if c.Audit != nil {
+		opts.AuditLog = c.Audit.Path
+		opts.AuditLogMaxSize = c.Audit.MaxSize
+		opts.AuditLogMaxAge = c.Audit.MaxAge
+		opts.AuditLogMaxBackups = c.Audit.MaxBackups
+	}
+	if c.Checkpoint != nil {
+		checkpointer, err := robot.NewFileCheckpointer(c.Checkpoint.Path)
+		if err != nil {
+			return opts, nil, nil, err
+		}
+		opts.Checkpointer = checkpointer
+		opts.CheckpointInterval = c.Checkpoint.Interval
+	}
+	if c.Sharding != nil {
+		config, err := clientcmd.BuildConfigFromFlags("", c.Sharding.ConfigPath)
+		if err != nil {
+			return opts, nil, nil, fmt.Errorf("invalid sharding config: %v", err)
+		}
+		client, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return opts, nil, nil, fmt.Errorf("invalid sharding config: %v", err)
+		}
+		opts.Sharding = &robot.Sharding{
+			Client:        client,
+			Namespace:     c.Sharding.Namespace,
+			Group:         c.Sharding.Group,
+			Identity:      c.Sharding.Identity,
+			LeaseDuration: c.Sharding.LeaseDuration,
+		}
+	}
+
+	clusters := make([]robot.Cluster, 0, len(c.Clusters))
+	for _, one := range c.Clusters {
+		cluster := robot.Cluster{
+			Name:        one.Name,
+			ConfigPath:  one.ConfigPath,
+			MasterUrl:   one.MasterURL,
+			Context:     one.Context,
+			BearerToken: one.BearerToken,
+			Labels:      one.Labels,
+			HotReload:   one.HotReload,
+		}
+		for _, r := range one.Resources {
+			resource, err := robot.ParseResource(r.Resource)
+			if err != nil {
+				return opts, nil, nil, err
+			}
+			cluster.Resources = append(cluster.Resources, robot.RN{
+				RType:             resource,
+				Namespace:         r.Namespace,
+				NamespaceSelector: r.NamespaceSelector,
+				LogEvents:         c.EventLog != nil,
+			})
+		}
+		clusters = append(clusters, cluster)
+	}
+
+	// The event log is opened last, so nothing is left open on errors.
+	var closer io.Closer = nopCloser{}
+	if c.EventLog != nil {
+		switch c.EventLog.Path {
+		case "", "stdout":
+			opts.EventLog = os.Stdout
+		default:
+			f, err := os.OpenFile(c.EventLog.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+			if err != nil {
+				return opts, nil, nil, err
+			}
+			opts.EventLog, closer = f, f
+		}
+	}
+	return opts, clusters, closer, nil
+}
+
+// nopCloser closes nothing, e.g. stdout.
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+func parsePayload(s string) (robot.Payload, error) {
+	switch strings.ToLower(s) {
+	case "", "none":
+		return robot.PayloadNone, nil
+	case "object":
+		return robot.PayloadObject, nil
+	case "jsonpatch":
+		return robot.PayloadJSONPatch, nil
+	case "mergepatch":
+		return robot.PayloadMergePatch, nil
+	}
+	return 0, fmt.Errorf("invalid payload %s", s)
+}
+
+func parseCodec(s, registry string) (robot.Codec, error) {
+	switch strings.ToLower(s) {
+	case "", "json":
+		return robot.JSONCodec{}, nil
+	case "protobuf":
+		return robot.ProtobufCodec{Delimited: true}, nil
+	case "avro":
+		if registry == "" {
+			return nil, fmt.Errorf("schemaRegistry is required by the avro codec")
+		}
+		return &robot.AvroCodec{RegistryURL: registry}, nil
+	}
+	return nil, fmt.Errorf("invalid codec %s", s)
+}
+
+func parseCompression(s string) (robot.Compression, error) {
+	switch strings.ToLower(s) {
+	case "", "none":
+		return robot.CompressionNone, nil
+	case "gzip":
+		return robot.CompressionGzip, nil
+	}
+	return 0, fmt.Errorf("invalid compression %s", s)
+}
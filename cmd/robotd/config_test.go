@@ -0,0 +1,150 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitlab.mfwdev.com/servicemesh/robot"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "robotd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfig(t, `
+debugAddr: ":8080"
+eventLog:
+  path: stdout
+  payload: jsonpatch
+  codec: protobuf
+  compression: gzip
+eventStream:
+  size: 100
+clusters:
+- name: east
+  masterUrl: https://east.example.com
+  labels: {region: us-east}
+  resources:
+  - resource: v1/pods
+  - resource: apps/v1/deployments
+    namespace: default
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	c, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	opts, clusters, closer, err := c.options()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer closer.Close()
+
+	if e, a := ":8080", opts.DebugAddr; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if opts.EventLog != os.Stdout || opts.EventLogPayload != robot.PayloadJSONPatch || opts.EventLogCompression != robot.CompressionGzip {
+		t.Errorf("expected the event log of the config, got %+v", opts)
+	}
+	if _, ok := opts.EventLogCodec.(robot.ProtobufCodec); !ok {
+		t.Errorf("expected the protobuf codec, got %T", opts.EventLogCodec)
+	}
+	if opts.EventStream == nil || opts.EventStream.Size != 100 {
+		t.Errorf("expected the event stream of the config, got %v", opts.EventStream)
+	}
+
+	if e, a := 1, len(clusters); e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	resources := clusters[0].Resources
+	if e, a := 2, len(resources); e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	if resources[0].RType != robot.Pods || resources[1].RType != (robot.Resource{Group: "apps", Version: "v1", Resource: "deployments"}) || resources[1].Namespace != "default" || !resources[1].LogEvents {
+		t.Errorf("expected the resources of the config, got %v", resources)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	for _, content := range []string{
+		"clusters: []",
+		"unknown: 1\nclusters: [{name: east}]",
+	} {
+		path := writeConfig(t, content)
+		if _, err := loadConfig(path); err == nil {
+			t.Errorf("expected an error of %q", content)
+		}
+		os.RemoveAll(filepath.Dir(path))
+	}
+
+	for _, content := range []string{
+		"eventLog: {codec: avro}\nclusters: [{name: east}]",
+		"eventLog: {payload: all}\nclusters: [{name: east}]",
+		"clusters: [{name: east, resources: [{resource: pods}]}]",
+	} {
+		path := writeConfig(t, content)
+		c, err := loadConfig(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, _, _, err := c.options(); err == nil {
+			t.Errorf("expected an error of %q", content)
+		}
+		os.RemoveAll(filepath.Dir(path))
+	}
+}
+
+func TestLoadConfigSinks(t *testing.T) {
+	path := writeConfig(t, `
+audit:
+  path: /var/log/robot/audit.log
+  maxAge: 24h
+  maxBackups: 5
+checkpoint:
+  interval: 30s
+clusters:
+- name: east
+`)
+	dir := filepath.Dir(path)
+	defer os.RemoveAll(dir)
+
+	c, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.Checkpoint.Path = filepath.Join(dir, "checkpoint.json")
+	opts, _, closer, err := c.options()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	closer.Close()
+	if opts.AuditLog != "/var/log/robot/audit.log" || opts.AuditLogMaxAge != 24*time.Hour || opts.AuditLogMaxBackups != 5 {
+		t.Errorf("expected the audit log of the config, got %+v", opts)
+	}
+	if opts.Checkpointer == nil || opts.CheckpointInterval != 30*time.Second {
+		t.Errorf("expected the checkpoints of the config, got %+v", opts)
+	}
+
+	// The event log isn't left open by errors.
+	c.EventLog = &eventLogConfig{Path: filepath.Join(dir, "events.log")}
+	c.EventStream = &eventStreamConfig{Payload: "all"}
+	if _, _, _, err := c.options(); err == nil {
+		t.Errorf("expected an error of the event stream")
+	}
+	if _, err := os.Stat(c.EventLog.Path); !os.IsNotExist(err) {
+		t.Errorf("expected the event log not opened, got %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+// Command robotd runs a robot watching the clusters of a YAML config, serving
+// its debug server with expvar metrics, its GraphQL and xDS servers, and
+// writing events to the sinks of the config, so the robot is deployed without
+// Go code. It has no gRPC server, as the robot serves none.
+//
+//	robotd -config /etc/robot/config.yaml
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"k8s.io/klog"
+
+	"gitlab.mfwdev.com/servicemesh/robot"
+)
+
+// drainTimeout bounds processing an event of the queue, which the daemon
+// only drains, as events go to the sinks.
+const drainTimeout = time.Minute
+
+func main() {
+	klog.InitFlags(nil)
+	path := flag.String("config", "/etc/robot/config.yaml", "path of the YAML config")
+	flag.Parse()
+
+	if err := run(*path); err != nil {
+		klog.Error(err)
+		klog.Flush()
+		os.Exit(1)
+	}
+	klog.Flush()
+}
+
+// run runs the robot of the config until it's stopped by a signal.
+func run(path string) error {
+	c, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+	opts, clusters, eventLog, err := c.options()
+	if err != nil {
+		return err
+	}
+	defer eventLog.Close()
+
+	r, err := robot.NewRobotWithOptions(opts, clusters...)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for err := range r.Errors() {
+			klog.Error(err)
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		klog.Infof("robotd: received %s, stopping", sig)
+		r.Stop()
+	}()
+
+	// Events go to the sinks, the queue is only drained.
+	go r.Process(func(context.Context, robot.QueueObject) error {
+		return nil
+	}, robot.PoolOptions{Name: "robotd", Timeout: drainTimeout})
+
+	klog.Infof("robotd: watching %d clusters", len(clusters))
+	return r.Run()
+}
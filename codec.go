@@ -0,0 +1,240 @@
+package robot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Codec encodes events written to sinks, e.g. Options.EventLog.
+type Codec interface {
+	// Encode returns the event as a message of the wire format.
+	Encode(e *EventRecord) ([]byte, error)
+}
+
+// JSONCodec encodes events as JSON lines, ended by a newline.
+type JSONCodec struct{}
+
+func (JSONCodec) Encode(e *EventRecord) ([]byte, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// ProtobufCodec encodes events as protobuf messages of:
+//
+//	message Event {
+//	  string severity = 1;
+//	  int64 time_unix_nano = 2;
+//	  string message = 3;
+//	  string cluster = 4;
+//	  string resource = 5;
+//	  string event = 6;
+//	  string key = 7;
+//	  map<string, string> labels = 8;
+//	  bytes object = 9; // JSON
+//	  bytes patch = 10; // JSON
+//	}
+type ProtobufCodec struct {
+	// Delimited prefixes each message with its size as a varint, as
+	// writeDelimitedTo of protobuf libraries, for streams of messages.
+	Delimited bool
+}
+
+func (c ProtobufCodec) Encode(e *EventRecord) ([]byte, error) {
+	var b protoBuffer
+	b.string(1, e.Severity)
+	if !e.Time.IsZero() {
+		b.varint(2, uint64(e.Time.UnixNano()))
+	}
+	b.string(3, e.Message)
+	b.string(4, e.Cluster)
+	b.string(5, e.Resource)
+	b.string(6, e.Event)
+	b.string(7, e.Key)
+	for _, k := range labelKeys(e.Labels) {
+		var entry protoBuffer
+		entry.string(1, k)
+		entry.string(2, e.Labels[k])
+		b.bytes(8, entry.Bytes())
+	}
+	b.bytes(9, e.Object)
+	b.bytes(10, e.Patch)
+
+	if !c.Delimited {
+		return b.Bytes(), nil
+	}
+	var message protoBuffer
+	message.uvarint(uint64(b.Len()))
+	message.Write(b.Bytes())
+	return message.Bytes(), nil
+}
+
+// protoBuffer encodes fields of protobuf messages, fields of zero values are
+// omitted as of proto3.
+type protoBuffer struct {
+	bytes.Buffer
+}
+
+func (b *protoBuffer) uvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	b.Write(buf[:binary.PutUvarint(buf[:], v)])
+}
+
+func (b *protoBuffer) varint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	b.uvarint(uint64(field)<<3 | 0)
+	b.uvarint(v)
+}
+
+func (b *protoBuffer) bytes(field int, v []byte) {
+	if len(v) == 0 {
+		return
+	}
+	b.uvarint(uint64(field)<<3 | 2)
+	b.uvarint(uint64(len(v)))
+	b.Write(v)
+}
+
+func (b *protoBuffer) string(field int, v string) {
+	b.bytes(field, []byte(v))
+}
+
+// avroSchema is the Avro schema of events, objects and patches are JSON.
+const avroSchema = `{"type":"record","name":"Event","namespace":"robot","fields":[` +
+	`{"name":"severity","type":"string"},` +
+	`{"name":"time","type":{"type":"long","logicalType":"timestamp-micros"}},` +
+	`{"name":"message","type":"string"},` +
+	`{"name":"cluster","type":"string"},` +
+	`{"name":"resource","type":"string"},` +
+	`{"name":"event","type":"string"},` +
+	`{"name":"key","type":"string"},` +
+	`{"name":"labels","type":{"type":"map","values":"string"}},` +
+	`{"name":"object","type":["null","string"],"default":null},` +
+	`{"name":"patch","type":["null","string"],"default":null}]}`
+
+// AvroCodec encodes events as Avro binary of the schema registered in a
+// Confluent schema registry, prefixed by the id of the schema in its wire
+// format. The schema is registered by the first event.
+type AvroCodec struct {
+	// RegistryURL is the URL of the schema registry, e.g. "http://localhost:8081".
+	RegistryURL string
+
+	// Subject is the subject the schema is registered under, "robot-events-value" if empty.
+	Subject string
+
+	// Client is the client of the schema registry, http.DefaultClient if nil.
+	Client *http.Client
+
+	mu sync.Mutex
+	id int32
+}
+
+func (c *AvroCodec) Encode(e *EventRecord) ([]byte, error) {
+	id, err := c.schemaID()
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	b.WriteByte(0)
+	binary.Write(&b, binary.BigEndian, id)
+	avroString(&b, e.Severity)
+	avroLong(&b, e.Time.UnixNano()/1000)
+	for _, s := range []string{e.Message, e.Cluster, e.Resource, e.Event, e.Key} {
+		avroString(&b, s)
+	}
+	if len(e.Labels) > 0 {
+		avroLong(&b, int64(len(e.Labels)))
+		for _, k := range labelKeys(e.Labels) {
+			avroString(&b, k)
+			avroString(&b, e.Labels[k])
+		}
+	}
+	avroLong(&b, 0)
+	for _, v := range []json.RawMessage{e.Object, e.Patch} {
+		if len(v) == 0 {
+			avroLong(&b, 0)
+			continue
+		}
+		avroLong(&b, 1)
+		avroString(&b, string(v))
+	}
+	return b.Bytes(), nil
+}
+
+// schemaID registers the schema once, and returns its id.
+func (c *AvroCodec) schemaID() (int32, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.id != 0 {
+		return c.id, nil
+	}
+
+	subject := c.Subject
+	if subject == "" {
+		subject = "robot-events-value"
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	body, err := json.Marshal(map[string]string{"schema": avroSchema})
+	if err != nil {
+		return 0, err
+	}
+	url := strings.TrimSuffix(c.RegistryURL, "/") + "/subjects/" + subject + "/versions"
+	resp, err := client.Post(url, "application/vnd.schemaregistry.v1+json", bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("robot: register schema %s: %v", subject, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return 0, fmt.Errorf("robot: register schema %s: %s: %s", subject, resp.Status, bytes.TrimSpace(body))
+	}
+	var result struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("robot: register schema %s: %v", subject, err)
+	}
+	if result.ID <= 0 || result.ID > math.MaxInt32 {
+		return 0, fmt.Errorf("robot: register schema %s: invalid id %d", subject, result.ID)
+	}
+	c.id = int32(result.ID)
+	return c.id, nil
+}
+
+// avroLong writes v zig-zag encoded as a varint.
+func avroLong(b *bytes.Buffer, v int64) {
+	var buf [binary.MaxVarintLen64]byte
+	b.Write(buf[:binary.PutVarint(buf[:], v)])
+}
+
+// avroString writes the length of s and s.
+func avroString(b *bytes.Buffer, s string) {
+	avroLong(b, int64(len(s)))
+	b.WriteString(s)
+}
+
+// labelKeys returns the keys of labels sorted, so encoding is deterministic.
+func labelKeys(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,125 @@
+package robot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRecord() *EventRecord {
+	return &EventRecord{
+		Severity: "INFO",
+		Time:     time.Unix(1, 0),
+		Message:  "add pods default/web in cluster east",
+		Cluster:  "east",
+		Resource: "pods",
+		Event:    "add",
+		Key:      "default/web",
+		Labels:   map[string]string{"region": "us"},
+		Object:   json.RawMessage(`{"a":1}`),
+	}
+}
+
+func TestJSONCodec(t *testing.T) {
+	data, err := JSONCodec{}.Encode(testRecord())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.HasSuffix(data, []byte("\n")) {
+		t.Errorf("expected a line, got %q", data)
+	}
+	var e EventRecord
+	if err := json.Unmarshal(data, &e); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e.Key != "default/web" {
+		t.Errorf("expected %v, got %v", "default/web", e.Key)
+	}
+}
+
+func TestProtobufCodec(t *testing.T) {
+	e := &EventRecord{Severity: "INFO", Key: "a", Labels: map[string]string{"k": "v"}}
+	data, err := ProtobufCodec{}.Encode(e)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// severity = 1, key = 7, labels = 8 of an entry of key = 1 and value = 2.
+	expected := []byte{0x0a, 4, 'I', 'N', 'F', 'O', 0x3a, 1, 'a', 0x42, 6, 0x0a, 1, 'k', 0x12, 1, 'v'}
+	if !bytes.Equal(expected, data) {
+		t.Errorf("expected %x, got %x", expected, data)
+	}
+
+	delimited, err := ProtobufCodec{Delimited: true}.Encode(e)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(append([]byte{byte(len(expected))}, expected...), delimited) {
+		t.Errorf("expected %x delimited, got %x", expected, delimited)
+	}
+}
+
+func TestAvroCodec(t *testing.T) {
+	registered := 0
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/subjects/events-value/versions" {
+			http.NotFound(w, req)
+			return
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+		if !strings.Contains(string(body), `\"name\":\"Event\"`) {
+			http.Error(w, "invalid schema", http.StatusUnprocessableEntity)
+			return
+		}
+		registered++
+		w.Write([]byte(`{"id":7}`))
+	}))
+	defer registry.Close()
+
+	c := &AvroCodec{RegistryURL: registry.URL, Subject: "events-value"}
+	e := testRecord()
+	e.Labels = nil
+	e.Object = nil
+	var data []byte
+	for i := 0; i < 2; i++ {
+		var err error
+		if data, err = c.Encode(e); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if registered != 1 {
+		t.Errorf("expected the schema registered once, got %d", registered)
+	}
+
+	if data[0] != 0 || binary.BigEndian.Uint32(data[1:5]) != 7 {
+		t.Errorf("expected the wire format of schema 7, got %x", data[:5])
+	}
+	var expected bytes.Buffer
+	avroString(&expected, "INFO")
+	avroLong(&expected, 1000000)
+	for _, s := range []string{e.Message, "east", "pods", "add", "default/web"} {
+		avroString(&expected, s)
+	}
+	// No labels, object nor patch.
+	expected.Write([]byte{0, 0, 0})
+	if !bytes.Equal(expected.Bytes(), data[5:]) {
+		t.Errorf("expected %x, got %x", expected.Bytes(), data[5:])
+	}
+}
+
+func TestAvroCodecRegistryError(t *testing.T) {
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "down", http.StatusInternalServerError)
+	}))
+	defer registry.Close()
+
+	c := &AvroCodec{RegistryURL: registry.URL}
+	if _, err := c.Encode(testRecord()); err == nil {
+		t.Errorf("expected an error when the schema can't be registered")
+	}
+}
@@ -0,0 +1,142 @@
+package robot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Compression is how payloads written to sinks are compressed. Only gzip is
+// supported, zstd would need a dependency out of the standard library.
+type Compression int
+
+const (
+	// CompressionNone doesn't compress.
+	CompressionNone Compression = iota
+
+	// CompressionGzip compresses each payload as a gzip member, so that
+	// payloads concatenated are a gzip stream too, e.g. of an event log file.
+	CompressionGzip
+)
+
+func (c Compression) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionGzip:
+		return "gzip"
+	}
+	return fmt.Sprintf("Compression(%d)", int(c))
+}
+
+// compress returns data compressed.
+func (c Compression) compress(data []byte) ([]byte, error) {
+	switch c {
+	case CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		var b bytes.Buffer
+		w := gzip.NewWriter(&b)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+	return nil, fmt.Errorf("robot: unknown compression %s", c)
+}
+
+// acceptsGzip reports whether the Accept-Encoding header of a request allows gzip.
+func acceptsGzip(header string) bool {
+	for _, one := range strings.Split(header, ",") {
+		parts := strings.Split(one, ";")
+		coding := strings.ToLower(strings.TrimSpace(parts[0]))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, _ = strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+			}
+		}
+		return q > 0
+	}
+	return false
+}
+
+// gzipHandler compresses responses of h by gzip for requests accepting it
+// by Accept-Encoding, e.g. large exports of cached objects.
+func gzipHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if req.Method == http.MethodHead || !acceptsGzip(req.Header.Get("Accept-Encoding")) {
+			h.ServeHTTP(w, req)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.close()
+		h.ServeHTTP(gw, req)
+	})
+}
+
+// gzipResponseWriter compresses the body of a response, responses without
+// one, e.g. 304 Not Modified, are written as is.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+
+	w           *gzip.Writer
+	wroteHeader bool
+}
+
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	// The ETag of the response is made weak, as the compressed body isn't the
+	// same bytes, so caches don't mix them up.
+	if etag := w.Header().Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		w.Header().Set("ETag", "W/"+etag)
+	}
+	if code != http.StatusNotModified && code != http.StatusNoContent && code >= http.StatusOK {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Del("Content-Length")
+		w.w = gzip.NewWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipResponseWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.w == nil {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.w.Write(data)
+}
+
+// Flush flushes what's compressed so far to the client, for streamed responses.
+func (w *gzipResponseWriter) Flush() {
+	if w.w != nil {
+		w.w.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// close flushes the compressed body.
+func (w *gzipResponseWriter) close() {
+	if w.w != nil {
+		w.w.Close()
+	}
+}
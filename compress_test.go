@@ -0,0 +1,98 @@
+package robot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCompressGzip(t *testing.T) {
+	var stream []byte
+	for _, s := range []string{"one\n", "two\n"} {
+		data, err := CompressionGzip.compress([]byte(s))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		stream = append(stream, data...)
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := "one\ntwo\n", string(data); e != a {
+		t.Errorf("expected %q, got %q", e, a)
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	for header, expected := range map[string]bool{
+		"":                  false,
+		"gzip":              true,
+		"deflate, gzip;q=1": true,
+		"gzip;q=0":          false,
+		"br, *":             true,
+		"identity":          false,
+	} {
+		if a := acceptsGzip(header); a != expected {
+			t.Errorf("expected %v of %q, got %v", expected, header, a)
+		}
+	}
+}
+
+func TestGzipHandler(t *testing.T) {
+	h := gzipHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/cached" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"abc"`)
+		w.Write([]byte("objects"))
+		w.(http.Flusher).Flush()
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if e, a := "gzip", w.Header().Get("Content-Encoding"); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := `W/"abc"`, w.Header().Get("ETag"); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if !w.Flushed {
+		t.Errorf("expected the response flushed")
+	}
+	r, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, _ := ioutil.ReadAll(r); string(data) != "objects" {
+		t.Errorf("expected %v, got %s", "objects", data)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/cached", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified || w.Header().Get("Content-Encoding") != "" || w.Body.Len() != 0 {
+		t.Errorf("expected an empty 304 response, got %d %v %q", w.Code, w.Header(), w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != "objects" {
+		t.Errorf("expected an uncompressed response, got %v %q", w.Header(), w.Body)
+	}
+	if e, a := `"abc"`, w.Header().Get("ETag"); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
@@ -1,18 +1,27 @@
 package robot
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 
-	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
 
 	"k8s.io/client-go/tools/cache"
-	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog"
 )
 
 // Robot is an interface for monitor k8s multi-cluster resources.
@@ -23,22 +32,160 @@ type Robot interface {
 
 	// Run start up the robot.
 	// Start monitoring resources and sending events to the queue.
-	Run()
+	// It blocks until Stop is called, and return an error if the robot
+	// has been run already or there is no resource to discover.
+	Run() error
 
 	// Stop stop monitoring resources
 	// Empty queue and recycle
+	// It is safe to call Stop multiple times.
 	Stop()
 
+	// Activate starts sending events of a robot in warm standby mode,
+	// see Options.WarmStandby. Changes before are not sent.
+	Activate()
+
+	// WaitForSync blocks until the caches of the given resources have synced,
+	// all resources are waited if none is given.
+	// It returns an error if ctx is done before that, or an informer has crashed.
+	WaitForSync(ctx context.Context, resources ...Resource) error
+
+	// WatchObject watches exactly one object of the cluster, and delivers its events
+	// on the returned channel, which is closed when ctx is done or the robot stops.
+	// Events must be received timely, or the watch is blocked.
+	WatchObject(ctx context.Context, cluster string, resource Resource, namespace, name string) (<-chan ObjectEvent, error)
+
+	// WaitForObject blocks until the object of the key of the resource is in the
+	// store of the cluster, and the predicate is true of it if not nil, e.g.
+	// ConditionTrue("Available") of a Deployment, and returns the object.
+	// It returns an error if ctx is done before that.
+	WaitForObject(ctx context.Context, cluster string, resource Resource, key string, predicate Predicate) (interface{}, error)
+
+	// Resync replays the cached objects of the resource in the clusters as
+	// EventUpdate events, so consumers can run a full reconciliation.
+	// They only go to consumers, e.g. not to History or the inventory.
+	// All clusters are replayed if none is given.
+	Resync(resource Resource, clusters ...string) error
+
+	// History returns the last versions of the object of the resource in the
+	// cluster from the oldest, see Options.HistorySize.
+	History(cluster string, resource Resource, key string) ([]ObjectVersion, error)
+
+	// Status returns the status of each cluster.
+	Status() []ClusterStatus
+
+	// Client returns the client of the cluster, nil if it's not found.
+	// The client is replaced when the kubeconfig of the cluster is reloaded,
+	// so it should be got again rather than kept.
+	Client(cluster string) kubernetes.Interface
+
+	// Apply creates the object of the resource in the cluster, or updates it
+	// to obj if it exists, retrying on conflicts with concurrent writers.
+	Apply(cluster string, resource Resource, obj runtime.Object, opts WriteOptions) (*unstructured.Unstructured, error)
+
+	// Patch patches the object of the resource in the cluster.
+	Patch(cluster string, resource Resource, namespace, name string, pt types.PatchType, data []byte, opts WriteOptions) (*unstructured.Unstructured, error)
+
+	// Delete deletes the object of the resource in the cluster,
+	// an object not found is deleted already.
+	Delete(cluster string, resource Resource, namespace, name string, opts WriteOptions) error
+
+	// Process processes objects of the queue with fn by a pool of workers
+	// scaled by the depth of the queue and latency, see PoolOptions.
+	// It blocks until the robot stops.
+	Process(fn ProcessFunc, opts PoolOptions)
+
+	// Workers returns the number of workers of Process.
+	Workers() int
+
+	// ImageLocations returns the pods of all clusters running the image,
+	// e.g. nginx:1.19, or the digest of an image, e.g. sha256:4f4e.
+	ImageLocations(image string) []ImageLocation
+
+	// Costs returns the sums of requests of pods of each value of the label in
+	// each cluster, see Options.CostLabel.
+	Costs() []CostTotal
+
+	// Query runs a SQL query against the inventory, see Options.Inventory.
+	Query(query string, args ...interface{}) (*QueryResult, error)
+
+	// View returns a read-only view of the queue and store of the robot
+	// restricted to the objects of the filter, e.g. of a cluster subset,
+	// namespaces or labels, so components of an application consume isolated
+	// slices of the same informers.
+	View(filter ViewFilter) (View, error)
+
+	// CommitOffset commits the sequence number of the last event of the event
+	// stream processed by the consumer, which resumes after it, see
+	// Options.EventStream.
+	CommitOffset(consumer string, seq uint64) error
+
+	// Offset returns the offset committed by the consumer, zero if none.
+	Offset(consumer string) uint64
+
+	// Errors return a channel of errors which occurred while monitoring,
+	// e.g. a panic recovered from an event handler or an informer.
+	Errors() <-chan error
+
 	queue
 
 	store
 }
 
 type controller struct {
-	clients   []*kubernetes.Clientset
-	informers informerSet
+	opts     Options
+	clusters []*member
+
+	audit *auditLog
+
+	// events is the event log, nil if disabled.
+	events *eventLog
+
+	// modified is the time of the last event of each resource.
+	modified *modifiedTimes
+
+	// inventory mirrors cached objects into a database, nil if disabled.
+	inventory *inventory
+
+	// images are the images seen in pods, see Options.OnNewImage.
+	images *imageSet
+
+	// xds renders Services for the xDS server, nil if disabled.
+	xds *xdsCache
 
-	stop chan struct{}
+	// validators validate objects of resources by Options.Schemas.
+	validators map[Resource]*validator
+
+	// policy evaluates objects by Options.Policy, nil if disabled.
+	policy *policyEvaluator
+
+	// costs sums requests of pods by Options.CostLabel, nil if disabled.
+	costs *costAggregator
+
+	// replicas consolidates events of replicated objects, nil if disabled.
+	replicas *replicaTracker
+
+	// stream numbers events for external consumers, nil if disabled.
+	stream *eventStream
+
+	// views are views of the robot, see View.
+	views *viewSet
+
+	// waiters are waiting for objects by WaitForObject.
+	waiters *storeWaiters
+
+	mu       sync.Mutex
+	running  bool
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	errs chan error
+
+	// workers is the number of workers of Process, accessed atomically.
+	workers int32
+
+	// standby is set until Activate in warm standby mode, accessed atomically.
+	standby int32
 
 	queue
 
@@ -48,132 +195,556 @@ type controller struct {
 var _ Robot = &controller{}
 
 func NewRobot(clusters ...Cluster) (Robot, error) {
+	return NewRobotWithOptions(Options{}, clusters...)
+}
+
+// NewRobotFromKubeconfigBytes creates a robot of the clusters of kubeconfig contents,
+// each of them watches the resources.
+func NewRobotFromKubeconfigBytes(configs [][]byte, resources ...RN) (Robot, error) {
+	clusters := make([]Cluster, 0, len(configs))
+	for _, config := range configs {
+		clusters = append(clusters, Cluster{Kubeconfig: config, Resources: resources})
+	}
+	return NewRobot(clusters...)
+}
+
+func NewRobotWithOptions(opts Options, clusters ...Cluster) (Robot, error) {
 	core := &controller{
-		queue:   newWorkQueue(),
-		stop:    make(chan struct{}, 1),
+		opts:     opts,
+		queue:    newWorkQueue(),
+		stop:     make(chan struct{}),
+		errs:     make(chan error, 100),
+		modified: newModifiedTimes(),
+		images:   newImageSet(),
+		waiters:  newStoreWaiters(),
+		views:    newViewSet(),
+	}
+
+	if len(opts.Versions) > 0 && opts.Converter == nil {
+		return nil, errors.New("robot: Converter is required with Versions")
+	}
+
+	if opts.WarmStandby {
+		core.standby = 1
+	}
+	if opts.XDSAddr != "" {
+		core.xds = newXDSCache()
+	}
+	if opts.Replicas != nil {
+		if sameFunc(opts.KeyFunc, ClusterKey) || sameFunc(opts.KeyFunc, UIDKey) {
+			return nil, errors.New("robot: Replicas needs keys of replicas to be the same in clusters, not by ClusterKey or UIDKey")
+		}
+		core.replicas = newReplicaTracker(*opts.Replicas)
+	}
+	if opts.CostLabel != "" {
+		core.costs = newCostAggregator(opts.CostLabel)
+	}
+	if opts.Schemas != nil {
+		validators, err := newValidators(opts.Schemas)
+		if err != nil {
+			return nil, err
+		}
+		core.validators = validators
+	}
+	if opts.Policy != nil {
+		if opts.Policy.URL == "" || opts.Policy.Path == "" {
+			return nil, errors.New("robot: policy URL and Path are required")
+		}
+		core.policy = newPolicyEvaluator(*opts.Policy)
+	}
+
+	if opts.Probe != nil {
+		if _, err := labels.Parse(opts.Probe.Selector); err != nil {
+			return nil, fmt.Errorf("robot: invalid probe selector: %v", err)
+		}
+	}
+	if opts.DNS != nil {
+		if err := opts.DNS.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.AuditLog != "" {
+		audit, err := newAuditLog(opts)
+		if err != nil {
+			return nil, err
+		}
+		core.audit = audit
+	}
+	if opts.EventStream != nil {
+		stream, err := newEventStream(*opts.EventStream)
+		if err != nil {
+			return nil, err
+		}
+		core.stream = stream
+	}
+	if opts.EventLog != nil {
+		core.events = newEventLog(opts.EventLog, opts.EventLogPayload, opts.EventLogCodec, opts.EventLogCompression)
 	}
 
 	store := make(mapIndexerSet)
-	informers := make(informerSet, 0)
 
+	names := make(map[string]bool)
 	for _, c := range clusters {
+		if c.Name == "" {
+			c.Name = c.deriveName()
+		}
+		if names[c.String()] {
+			return nil, fmt.Errorf("robot: duplicate cluster %s, set Name to tell them apart", &c)
+		}
+		names[c.String()] = true
+
+		c.clock = newClockSkew()
 		client, err := c.newClient()
 		if err != nil {
 			return nil, err
 		}
+
+		if errs := validation.ValidateLabels(c.Labels, field.NewPath("labels")); len(errs) > 0 {
+			return nil, fmt.Errorf("robot: cluster %s: %v", &c, errs.ToAggregate())
+		}
+
+		m := &member{
+			Cluster:              c,
+			limiter:              newLimiter(c.RateLimit, core.stop),
+			limiters:             make(map[RN]*limiter),
+			streamingList:        opts.StreamingList,
+			syncNamespaceDeletes: opts.NamespaceDeletes,
+			preflightAccess:      opts.PreflightAccess,
+			keyFunc:              opts.KeyFunc,
+			dependsOn:            opts.DependsOn,
+			handlers:             opts.Handlers,
+			versions:             opts.Versions,
+			converter:            opts.Converter,
+			unstructured:         opts.Unstructured,
+			scheme:               opts.Scheme,
+			onSnapshot:           opts.OnInitialSnapshot,
+		}
+		m.breaker = newBreaker(opts.CircuitBreaker, core.tripper(m))
+		if err := core.loadCheckpoints(m); err != nil {
+			return nil, err
+		}
 		for _, r := range c.Resources {
-			indexer, informer := r.createIndexInformer(client, core.queue)
+			if err := r.RType.Validate(); err != nil {
+				return nil, err
+			}
+			m.limiters[r] = newLimiter(r.RateLimit, core.stop)
+
+			indexer := m.newIndexer()
 
 			store[r.RType] = append(store[r.RType], indexer)
-			informers = append(informers, informer)
+			m.indexers = append(m.indexers, indexer)
 		}
+		resources := make([]Resource, 0, len(c.Resources))
+		for _, r := range c.Resources {
+			resources = append(resources, r.RType)
+		}
+		if err := checkDependencies(opts.DependsOn, resources); err != nil {
+			return nil, err
+		}
+		m.observed = newEventCounter()
+		m.latency = newLatencyRecorder(opts.LatencySLO)
+		m.history = newHistory(opts.HistorySize)
+		m.build(client, core.emitter(m), core.report)
+
+		core.clusters = append(core.clusters, m)
 	}
 
-	core.informers = informers
 	core.store = store
 
+	if opts.Drift != nil && opts.Drift.Source != "" && !names[opts.Drift.Source] {
+		return nil, fmt.Errorf("robot: drift source cluster %s not found", opts.Drift.Source)
+	}
+
+	if opts.Inventory != nil {
+		resources := make([]Resource, 0, len(store))
+		for r := range store {
+			resources = append(resources, r)
+		}
+		inventory, err := newInventory(opts.Inventory, resources, core.report)
+		if err != nil {
+			return nil, err
+		}
+		core.inventory = inventory
+	}
+
+	if err := core.linkStandbys(); err != nil {
+		return nil, err
+	}
+
+	core.checkVersionSkew()
+
 	return core, nil
 }
 
-func initHandle(resource Resource, worker queue) cache.ResourceEventHandlerFuncs {
+// emitFunc sends an event of obj to consumers.
+type emitFunc func(item QueueObject, obj interface{})
+
+func initHandle(resource Resource, keyFunc cache.KeyFunc, emit emitFunc, report func(error)) cache.ResourceEventHandlerFuncs {
 	handler := cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
-			key, err := cache.MetaNamespaceKeyFunc(obj)
+			defer handleCrash(report, "%s add handler", resource)
+
+			key, err := keyFunc(obj)
 			if err == nil {
-				worker.push(QueueObject{EventAdd, resource, key, time.Now()})
+				emit(QueueObject{Event: EventAdd, RType: resource, Key: key, CreateAt: time.Now()}, obj)
 			}
 		},
 		UpdateFunc: func(old interface{}, new interface{}) {
-			key, err := cache.MetaNamespaceKeyFunc(new)
-			bAdd := true
-			if err == nil {
-				if resource == Endpoints {
-					oldE := old.(*v1.Endpoints)
-					curE := new.(*v1.Endpoints)
-					if !reflect.DeepEqual(oldE.Subsets, curE.Subsets) {
-						worker.push(QueueObject{EventUpdate, resource, key, time.Now()})
-					}
-				} else {
-					worker.push(QueueObject{EventUpdate, resource, key, time.Now()})
-				}
+			defer handleCrash(report, "%s update handler", resource)
+
+			key, err := keyFunc(new)
+			if err == nil && changed(resource, old, new) {
+				emit(QueueObject{Event: EventUpdate, RType: resource, Key: key, CreateAt: time.Now()}, new)
 			}
 		},
 		DeleteFunc: func(obj interface{}) {
-			// IndexerInformer uses a delta queue, therefore for deletes we have to use this
-			// key function.
-			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			defer handleCrash(report, "%s delete handler", resource)
+
+			// IndexerInformer uses a delta queue, therefore for deletes keyFunc
+			// must handle cache.DeletedFinalStateUnknown.
+			key, err := keyFunc(obj)
 			if err == nil {
-				worker.push(QueueObject{EventDelete, resource, key, time.Now()})
+				emit(QueueObject{Event: EventDelete, RType: resource, Key: key, CreateAt: time.Now()}, obj)
 			}
 		},
 	}
 	return handler
 }
 
-func (c *controller) Run() {
-	defer c.queue.close()
+// changed reports whether an update of the resource is worth an event.
+// Updates of Endpoints are compared by subsets, of Services by metadata labels
+// and annotations, spec and status, and of ConfigMaps by labels, annotations
+// and data, other resources are compared by resourceVersion only.
+// Objects of unexpected types, e.g. cache.DeletedFinalStateUnknown tombstones,
+// are always considered changed so that no event gets lost.
+func changed(resource Resource, old, new interface{}) bool {
+	oldMeta, err := meta.Accessor(old)
+	if err != nil {
+		return true
+	}
+	curMeta, err := meta.Accessor(new)
+	if err != nil {
+		return true
+	}
+	// Periodic resync will send update events for all known objects.
+	if oldMeta.GetResourceVersion() != "" && oldMeta.GetResourceVersion() == curMeta.GetResourceVersion() {
+		return false
+	}
+
+	switch resource {
+	case Services:
+		oldS, ok1 := old.(*v1.Service)
+		curS, ok2 := new.(*v1.Service)
+		if ok1 && ok2 {
+			return !reflect.DeepEqual(oldS.Labels, curS.Labels) ||
+				!reflect.DeepEqual(oldS.Annotations, curS.Annotations) ||
+				!reflect.DeepEqual(oldS.Spec, curS.Spec) ||
+				!reflect.DeepEqual(oldS.Status, curS.Status)
+		}
+	case Endpoints:
+		oldE, ok1 := old.(*v1.Endpoints)
+		curE, ok2 := new.(*v1.Endpoints)
+		if ok1 && ok2 {
+			return !reflect.DeepEqual(oldE.Subsets, curE.Subsets)
+		}
+	case ConfigMaps:
+		oldC, ok1 := old.(*v1.ConfigMap)
+		curC, ok2 := new.(*v1.ConfigMap)
+		if ok1 && ok2 {
+			return !reflect.DeepEqual(oldC.Labels, curC.Labels) ||
+				!reflect.DeepEqual(oldC.Annotations, curC.Annotations) ||
+				!reflect.DeepEqual(oldC.Data, curC.Data) ||
+				!reflect.DeepEqual(oldC.BinaryData, curC.BinaryData)
+		}
+	}
+	return true
+}
 
-	c.informers.run(c.stop)
+// emitter returns the function which sends events of the member to consumers.
+func (c *controller) emitter(m *member) func(RN) emitFunc {
+	return func(r RN) emitFunc {
+		return c.emit(m, r)
+	}
+}
 
-	<-c.stop
+func (c *controller) emit(m *member, r RN) emitFunc {
+	return c.newEmit(m, r, false)
 }
 
-func (c *controller) Stop() {
-	c.stop <- struct{}{}
+// replay returns the function which sends events of cached objects unchanged,
+// e.g. replayed by Resync, or synthetic ones like EventOrphan and
+// EventReachability. They skip the bookkeeping of changes, e.g. history,
+// inventory, xDS and replicas, and only go to consumers.
+func (c *controller) replay(m *member, r RN) emitFunc {
+	return c.newEmit(m, r, true)
 }
 
-type informerSet []cache.Controller
+func (c *controller) newEmit(m *member, r RN, replay bool) emitFunc {
+	dryRun := m.DryRun || r.DryRun
+	checkpoint := m.checkpoints[r]
+	progress := m.progress[r]
+
+	var deliver emitFunc = func(item QueueObject, obj interface{}) {
+		// Only events delivered are waited for, events dropped never finish.
+		progress.delivered(item, obj)
+		if c.audit != nil {
+			if err := c.audit.write(item, obj); err != nil {
+				c.report(err)
+			}
+		}
+
+		c.queue.push(item)
+		c.views.push(item, obj)
+		if err := c.stream.append(item, obj); err != nil {
+			c.report(err)
+		}
+		metrics.Add(metricEmitted, 1)
+	}
+	// limits of the resource first, then the cluster
+	deliver = m.limiters[r].wrap(m.limiter.wrap(deliver))
 
-func (s informerSet) run(done chan struct{}) {
-	for _, one := range s {
+	clusterLabels := labels.Set(m.Labels).String()
 
-		go one.Run(done)
+	// updates counts updates for sampling, accessed atomically.
+	var updates uint64
 
-		if !cache.WaitForCacheSync(done, one.HasSynced) {
-			panic("Timed out waiting for caches to sync")
+	// send sends the event unless it's filtered.
+	send := func(item QueueObject, obj interface{}) {
+		if item.Event == EventAdd && !newerThan(obj, checkpoint) {
+			// Consumers have it already.
+			return
 		}
+
+		if m.primary != nil && m.primary.isHealthy() {
+			return
+		}
+
+		if atomic.LoadInt32(&c.standby) == 1 {
+			return
+		}
+
+		if r.SampleUpdates > 1 && item.Event == EventUpdate {
+			if atomic.AddUint64(&updates, 1)%uint64(r.SampleUpdates) != 1 {
+				return
+			}
+		}
+
+		if r.LogEvents && c.events != nil {
+			if err := c.events.write(item, obj); err != nil {
+				c.report(err)
+			}
+		}
+
+		if dryRun {
+			m.observed.add(item.RType)
+			return
+		}
+
+		deliver(item, obj)
+	}
+
+	return func(item QueueObject, obj interface{}) {
+		item.Cluster = m.String()
+		item.Labels = clusterLabels
+		item.ClockSkew = m.clock.get()
+		progress.observed(obj)
+		if replay {
+			send(item, obj)
+			return
+		}
+
+		c.modified.touch(item.Cluster, r.RType, time.Now())
+		if err := c.inventory.write(item, obj); err != nil {
+			c.report(err)
+		}
+		c.xds.invalidate(item.RType, obj)
+		c.costs.observe(item, obj)
+		c.waiters.notify(item)
+		if c.opts.OnNewImage != nil && item.RType == Pods && item.Event != EventDelete {
+			c.images.observe(item.Cluster, obj, c.opts.OnNewImage)
+		}
+		m.history.record(item, obj)
+
+		c.replicas.emit(item, obj, send)
+		if violations, ok := c.validators[item.RType].check(item, obj); ok {
+			invalid := item
+			invalid.Event = EventInvalid
+			invalid.Validation = &Validation{violations}
+			send(invalid, obj)
+		}
+		c.policy.enqueue(item, obj, send)
 	}
 }
 
-type RN struct {
-	RType          Resource
-	Namespace      string
+// handleCrash recovers a panic and reports it as an error, so that one bad
+// object or informer can't take down the whole process.
+// It must be called directly by defer.
+// It only covers the goroutine deferring it, e.g. handlers and the goroutine
+// running an informer, not goroutines started by client-go, e.g. the one of
+// the reflector listing and watching, whose panics still crash the process
+// by runtime.HandleCrash.
+func handleCrash(report func(error), format string, args ...interface{}) {
+	if r := recover(); r != nil {
+		report(fmt.Errorf("robot: panic in %s: %v", fmt.Sprintf(format, args...), r))
+	}
 }
 
-func (r *RN) createIndexInformer(client *kubernetes.Clientset, worker queue) (indexer cache.Indexer, informer cache.Controller)  {
-	lw := cache.NewListWatchFromClient(client.CoreV1().RESTClient(), r.RType.String(), r.Namespace, fields.Everything())
-	switch r.RType {
-	case Services:
-		indexer, informer = cache.NewIndexerInformer(lw, &v1.Service{}, 0, initHandle(Services, worker), cache.Indexers{})
-	case Pods:
-		indexer, informer = cache.NewIndexerInformer(lw, &v1.Pod{}, 0, initHandle(Pods, worker), cache.Indexers{})
-	case Endpoints:
-		indexer, informer = cache.NewIndexerInformer(lw, &v1.Endpoints{}, 0, initHandle(Endpoints, worker), cache.Indexers{})
-	case ConfigMaps:
-		indexer, informer = cache.NewIndexerInformer(lw, &v1.ConfigMap{}, 0, initHandle(ConfigMaps, worker), cache.Indexers{})
+// report sends err to the error channel without blocking,
+// the error is dropped if nobody is receiving.
+func (c *controller) report(err error) {
+	select {
+	case c.errs <- err:
+	default:
+	}
+}
+
+func (c *controller) Run() error {
+	c.mu.Lock()
+	if c.running {
+		c.mu.Unlock()
+		return errors.New("robot: Run has been called already")
+	}
+	if len(c.informers()) == 0 {
+		c.mu.Unlock()
+		return errors.New("robot: no resources to discover, please make sure Resources in cluster")
+	}
+	c.running = true
+	if c.opts.Sharding == nil {
+		for _, m := range c.clusters {
+			m.run(c.report)
+		}
+	}
+	c.mu.Unlock()
+
+	defer c.queue.close()
+	defer c.views.close()
+	defer c.inventory.close()
+	if c.audit != nil {
+		defer c.audit.close()
+	}
+
+	go c.watchConfigs()
+	go c.logObserved()
+	go c.checkHealth()
+	go c.saveCheckpoints()
+	go c.detectOrphans()
+	go c.probeServices()
+	go c.exportDNS()
+	go c.serveDebug()
+	go c.runGraphQL()
+	go c.runXDS()
+	go c.reportDrift()
+	go c.evaluatePolicies()
+
+	sharded := make(chan struct{})
+	go func() {
+		defer close(sharded)
+		c.shard()
+	}()
+
+	<-c.stop
+
+	c.mu.Lock()
+	c.checkpoint()
+	if err := c.stream.close(); err != nil {
+		c.report(err)
+	}
+	for _, m := range c.clusters {
+		m.halt()
+	}
+	c.mu.Unlock()
+
+	<-sharded
+
+	return nil
+}
+
+func (c *controller) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+}
+
+func (c *controller) Activate() {
+	if atomic.CompareAndSwapInt32(&c.standby, 1, 0) {
+		klog.Infof("robot: activated, sending events")
+	}
+}
+
+func (c *controller) WaitForSync(ctx context.Context, resources ...Resource) error {
+	c.mu.Lock()
+	informers := c.informers()
+	c.mu.Unlock()
+
+	return informers.waitForSync(ctx, resources...)
+}
+
+// informers returns the informers of all clusters, c.mu must be held.
+func (c *controller) informers() (s informerSet) {
+	for _, m := range c.clusters {
+		s = append(s, m.informers...)
 	}
 	return
 }
 
-type Cluster struct {
-	ConfigPath      string
-	MasterUrl       string
-	Resources       []RN
+func (c *controller) Client(cluster string) kubernetes.Interface {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if m := c.memberOf(cluster); m != nil {
+		return m.client
+	}
+	return nil
 }
 
-func (c *Cluster) newClient() (*kubernetes.Clientset, error) {
-	if c.ConfigPath != "" || c.MasterUrl != "" {
-		config, err := clientcmd.BuildConfigFromFlags(c.MasterUrl, c.ConfigPath)
-		if err != nil {
-			return nil, err
-		}
-		clientset, err := kubernetes.NewForConfig(config)
-		if err != nil {
-			return nil, err
+// memberOf returns the member of the cluster, c.mu must be held.
+func (c *controller) memberOf(cluster string) *member {
+	for _, m := range c.clusters {
+		if m.is(cluster) {
+			return m
 		}
-		return clientset, nil
 	}
-	return nil, errors.New("Can`t find a way to access to k8s api. Please make sure ConfigPath or MasterUrl in cluster ")
+	return nil
+}
+
+func (c *controller) Errors() <-chan error {
+	return c.errs
+}
+
+type RN struct {
+	RType     Resource
+	Namespace string
+
+	// DryRun only counts and logs events of the resource, see Cluster.DryRun.
+	DryRun bool
+
+	// RateLimit limits events of the resource.
+	RateLimit RateLimit
+
+	// GenerationChanged sends updates of the resource only if metadata.generation
+	// changed, i.e. the spec changed, ignoring updates of status or metadata alone.
+	GenerationChanged bool
+
+	// SampleUpdates sends only 1 of every SampleUpdates updates of the resource,
+	// for analytics which don't need every update. Adds and deletes are all sent.
+	SampleUpdates int
+
+	// LogEvents writes events of the resource to Options.EventLog, also in dry run
+	// mode, so that the robot can run with the event log alone.
+	LogEvents bool
+
+	// NamespaceSelector is a label selector of namespaces, when it's set the
+	// resource is watched in each matching namespace instead of Namespace,
+	// and watches are created and removed as namespaces come and go.
+	NamespaceSelector string
+}
+
+func (r *RN) createInformer(lw cache.ListerWatcher, obj runtime.Object, keyFunc cache.KeyFunc, indexer cache.Indexer, h cache.ResourceEventHandler) cache.Controller {
+	if obj == nil {
+		return nil
+	}
+	return newIndexerInformer(lw, obj, keyFunc, h, indexer)
 }
 
 func MetaUIDFunc(obj interface{}) string {
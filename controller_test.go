@@ -0,0 +1,194 @@
+package robot
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestHandlerPanicRecovery(t *testing.T) {
+	var errs []error
+	emit := func(QueueObject, interface{}) {
+		panic("emit")
+	}
+	handler := initHandle(Endpoints, cache.DeletionHandlingMetaNamespaceKeyFunc, emit, func(err error) {
+		errs = append(errs, err)
+	})
+
+	obj := &v1.Endpoints{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "one"}}
+
+	handler.OnAdd(obj)
+	handler.OnDelete(obj)
+
+	if e, a := 2, len(errs); e != a {
+		t.Errorf("expected %v errors, got %v", e, a)
+	}
+}
+
+func TestChanged(t *testing.T) {
+	subsets := []v1.EndpointSubset{{Addresses: []v1.EndpointAddress{{IP: "10.0.0.1"}}}}
+	one := &v1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "one", ResourceVersion: "1"}}
+	two := &v1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "one", ResourceVersion: "2"}}
+	three := &v1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "one", ResourceVersion: "3"}, Subsets: subsets}
+
+	tests := []struct {
+		resource Resource
+		old, new interface{}
+		expected bool
+	}{
+		{Endpoints, one, one, false},
+		{Endpoints, one, two, false},
+		{Endpoints, two, three, true},
+		{Endpoints, cache.DeletedFinalStateUnknown{Key: "one", Obj: one}, three, true},
+		{Endpoints, &v1.Service{}, three, true},
+		{Services, &v1.Service{}, &v1.Service{Spec: v1.ServiceSpec{ClusterIP: "10.0.0.1"}}, true},
+		{Services, &v1.Service{}, &v1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"external-dns": "web"}}}, true},
+		{Services, &v1.Service{}, &v1.Service{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "2"}}, false},
+		{ConfigMaps, &v1.ConfigMap{}, &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"checksum": "1"}}}, true},
+		{ConfigMaps, &v1.ConfigMap{}, &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "2"}}, false},
+		{Pods, &v1.Pod{}, &v1.Pod{}, true},
+	}
+	for i, test := range tests {
+		if e, a := test.expected, changed(test.resource, test.old, test.new); e != a {
+			t.Errorf("%d: expected %v, got %v", i, e, a)
+		}
+	}
+}
+
+type fakeInformer struct{}
+
+func (fakeInformer) Run(stop <-chan struct{}) { <-stop }
+
+func (fakeInformer) HasSynced() bool { return true }
+
+func (fakeInformer) LastSyncResourceVersion() string { return "" }
+
+func TestRunAndStop(t *testing.T) {
+	empty, _ := NewRobot()
+	if err := empty.Run(); err == nil {
+		t.Errorf("expected an error when there is no resource to discover")
+	}
+
+	c := &controller{
+		queue: newWorkQueue(),
+		stop:  make(chan struct{}),
+		clusters: []*member{{
+			informers: informerSet{newInformer(Pods, fakeInformer{})},
+			stop:      make(chan struct{}),
+		}},
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- c.Run()
+	}()
+
+	// wait for the first Run to take effect
+	for {
+		c.mu.Lock()
+		running := c.running
+		c.mu.Unlock()
+		if running {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := c.Run(); err == nil {
+		t.Errorf("expected an error when Run is called twice")
+	}
+
+	c.Stop()
+	c.Stop()
+
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestClient(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	c := &controller{clusters: []*member{{Cluster: Cluster{MasterUrl: "https://one.example.com"}, client: client}}}
+
+	if c.Client("https://one.example.com") != client {
+		t.Errorf("expected client of the cluster")
+	}
+	if a := c.Client("https://two.example.com"); a != nil {
+		t.Errorf("expected nil, got %v", a)
+	}
+}
+
+func TestEmitClusterLabels(t *testing.T) {
+	var sent []QueueObject
+	c := &controller{queue: &recordQueue{sent: &sent}}
+	m := &member{Cluster: Cluster{MasterUrl: "https://one.example.com", Labels: map[string]string{"region": "eu", "env": "prod"}}}
+
+	c.emit(m, RN{RType: Pods})(QueueObject{Event: EventAdd, RType: Pods, Key: "default/one"}, &v1.Pod{})
+	if e, a := 1, len(sent); e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	if e, a := "env=prod,region=eu", sent[0].Labels; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := "eu", sent[0].ClusterLabels().Get("region"); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if _, err := NewRobot(Cluster{MasterUrl: "https://one.example.com", Labels: map[string]string{"region": "eu west"}}); err == nil {
+		t.Errorf("expected error of invalid labels")
+	}
+}
+
+func TestEmitSampleUpdates(t *testing.T) {
+	var sent []QueueObject
+	c := &controller{queue: &recordQueue{sent: &sent}}
+	m := &member{Cluster: Cluster{MasterUrl: "https://one.example.com"}}
+
+	emit := c.emit(m, RN{RType: Pods, SampleUpdates: 3})
+	emit(QueueObject{Event: EventAdd, RType: Pods, Key: "default/one"}, &v1.Pod{})
+	for i := 0; i < 7; i++ {
+		emit(QueueObject{Event: EventUpdate, RType: Pods, Key: "default/one"}, &v1.Pod{})
+	}
+	emit(QueueObject{Event: EventDelete, RType: Pods, Key: "default/one"}, &v1.Pod{})
+
+	counts := make(map[event]int)
+	for _, item := range sent {
+		counts[item.Event]++
+	}
+	for e, n := range map[event]int{EventAdd: 1, EventUpdate: 3, EventDelete: 1} {
+		if a := counts[e]; n != a {
+			t.Errorf("expected %v %v events, got %v", n, e, a)
+		}
+	}
+}
+
+func TestWarmStandby(t *testing.T) {
+	robot, err := NewRobotWithOptions(Options{WarmStandby: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := robot.(*controller)
+	var sent []QueueObject
+	c.queue = &recordQueue{sent: &sent}
+	m := &member{Cluster: Cluster{MasterUrl: "https://one.example.com"}}
+
+	emit := c.emit(m, RN{RType: Pods})
+	emit(QueueObject{Event: EventAdd, RType: Pods, Key: "default/one"}, &v1.Pod{})
+	if e, a := 0, len(sent); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	c.Activate()
+	c.Activate()
+	emit(QueueObject{Event: EventUpdate, RType: Pods, Key: "default/one"}, &v1.Pod{})
+	if e, a := 1, len(sent); e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	if e, a := EventUpdate, sent[0].Event; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
@@ -0,0 +1,97 @@
+package robot
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Converter converts an object of another version to the version of the resource.
+type Converter func(obj *unstructured.Unstructured, to Resource) (*unstructured.Unstructured, error)
+
+// Relabel is a Converter which only changes the apiVersion, for versions of
+// the same schema, e.g. a beta version promoted as is. Objects of versions whose
+// schemas differ, like HPAs of autoscaling/v2beta2 and v2, need a real conversion.
+func Relabel(obj *unstructured.Unstructured, to Resource) (*unstructured.Unstructured, error) {
+	obj.SetAPIVersion(to.groupVersion())
+	return obj, nil
+}
+
+// alternative returns the resource to watch for r, which is r if it's served,
+// or else the first of its alternative versions served by the cluster.
+func (m *member) alternative(r Resource) Resource {
+	return m.alternativeIn(m.served, r)
+}
+
+// alternativeIn is alternative by the resources served.
+func (m *member) alternativeIn(served map[Resource]metav1.APIResource, r Resource) Resource {
+	if _, ok := served[r]; ok || served == nil {
+		return r
+	}
+	for _, version := range m.versions[r] {
+		alt := r
+		alt.Version = version
+		if _, ok := served[alt]; ok {
+			return alt
+		}
+	}
+	return r
+}
+
+// convertingListWatch converts objects listed and watched to the version of the resource.
+func (m *member) convertingListWatch(lw cache.ListerWatcher, to Resource) cache.ListerWatcher {
+	convert := m.converter
+	one := func(obj runtime.Object) (runtime.Object, error) {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			return obj, nil
+		}
+		return convert(u, to)
+	}
+
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			obj, err := lw.List(options)
+			if err != nil {
+				return nil, err
+			}
+			list, ok := obj.(*unstructured.UnstructuredList)
+			if !ok {
+				return obj, nil
+			}
+			for i := range list.Items {
+				out, err := one(&list.Items[i])
+				if err != nil {
+					return nil, fmt.Errorf("robot: convert %s to %s: %v", list.Items[i].GetName(), to.groupVersion(), err)
+				}
+				list.Items[i] = *out.(*unstructured.Unstructured)
+			}
+			return list, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			w, err := lw.Watch(options)
+			if err != nil {
+				return nil, err
+			}
+			return watch.Filter(w, func(in watch.Event) (watch.Event, bool) {
+				if in.Type == watch.Error || in.Object == nil {
+					return in, true
+				}
+				out, err := one(in.Object)
+				if err != nil {
+					// Let the reflector relist.
+					return watch.Event{Type: watch.Error, Object: &metav1.Status{
+						Status:  metav1.StatusFailure,
+						Message: fmt.Sprintf("robot: convert to %s: %v", to.groupVersion(), err),
+					}}, true
+				}
+				in.Object = out
+				return in, true
+			}), nil
+		},
+	}
+}
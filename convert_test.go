@@ -0,0 +1,90 @@
+package robot
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestConvertAlternativeVersion(t *testing.T) {
+	hpa := Resource{Group: "autoscaling", Version: "v2", Resource: "horizontalpodautoscalers"}
+	beta := hpa
+	beta.Version = "v2beta2"
+
+	m := &member{
+		served:   map[Resource]metav1.APIResource{beta: {Name: "horizontalpodautoscalers"}},
+		versions: map[Resource][]string{hpa: {"v2beta1", "v2beta2"}},
+		// The schemas differ, relabeling is enough for the test.
+		converter: Relabel,
+	}
+	if e, a := beta, m.alternative(hpa); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	object := func() unstructured.Unstructured {
+		u := unstructured.Unstructured{}
+		u.SetAPIVersion("autoscaling/v2beta2")
+		u.SetKind("HorizontalPodAutoscaler")
+		u.SetName("one")
+		return u
+	}
+	w := watch.NewFake()
+	lw := m.convertingListWatch(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return &unstructured.UnstructuredList{Items: []unstructured.Unstructured{object()}}, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return w, nil
+		},
+	}, hpa)
+
+	list, err := lw.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := "autoscaling/v2", list.(*unstructured.UnstructuredList).Items[0].GetAPIVersion(); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	converted, err := lw.Watch(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer converted.Stop()
+	u := object()
+	go w.Add(&u)
+	event := <-converted.ResultChan()
+	if e, a := "autoscaling/v2", event.Object.(*unstructured.Unstructured).GetAPIVersion(); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestVersionsRequireConverter(t *testing.T) {
+	hpa := Resource{Group: "autoscaling", Version: "v2", Resource: "horizontalpodautoscalers"}
+	_, err := NewRobotWithOptions(Options{Versions: map[Resource][]string{hpa: {"v2beta2"}}})
+	if err == nil {
+		t.Errorf("expected an error of Versions without Converter")
+	}
+}
+
+func TestUnstructuredMode(t *testing.T) {
+	m := &member{unstructured: true}
+	if m.typed(Pods) {
+		t.Errorf("expected pods unstructured")
+	}
+	if _, ok := m.object(Pods).(*unstructured.Unstructured); !ok {
+		t.Errorf("expected an unstructured object, got %T", m.object(Pods))
+	}
+
+	m = &member{}
+	if !m.typed(Pods) {
+		t.Errorf("expected pods typed")
+	}
+	if _, ok := m.object(Pods).(*unstructured.Unstructured); ok {
+		t.Errorf("expected a typed object")
+	}
+}
@@ -0,0 +1,175 @@
+package robot
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// costsPath is the path of the costs API of the debug server.
+const costsPath = "/costs"
+
+// requestMetrics are the sums of requests of pods by clusters, groups and
+// resources, exposed by expvar as "robot_requests", e.g. cores of CPU as
+// "cluster=east,team=payments,resource=cpu".
+var requestMetrics = expvar.NewMap("robot_requests")
+
+// CostTotal is the sum of requests of pods of a group in a cluster,
+// see Options.CostLabel.
+type CostTotal struct {
+	Cluster string `json:"cluster"`
+
+	// Group is the value of the label of the pods, empty for pods without it.
+	Group string `json:"group"`
+
+	Pods     int             `json:"pods"`
+	Requests v1.ResourceList `json:"requests"`
+}
+
+// costPod is what a pod adds to the total of its group.
+type costPod struct {
+	group    string
+	requests v1.ResourceList
+}
+
+type costKey struct {
+	cluster string
+	group   string
+}
+
+// costAggregator sums requests of pods by the values of a label, incrementally
+// by events of pods. A nil one sums nothing.
+type costAggregator struct {
+	label string
+
+	mu     sync.Mutex
+	pods   map[string]costPod
+	totals map[costKey]*CostTotal
+}
+
+func newCostAggregator(label string) *costAggregator {
+	return &costAggregator{label: label, pods: make(map[string]costPod), totals: make(map[costKey]*CostTotal)}
+}
+
+// observe updates the totals by the event of obj, pods count once scheduled
+// until terminated.
+func (a *costAggregator) observe(item QueueObject, obj interface{}) {
+	if a == nil || item.RType != Pods {
+		return
+	}
+	switch item.Event {
+	case EventAdd, EventUpdate, EventDelete:
+	default:
+		return
+	}
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := item.Cluster + "|" + item.Key
+	if last, ok := a.pods[key]; ok {
+		delete(a.pods, key)
+		a.add(item.Cluster, last, -1)
+	}
+	if item.Event == EventDelete {
+		return
+	}
+	pod, ok := podOf(obj)
+	if !ok || pod.Spec.NodeName == "" || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+		return
+	}
+	one := costPod{group: pod.Labels[a.label], requests: podRequests(pod)}
+	a.pods[key] = one
+	a.add(item.Cluster, one, 1)
+}
+
+// add adds the pod to the total of its group if sign is 1, or subtracts it
+// if -1, and updates the metrics. a.mu must be held.
+func (a *costAggregator) add(cluster string, pod costPod, sign int) {
+	key := costKey{cluster, pod.group}
+	total, ok := a.totals[key]
+	if !ok {
+		total = &CostTotal{Cluster: cluster, Group: pod.group, Requests: v1.ResourceList{}}
+		a.totals[key] = total
+	}
+	total.Pods += sign
+	for name, quantity := range pod.requests {
+		current := total.Requests[name]
+		if sign > 0 {
+			current.Add(quantity)
+		} else {
+			current.Sub(quantity)
+		}
+		total.Requests[name] = current
+	}
+
+	for name, quantity := range total.Requests {
+		metric := a.metric(cluster, pod.group, name)
+		if total.Pods == 0 {
+			requestMetrics.Delete(metric)
+			continue
+		}
+		value := new(expvar.Float)
+		value.Set(float64(quantity.MilliValue()) / 1000)
+		requestMetrics.Set(metric, value)
+	}
+	if total.Pods == 0 {
+		delete(a.totals, key)
+	}
+}
+
+// metric returns the name of the metric of the resource of the group.
+func (a *costAggregator) metric(cluster, group string, resource v1.ResourceName) string {
+	return fmt.Sprintf("cluster=%s,%s=%s,resource=%s", cluster, a.label, group, resource)
+}
+
+// Costs returns the sums of requests of pods by clusters and values of the
+// label of Options.CostLabel, sorted by clusters and groups, none if it's empty.
+func (c *controller) Costs() []CostTotal {
+	a := c.costs
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	totals := make([]CostTotal, 0, len(a.totals))
+	for _, total := range a.totals {
+		one := *total
+		one.Requests = total.Requests.DeepCopy()
+		totals = append(totals, one)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Cluster != totals[j].Cluster {
+			return totals[i].Cluster < totals[j].Cluster
+		}
+		return totals[i].Group < totals[j].Group
+	})
+	return totals
+}
+
+// serveCosts serves Costs as JSON.
+func (c *controller) serveCosts(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if c.costs == nil {
+		http.Error(w, "costs are disabled, set CostLabel", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(c.Costs()); err != nil {
+		c.report(fmt.Errorf("robot: write costs: %v", err))
+	}
+}
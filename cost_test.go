@@ -0,0 +1,84 @@
+package robot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// teamPod returns a pod of the team on a node requesting the CPU.
+func teamPod(name, team, cpu string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Labels: map[string]string{"team": team}},
+		Spec: v1.PodSpec{
+			NodeName: "node-1",
+			Containers: []v1.Container{{
+				Name:      "app",
+				Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu)}},
+			}},
+		},
+		Status: v1.PodStatus{Phase: v1.PodRunning},
+	}
+}
+
+func TestCosts(t *testing.T) {
+	robot, err := NewRobotWithOptions(Options{CostLabel: "team"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := robot.(*controller)
+	c.queue = &recordQueue{sent: &[]QueueObject{}}
+	emit := c.emit(&member{Cluster: Cluster{Name: "east"}}, RN{RType: Pods})
+
+	emit(QueueObject{Event: EventAdd, RType: Pods, Key: "default/a"}, teamPod("a", "payments", "500m"))
+	emit(QueueObject{Event: EventAdd, RType: Pods, Key: "default/b"}, teamPod("b", "payments", "1"))
+	emit(QueueObject{Event: EventAdd, RType: Pods, Key: "default/c"}, teamPod("c", "search", "250m"))
+	// Updated, and replayed by Resync.
+	emit(QueueObject{Event: EventUpdate, RType: Pods, Key: "default/b"}, teamPod("b", "payments", "2"))
+	emit(QueueObject{Event: EventUpdate, RType: Pods, Key: "default/b"}, teamPod("b", "payments", "2"))
+	emit(QueueObject{Event: EventDelete, RType: Pods, Key: "default/c"}, teamPod("c", "search", "250m"))
+	done := teamPod("d", "payments", "4")
+	done.Status.Phase = v1.PodSucceeded
+	emit(QueueObject{Event: EventAdd, RType: Pods, Key: "default/d"}, done)
+
+	costs := c.Costs()
+	if e, a := 1, len(costs); e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	if e, a := "payments", costs[0].Group; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := 2, costs[0].Pods; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	cpu := costs[0].Requests[v1.ResourceCPU]
+	if e, a := "2500m", cpu.String(); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := "2.5", requestMetrics.Get("cluster=east,team=payments,resource=cpu").String(); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if requestMetrics.Get("cluster=east,team=search,resource=cpu") != nil {
+		t.Error("expected no metric of search")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(c.serveCosts))
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var totals []CostTotal
+	if err := json.NewDecoder(resp.Body).Decode(&totals); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := 1, len(totals); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
@@ -0,0 +1,91 @@
+package robot
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+)
+
+// metrics are counters of all robots in the process, exposed by expvar as "robot".
+var metrics = expvar.NewMap("robot")
+
+// processTimeouts counts objects timed out in Process per PoolOptions.Name,
+// exposed by expvar as "robot_process_timeouts".
+var processTimeouts = expvar.NewMap("robot_process_timeouts")
+
+const (
+	// metricEmitted counts events sent to the queue.
+	metricEmitted = "events_emitted"
+
+	// metricDropped counts events dropped or coalesced by rate limits.
+	metricDropped = "events_dropped"
+
+	// metricReconnects counts watches started, including the first ones.
+	metricReconnects = "watch_reconnects"
+
+	// metricWorkers is the number of workers of Process.
+	metricWorkers = "workers"
+
+	// metricPolicyDropped counts events not evaluated by policies as too many
+	// events wait for evaluation.
+	metricPolicyDropped = "policy_evaluations_dropped"
+)
+
+// debugHandler serves net/http/pprof at /debug/pprof/ and expvar at /debug/vars.
+func debugHandler() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+// serveDebug runs the debug server on opts.DebugAddr until the robot stops.
+func (c *controller) serveDebug() {
+	if c.opts.DebugAddr == "" {
+		return
+	}
+
+	mux := debugHandler()
+	mux.Handle(objectsPath, gzipHandler(http.HandlerFunc(c.serveObjects)))
+	mux.Handle(prometheusSDPath, gzipHandler(http.HandlerFunc(c.servePrometheusSD)))
+	mux.Handle(costsPath, gzipHandler(http.HandlerFunc(c.serveCosts)))
+	mux.HandleFunc(eventsPath, c.serveEvents)
+	mux.HandleFunc(eventOffsetsPath, c.serveOffsets)
+	c.listenAndServe("debug", c.opts.DebugAddr, mux)
+}
+
+// runGraphQL runs the GraphQL server on opts.GraphQLAddr until the robot stops.
+func (c *controller) runGraphQL() {
+	if c.opts.GraphQLAddr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(graphqlPath, c.serveGraphQL)
+	c.listenAndServe("graphql", c.opts.GraphQLAddr, mux)
+}
+
+// listenAndServe serves handler on addr until the robot stops.
+func (c *controller) listenAndServe(name, addr string, handler http.Handler) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		c.report(fmt.Errorf("robot: listen %s server: %v", name, err))
+		return
+	}
+
+	server := &http.Server{Handler: handler}
+	go func() {
+		<-c.stop
+		server.Close()
+	}()
+
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		c.report(fmt.Errorf("robot: serve %s server: %v", name, err))
+	}
+}
@@ -0,0 +1,38 @@
+package robot
+
+import (
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+)
+
+// servedResources returns which of the resources are served by the api server,
+// with their discovery information.
+func servedResources(client discovery.DiscoveryInterface, resources []Resource) (map[Resource]metav1.APIResource, error) {
+	served := make(map[Resource]metav1.APIResource)
+	discovered := make(map[string]bool)
+	for _, one := range resources {
+		gv := one.groupVersion()
+		if discovered[gv] {
+			continue
+		}
+		discovered[gv] = true
+
+		list, err := client.ServerResourcesForGroupVersion(gv)
+		if errors.IsNotFound(err) {
+			// The group version isn't served at all.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range list.APIResources {
+			for _, one := range resources {
+				if one.groupVersion() == gv && r.Name == one.Resource {
+					served[one] = r
+				}
+			}
+		}
+	}
+	return served, nil
+}
@@ -0,0 +1,63 @@
+package robot
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestBuildSkipsUnservedResources(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.Resources = []*metav1.APIResourceList{{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{{Name: "services"}, {Name: "pods"}},
+	}}
+
+	m := &member{Cluster: Cluster{Resources: []RN{{RType: Services}, {RType: ConfigMaps}}}}
+	for range m.Resources {
+		m.indexers = append(m.indexers, cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, cache.Indexers{}))
+	}
+
+	var errs []error
+	emitter := func(RN) emitFunc {
+		return func(QueueObject, interface{}) {}
+	}
+	m.build(client, emitter, func(err error) {
+		errs = append(errs, err)
+	})
+
+	if e, a := 1, len(m.informers); e != a {
+		t.Fatalf("expected %v informers, got %v", e, a)
+	}
+	if e, a := Services, m.informers[0].resource; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := 1, len(errs); e != a {
+		t.Errorf("expected %v errors, got %v", e, a)
+	}
+}
+
+func TestServedResourcesOfGroups(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.Resources = []*metav1.APIResourceList{{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{{Name: "pods"}},
+	}, {
+		GroupVersion: "apps/v1",
+		APIResources: []metav1.APIResource{{Name: "deployments"}},
+	}}
+
+	deployments := Resource{Group: "apps", Version: "v1", Resource: "deployments"}
+	statefulSets := Resource{Group: "apps", Version: "v1", Resource: "statefulsets"}
+	served, err := servedResources(client.Discovery(), []Resource{Pods, deployments, statefulSets})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for r, e := range map[Resource]bool{Pods: true, deployments: true, statefulSets: false} {
+		if _, a := served[r]; e != a {
+			t.Errorf("expected %v of %s, got %v", e, r, a)
+		}
+	}
+}
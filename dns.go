@@ -0,0 +1,288 @@
+package robot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// DNSOptions are options of exporting DNS records of Services, see Options.DNS.
+type DNSOptions struct {
+	// Zone is the DNS zone of the records, e.g. "clusterset.example.com".
+	Zone string
+
+	// Selector selects the Services exported by labels, all if empty.
+	Selector string
+
+	// TTL is the TTL of the records in seconds, 60 if zero.
+	TTL int
+
+	// Interval is how often the records are rendered, and exported if they
+	// changed, 30s if zero.
+	Interval time.Duration
+
+	// Exporter exports the records, e.g. NewZoneFileExporter.
+	Exporter DNSExporter
+}
+
+// DNSRecord is a set of DNS records of a name and type.
+type DNSRecord struct {
+	// Name is the fully qualified name, e.g. "web.default.clusterset.example.com.".
+	Name string
+
+	// Type is A or AAAA.
+	Type string
+
+	TTL     int
+	Targets []string
+}
+
+// DNSExporter exports DNS records rendered from Services of all clusters,
+// e.g. to a zone file, or to DNSEndpoint objects of external-dns.
+type DNSExporter interface {
+	// Export replaces the records exported by the records, sorted by names and types.
+	Export(zone string, records []DNSRecord) error
+}
+
+// validate returns an error if the options can't export records.
+func (o *DNSOptions) validate() error {
+	if errs := validation.IsDNS1123Subdomain(strings.TrimSuffix(o.Zone, ".")); len(errs) > 0 {
+		return fmt.Errorf("robot: invalid DNS zone %q: %s", o.Zone, strings.Join(errs, ", "))
+	}
+	if _, err := labels.Parse(o.Selector); err != nil {
+		return fmt.Errorf("robot: invalid DNS selector: %v", err)
+	}
+	if o.Exporter == nil {
+		return errors.New("robot: DNS exporter is nil")
+	}
+	return nil
+}
+
+// zoneFileExporter writes records into a zone file.
+type zoneFileExporter struct {
+	path string
+}
+
+// NewZoneFileExporter returns a DNSExporter writing records into the zone file
+// at path, which is served by a DNS server, e.g. by the file plugin of CoreDNS.
+func NewZoneFileExporter(path string) DNSExporter {
+	return &zoneFileExporter{path: path}
+}
+
+func (e *zoneFileExporter) Export(zone string, records []DNSRecord) error {
+	origin := dnsName(zone)
+	ttl := 60
+	if len(records) > 0 {
+		ttl = records[0].TTL
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "$ORIGIN %s\n", origin)
+	fmt.Fprintf(&buf, "$TTL %d\n", ttl)
+	fmt.Fprintf(&buf, "@\tIN\tSOA\tns.%s hostmaster.%s %d 7200 3600 1209600 %d\n", origin, origin, time.Now().Unix(), ttl)
+	for _, record := range records {
+		for _, target := range record.Targets {
+			fmt.Fprintf(&buf, "%s\t%d\tIN\t%s\t%s\n", record.Name, record.TTL, record.Type, target)
+		}
+	}
+
+	// write then rename, so the file is never partially written
+	tmp := e.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("robot: write zone file: %v", err)
+	}
+	if err := os.Rename(tmp, e.path); err != nil {
+		return fmt.Errorf("robot: write zone file: %v", err)
+	}
+	return nil
+}
+
+// dnsName returns the fully qualified name of name, ending with a dot.
+func dnsName(name string) string {
+	return strings.TrimSuffix(name, ".") + "."
+}
+
+// exportDNS renders and exports DNS records periodically until the robot stops.
+func (c *controller) exportDNS() {
+	if c.opts.DNS == nil {
+		return
+	}
+	opts := *c.opts.DNS
+	if opts.TTL <= 0 {
+		opts.TTL = 60
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 30 * time.Second
+	}
+	// It's validated by NewRobotWithOptions.
+	selector, _ := labels.Parse(opts.Selector)
+
+	// Records rendered from caches not synced would drop names for a while.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-c.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	if err := c.WaitForSync(ctx, Services, Endpoints); err != nil && ctx.Err() == nil {
+		c.report(err)
+	}
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	var last []DNSRecord
+	exported := false
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+			records := c.dnsRecords(opts, selector)
+			c.mu.Unlock()
+
+			if exported && reflect.DeepEqual(records, last) {
+				continue
+			}
+			if err := opts.Exporter.Export(opts.Zone, records); err != nil {
+				c.report(err)
+				continue
+			}
+			last, exported = records, true
+		}
+	}
+}
+
+// dnsRecords renders the records of the Services selected. A Service is named
+// <service>.<namespace>.<zone> by the addresses in all clusters, and
+// <service>.<namespace>.<cluster>.<zone> by those in the cluster, if the name
+// of the cluster is a DNS label. The addresses are the ingress IPs of load
+// balancers, or the ready addresses of the Endpoints. c.mu must be held.
+func (c *controller) dnsRecords(opts DNSOptions, selector labels.Selector) []DNSRecord {
+	zone := dnsName(opts.Zone)
+	targets := make(map[string]map[string]bool)
+	add := func(name, ip string) {
+		if targets[name] == nil {
+			targets[name] = make(map[string]bool)
+		}
+		targets[name][ip] = true
+	}
+
+	for _, m := range c.clusters {
+		var suffixes []string
+		if len(validation.IsDNS1123Label(m.String())) == 0 {
+			suffixes = append(suffixes, m.String()+".")
+		}
+		suffixes = append(suffixes, "")
+
+		for i, r := range m.Resources {
+			if r.RType != Services {
+				continue
+			}
+			for _, obj := range m.indexers[i].List() {
+				accessor, err := meta.Accessor(obj)
+				if err != nil || !selector.Matches(labels.Set(accessor.GetLabels())) {
+					continue
+				}
+				for _, ip := range m.serviceIPs(obj, accessor.GetNamespace(), accessor.GetName()) {
+					for _, suffix := range suffixes {
+						add(accessor.GetName()+"."+accessor.GetNamespace()+"."+suffix+zone, ip)
+					}
+				}
+			}
+		}
+	}
+
+	var records []DNSRecord
+	for name, ips := range targets {
+		a := DNSRecord{Name: name, Type: "A", TTL: opts.TTL}
+		aaaa := DNSRecord{Name: name, Type: "AAAA", TTL: opts.TTL}
+		for ip := range ips {
+			if net.ParseIP(ip).To4() != nil {
+				a.Targets = append(a.Targets, ip)
+			} else {
+				aaaa.Targets = append(aaaa.Targets, ip)
+			}
+		}
+		for _, record := range []DNSRecord{a, aaaa} {
+			if len(record.Targets) > 0 {
+				sort.Strings(record.Targets)
+				records = append(records, record)
+			}
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Name != records[j].Name {
+			return records[i].Name < records[j].Name
+		}
+		return records[i].Type < records[j].Type
+	})
+	return records
+}
+
+// serviceIPs returns the ingress IPs of the Service if it's a load balancer
+// which has them, or the ready addresses of its Endpoints cached.
+func (m *member) serviceIPs(obj interface{}, namespace, name string) []string {
+	var ips []string
+	if service, ok := serviceOf(obj); ok && service.Spec.Type == v1.ServiceTypeLoadBalancer {
+		for _, ingress := range service.Status.LoadBalancer.Ingress {
+			if ingress.IP != "" {
+				ips = append(ips, ingress.IP)
+			}
+		}
+	}
+	if len(ips) > 0 {
+		return ips
+	}
+
+	for i, r := range m.Resources {
+		if r.RType != Endpoints {
+			continue
+		}
+		found, exists, err := getByName(m.indexers[i], namespace, name)
+		if err != nil || !exists {
+			continue
+		}
+		if endpoints, ok := endpointsOf(found); ok {
+			for _, subset := range endpoints.Subsets {
+				for _, address := range subset.Addresses {
+					if net.ParseIP(address.IP) != nil {
+						ips = append(ips, address.IP)
+					}
+				}
+			}
+			break
+		}
+	}
+	return ips
+}
+
+// serviceOf returns obj as a Service, false if it isn't one.
+func serviceOf(obj interface{}) (*v1.Service, bool) {
+	switch o := obj.(type) {
+	case *v1.Service:
+		return o, true
+	case *unstructured.Unstructured:
+		service := &v1.Service{}
+		return service, fromUnstructured(o, "Service", service)
+	}
+	return nil, false
+}
@@ -0,0 +1,101 @@
+package robot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestDNSRecords(t *testing.T) {
+	c := &controller{}
+	for _, name := range []string{"east", "https://west.example.com"} {
+		m := &member{Cluster: Cluster{Name: name, Resources: []RN{{RType: Services}, {RType: Endpoints}}}}
+		for range m.Resources {
+			m.indexers = append(m.indexers, m.newIndexer())
+		}
+		c.clusters = append(c.clusters, m)
+	}
+
+	web := metav1.ObjectMeta{Namespace: "default", Name: "web", Labels: map[string]string{"dns": "true"}}
+	east, west := c.clusters[0], c.clusters[1]
+	_ = east.indexers[0].Add(&v1.Service{ObjectMeta: web})
+	_ = east.indexers[1].Add(&v1.Endpoints{ObjectMeta: web, Subsets: []v1.EndpointSubset{{
+		Addresses:         []v1.EndpointAddress{{IP: "10.0.0.2"}, {IP: "fd00::1"}},
+		NotReadyAddresses: []v1.EndpointAddress{{IP: "10.0.0.3"}},
+	}}})
+	_ = west.indexers[0].Add(&v1.Service{
+		ObjectMeta: web,
+		Spec:       v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer},
+		Status:     v1.ServiceStatus{LoadBalancer: v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: "192.0.2.1"}}}},
+	})
+	_ = west.indexers[1].Add(&v1.Endpoints{ObjectMeta: web, Subsets: []v1.EndpointSubset{{Addresses: []v1.EndpointAddress{{IP: "10.1.0.2"}}}}})
+	_ = east.indexers[0].Add(&v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other"}})
+
+	selector, _ := labels.Parse("dns=true")
+	records := c.dnsRecords(DNSOptions{Zone: "example.com", TTL: 30}, selector)
+	e := []DNSRecord{
+		{Name: "web.default.east.example.com.", Type: "A", TTL: 30, Targets: []string{"10.0.0.2"}},
+		{Name: "web.default.east.example.com.", Type: "AAAA", TTL: 30, Targets: []string{"fd00::1"}},
+		{Name: "web.default.example.com.", Type: "A", TTL: 30, Targets: []string{"10.0.0.2", "192.0.2.1"}},
+		{Name: "web.default.example.com.", Type: "AAAA", TTL: 30, Targets: []string{"fd00::1"}},
+	}
+	if a := records; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestZoneFileExporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "robot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "db.example.com")
+	exporter := NewZoneFileExporter(path)
+	records := []DNSRecord{{Name: "web.default.example.com.", Type: "A", TTL: 30, Targets: []string{"10.0.0.1", "10.0.0.2"}}}
+	if err := exporter.Export("example.com", records); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if e, a := 5, len(lines); e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	if e, a := "$ORIGIN example.com.", lines[0]; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if !strings.HasPrefix(lines[2], "@\tIN\tSOA\tns.example.com. hostmaster.example.com. ") {
+		t.Errorf("unexpected SOA %q", lines[2])
+	}
+	if e, a := "web.default.example.com.\t30\tIN\tA\t10.0.0.2", lines[4]; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestDNSOptions(t *testing.T) {
+	exporter := NewZoneFileExporter("db")
+	for _, opts := range []DNSOptions{
+		{Zone: "Example.com", Exporter: exporter},
+		{Zone: "example.com", Selector: "a b", Exporter: exporter},
+		{Zone: "example.com"},
+	} {
+		if _, err := NewRobotWithOptions(Options{DNS: &opts}); err == nil {
+			t.Errorf("expected an error of %+v", opts)
+		}
+	}
+	if _, err := NewRobotWithOptions(Options{DNS: &DNSOptions{Zone: "example.com.", Exporter: exporter}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,386 @@
+package robot
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+)
+
+// DriftOptions are options of drift reports, see Options.Drift.
+type DriftOptions struct {
+	// Resources are the resources snapshotted, all resources watched but
+	// Secrets if empty. Data of Secrets are compared by their HMAC-SHA256 of
+	// a random key of the process, so they aren't written in reports, and
+	// can't be guessed from them.
+	Resources []Resource
+
+	// Source is the cluster which is the source of truth, objects of other
+	// clusters are compared with its objects. No comparison if empty.
+	Source string
+
+	// Interval is how often snapshots are taken, an hour if zero.
+	Interval time.Duration
+
+	// Ignore are JSON Pointers of fields not compared, e.g. "/spec/replicas".
+	// Status and metadata but labels and annotations are never compared.
+	Ignore []string
+
+	// OnReport is called with each report.
+	OnReport func(*DriftReport)
+
+	// Dir is the directory where each report is written as drift-<time>.json
+	// and drift-<time>.txt, which is human readable. Not written if empty.
+	Dir string
+
+	// Compression compresses the reports written to Dir, which are suffixed
+	// by .gz if it's CompressionGzip.
+	Compression Compression
+}
+
+// DriftKind is how an object drifted.
+type DriftKind string
+
+const (
+	// DriftAdded is an object added since the previous snapshot,
+	// or not in the source cluster.
+	DriftAdded DriftKind = "added"
+
+	// DriftRemoved is an object removed since the previous snapshot,
+	// or only in the source cluster.
+	DriftRemoved DriftKind = "removed"
+
+	// DriftChanged is an object changed since the previous snapshot,
+	// or different from the one of the source cluster.
+	DriftChanged DriftKind = "changed"
+)
+
+// Drift is an object drifted.
+type Drift struct {
+	Kind     DriftKind `json:"kind"`
+	Cluster  string    `json:"cluster"`
+	Resource string    `json:"resource"`
+	Key      string    `json:"key"`
+
+	// Patch is the JSON Patch from the previous object, or the object of the
+	// source cluster, to the object, if it's changed.
+	Patch json.RawMessage `json:"patch,omitempty"`
+}
+
+func (d Drift) String() string {
+	s := fmt.Sprintf("%s %s %s in cluster %s", d.Kind, d.Resource, d.Key, d.Cluster)
+	if len(d.Patch) > 0 {
+		s += ": " + string(d.Patch)
+	}
+	return s
+}
+
+// DriftReport is the drift of objects found by a snapshot.
+type DriftReport struct {
+	Time   time.Time `json:"time"`
+	Source string    `json:"source,omitempty"`
+
+	// Changes are the objects drifted since the previous snapshot,
+	// none at the first one.
+	Changes []Drift `json:"changes"`
+
+	// Divergences are the objects of clusters drifted from the source cluster.
+	Divergences []Drift `json:"divergences"`
+}
+
+// String returns the report in a human readable form.
+func (r *DriftReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Drift report at %s\n", r.Time.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "\n%d changes since the previous snapshot\n", len(r.Changes))
+	for _, d := range r.Changes {
+		fmt.Fprintf(&b, "  %s\n", d)
+	}
+	if r.Source != "" {
+		fmt.Fprintf(&b, "\n%d divergences from cluster %s\n", len(r.Divergences), r.Source)
+		for _, d := range r.Divergences {
+			fmt.Fprintf(&b, "  %s\n", d)
+		}
+	}
+	return b.String()
+}
+
+// driftKey is an object of a snapshot.
+type driftKey struct {
+	cluster  string
+	resource Resource
+	key      string
+}
+
+// driftSnapshot is the compared JSON of objects.
+type driftSnapshot map[driftKey][]byte
+
+// reportDrift takes snapshots and reports drift periodically until the robot stops.
+func (c *controller) reportDrift() {
+	if c.opts.Drift == nil {
+		return
+	}
+	opts := *c.opts.Drift
+	if opts.Interval <= 0 {
+		opts.Interval = time.Hour
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-c.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	if err := c.WaitForSync(ctx, opts.Resources...); err != nil {
+		if ctx.Err() == nil {
+			c.report(err)
+		}
+		return
+	}
+
+	// key is of HMACs of data of Secrets, random as snapshots are only
+	// compared with snapshots of the same run.
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		c.report(fmt.Errorf("robot: drift report: %v", err))
+		return
+	}
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	var last driftSnapshot
+	for {
+		snapshot := c.driftSnapshot(opts, key)
+		report := compareSnapshots(opts, last, snapshot)
+		report.Time = time.Now()
+		last = snapshot
+		klog.Infof("robot: drift report: %d changes, %d divergences", len(report.Changes), len(report.Divergences))
+		if opts.OnReport != nil {
+			opts.OnReport(report)
+		}
+		if err := writeDriftReport(opts.Dir, opts.Compression, report); err != nil {
+			c.report(err)
+		}
+
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// driftSnapshot returns the compared JSON of objects of the resources in
+// all clusters, data of Secrets are HMACs of key. Objects are listed under
+// c.mu, and marshaled without it.
+func (c *controller) driftSnapshot(opts DriftOptions, key []byte) driftSnapshot {
+	wanted := make(map[Resource]bool, len(opts.Resources))
+	for _, r := range opts.Resources {
+		wanted[r] = true
+	}
+
+	type listed struct {
+		cluster string
+		r       Resource
+		objects []interface{}
+	}
+	var lists []listed
+	c.mu.Lock()
+	for _, m := range c.clusters {
+		for i, r := range m.Resources {
+			if len(wanted) > 0 && !wanted[r.RType] {
+				continue
+			}
+			if len(wanted) == 0 && r.RType == Secrets {
+				continue
+			}
+			lists = append(lists, listed{m.String(), r.RType, m.indexers[i].List()})
+		}
+	}
+	c.mu.Unlock()
+
+	snapshot := make(driftSnapshot)
+	for _, one := range lists {
+		for _, obj := range one.objects {
+			objKey, err := cache.MetaNamespaceKeyFunc(obj)
+			if err != nil {
+				continue
+			}
+			var redact []byte
+			if one.r == Secrets {
+				redact = key
+			}
+			data, err := driftJSON(obj, opts.Ignore, redact)
+			if err != nil {
+				continue
+			}
+			snapshot[driftKey{one.cluster, one.r, objKey}] = data
+		}
+	}
+	return snapshot
+}
+
+// driftJSON returns the JSON of obj compared, without status, metadata but
+// labels and annotations, apiVersion, kind, and the fields ignored.
+// Values of data and stringData are replaced by their HMACs of redact if it's set.
+func driftJSON(obj interface{}, ignore []string, redact []byte) ([]byte, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	delete(doc, "status")
+	delete(doc, "apiVersion")
+	delete(doc, "kind")
+	if metadata, ok := doc["metadata"].(map[string]interface{}); ok {
+		for k := range metadata {
+			if k != "labels" && k != "annotations" {
+				delete(metadata, k)
+			}
+		}
+	}
+	if redact != nil {
+		for _, field := range []string{"data", "stringData"} {
+			values, _ := doc[field].(map[string]interface{})
+			for k, v := range values {
+				mac := hmac.New(sha256.New, redact)
+				mac.Write([]byte(fmt.Sprint(v)))
+				values[k] = fmt.Sprintf("hmac-sha256:%x", mac.Sum(nil))
+			}
+		}
+	}
+	for _, pointer := range ignore {
+		removePointer(doc, pointer)
+	}
+	return json.Marshal(doc)
+}
+
+// removePointer removes the field of the JSON Pointer from doc, if it's found.
+func removePointer(doc map[string]interface{}, pointer string) {
+	tokens := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, token := range tokens {
+		token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+		if i == len(tokens)-1 {
+			delete(doc, token)
+			return
+		}
+		next, ok := doc[token].(map[string]interface{})
+		if !ok {
+			return
+		}
+		doc = next
+	}
+}
+
+// compareSnapshots returns the report of the snapshot compared with the last
+// one, if any, and its objects of other clusters compared with the source.
+func compareSnapshots(opts DriftOptions, last, snapshot driftSnapshot) *DriftReport {
+	report := &DriftReport{Source: opts.Source, Changes: []Drift{}, Divergences: []Drift{}}
+
+	if last != nil {
+		report.Changes = diffSnapshots(last, snapshot)
+	}
+
+	if opts.Source != "" {
+		// Objects of the source, as objects of each other cluster watching the resource.
+		clusters := make(map[Resource]map[string]bool)
+		for k := range snapshot {
+			if clusters[k.resource] == nil {
+				clusters[k.resource] = make(map[string]bool)
+			}
+			clusters[k.resource][k.cluster] = true
+		}
+		expected := make(driftSnapshot)
+		actual := make(driftSnapshot)
+		for k, data := range snapshot {
+			if k.cluster != opts.Source {
+				actual[k] = data
+				continue
+			}
+			for cluster := range clusters[k.resource] {
+				if cluster != opts.Source {
+					expected[driftKey{cluster, k.resource, k.key}] = data
+				}
+			}
+		}
+		report.Divergences = diffSnapshots(expected, actual)
+	}
+	return report
+}
+
+// diffSnapshots returns the drift from the objects of a to b, sorted.
+func diffSnapshots(a, b driftSnapshot) []Drift {
+	drifts := []Drift{}
+	for k, data := range b {
+		previous, ok := a[k]
+		switch {
+		case !ok:
+			drifts = append(drifts, Drift{Kind: DriftAdded, Cluster: k.cluster, Resource: k.resource.String(), Key: k.key})
+		case !bytes.Equal(previous, data):
+			patch, _ := createJSONPatch(previous, data)
+			drifts = append(drifts, Drift{Kind: DriftChanged, Cluster: k.cluster, Resource: k.resource.String(), Key: k.key, Patch: patch})
+		}
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			drifts = append(drifts, Drift{Kind: DriftRemoved, Cluster: k.cluster, Resource: k.resource.String(), Key: k.key})
+		}
+	}
+	sort.Slice(drifts, func(i, j int) bool {
+		x, y := drifts[i], drifts[j]
+		if x.Cluster != y.Cluster {
+			return x.Cluster < y.Cluster
+		}
+		if x.Resource != y.Resource {
+			return x.Resource < y.Resource
+		}
+		return x.Key < y.Key
+	})
+	return drifts
+}
+
+// writeDriftReport writes the report into dir as JSON and text compressed by
+// compression, nothing if dir is empty.
+func writeDriftReport(dir string, compression Compression, report *DriftReport) error {
+	if dir == "" {
+		return nil
+	}
+	name := filepath.Join(dir, "drift-"+report.Time.UTC().Format("20060102T150405Z"))
+	var suffix string
+	if compression == CompressionGzip {
+		suffix = ".gz"
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("robot: write drift report: %v", err)
+	}
+	for ext, data := range map[string][]byte{".json": data, ".txt": []byte(report.String())} {
+		compressed, err := compression.compress(data)
+		if err != nil {
+			return fmt.Errorf("robot: write drift report: %v", err)
+		}
+		if err := ioutil.WriteFile(name+ext+suffix, compressed, 0600); err != nil {
+			return fmt.Errorf("robot: write drift report: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package robot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDriftReport(t *testing.T) {
+	c := &controller{}
+	for _, name := range []string{"east", "west"} {
+		m := &member{Cluster: Cluster{Name: name, Resources: []RN{{RType: ConfigMaps}, {RType: Pods}}}}
+		for range m.Resources {
+			m.indexers = append(m.indexers, m.newIndexer())
+		}
+		c.clusters = append(c.clusters, m)
+	}
+	east, west := c.clusters[0], c.clusters[1]
+
+	configMap := func(name, value, rv string) *v1.ConfigMap {
+		return &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, ResourceVersion: rv},
+			Data:       map[string]string{"key": value},
+		}
+	}
+	_ = east.indexers[0].Add(configMap("same", "a", "1"))
+	_ = west.indexers[0].Add(configMap("same", "a", "2"))
+	_ = east.indexers[0].Add(configMap("differs", "a", "1"))
+	_ = west.indexers[0].Add(configMap("differs", "b", "1"))
+	_ = east.indexers[0].Add(configMap("missing", "a", "1"))
+	_ = west.indexers[0].Add(configMap("extra", "a", "1"))
+	_ = east.indexers[1].Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}})
+
+	opts := DriftOptions{Resources: []Resource{ConfigMaps}, Source: "east"}
+	first := c.driftSnapshot(opts, nil)
+	report := compareSnapshots(opts, nil, first)
+	if e, a := 0, len(report.Changes); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	e := []Drift{
+		{Kind: DriftChanged, Cluster: "west", Resource: "configmaps", Key: "default/differs", Patch: []byte(`[{"op":"replace","path":"/data/key","value":"b"}]`)},
+		{Kind: DriftAdded, Cluster: "west", Resource: "configmaps", Key: "default/extra"},
+		{Kind: DriftRemoved, Cluster: "west", Resource: "configmaps", Key: "default/missing"},
+	}
+	if a := report.Divergences; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	_ = east.indexers[0].Update(configMap("same", "c", "3"))
+	_ = west.indexers[0].Delete(configMap("extra", "a", "1"))
+	report = compareSnapshots(DriftOptions{}, first, c.driftSnapshot(opts, nil))
+	e = []Drift{
+		{Kind: DriftChanged, Cluster: "east", Resource: "configmaps", Key: "default/same", Patch: []byte(`[{"op":"replace","path":"/data/key","value":"c"}]`)},
+		{Kind: DriftRemoved, Cluster: "west", Resource: "configmaps", Key: "default/extra"},
+	}
+	if a := report.Changes; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestDriftSecrets(t *testing.T) {
+	m := &member{Cluster: Cluster{Name: "east", Resources: []RN{{RType: Secrets}}}}
+	m.indexers = append(m.indexers, m.newIndexer())
+	_ = m.indexers[0].Add(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "token"},
+		Data:       map[string][]byte{"token": []byte("hunter2")},
+	})
+	c := &controller{clusters: []*member{m}}
+
+	if e, a := 0, len(c.driftSnapshot(DriftOptions{}, nil)); e != a {
+		t.Errorf("expected Secrets not snapshotted by default, got %v", a)
+	}
+	snapshot := c.driftSnapshot(DriftOptions{Resources: []Resource{Secrets}}, []byte("key"))
+	data := snapshot[driftKey{"east", Secrets, "default/token"}]
+	if len(data) == 0 || strings.Contains(string(data), "aHVudGVyMg") {
+		t.Errorf("expected the data of the Secret redacted, got %s", data)
+	}
+	// The data is hashed with the key, so it can't be guessed by unkeyed hashes.
+	unkeyed := fmt.Sprintf("%x", sha256.Sum256([]byte("aHVudGVyMg==")))
+	if !strings.Contains(string(data), "hmac-sha256:") || strings.Contains(string(data), unkeyed) {
+		t.Errorf("expected the data of the Secret keyed by HMAC, got %s", data)
+	}
+	other := c.driftSnapshot(DriftOptions{Resources: []Resource{Secrets}}, []byte("other"))
+	if bytes.Equal(data, other[driftKey{"east", Secrets, "default/token"}]) {
+		t.Errorf("expected HMACs of different keys to differ")
+	}
+}
+
+func TestDriftIgnore(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Labels: map[string]string{"app": "web"}, UID: "1"},
+		Spec:       v1.PodSpec{NodeName: "node-1", Hostname: "web"},
+		Status:     v1.PodStatus{Phase: v1.PodRunning},
+	}
+	data, err := driftJSON(pod, []string{"/spec/nodeName"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, a := `{"metadata":{"labels":{"app":"web"}},"spec":{"containers":null,"hostname":"web"}}`, string(data); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestWriteDriftReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "robot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	report := &DriftReport{
+		Time:        time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Source:      "east",
+		Changes:     []Drift{},
+		Divergences: []Drift{{Kind: DriftRemoved, Cluster: "west", Resource: "configmaps", Key: "default/missing"}},
+	}
+	if err := writeDriftReport(dir, CompressionNone, report); err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := ioutil.ReadFile(filepath.Join(dir, "drift-20200102T030405Z.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(text), "1 divergences from cluster east\n  removed configmaps default/missing in cluster west\n") {
+		t.Errorf("unexpected report %s", text)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "drift-20200102T030405Z.json")); err != nil {
+		t.Error(err)
+	} else if e, a := os.FileMode(0600), info.Mode().Perm(); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	report.Time = report.Time.Add(time.Second)
+	if err := writeDriftReport(dir, CompressionGzip, report); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filepath.Join(dir, "drift-20200102T030406Z.txt.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, _ := ioutil.ReadAll(r); !strings.Contains(string(text), "removed configmaps default/missing") {
+		t.Errorf("unexpected report %s", text)
+	}
+}
+
+func TestDriftSource(t *testing.T) {
+	_, err := NewRobotWithOptions(Options{Drift: &DriftOptions{Source: "east"}})
+	if err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,97 @@
+package robot
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const defaultDryRunLogInterval = time.Minute
+
+// eventCounter counts events of each resource.
+type eventCounter struct {
+	mu     sync.Mutex
+	total  map[Resource]int64
+	logged map[Resource]int64
+}
+
+func newEventCounter() *eventCounter {
+	return &eventCounter{
+		total:  make(map[Resource]int64),
+		logged: make(map[Resource]int64),
+	}
+}
+
+func (c *eventCounter) add(r Resource) {
+	c.mu.Lock()
+	c.total[r]++
+	c.mu.Unlock()
+}
+
+func (c *eventCounter) counts() map[Resource]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := make(map[Resource]int64, len(c.total))
+	for r, n := range c.total {
+		out[r] = n
+	}
+	return out
+}
+
+// since returns the number of events of each resource since the last call.
+func (c *eventCounter) since() map[Resource]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := make(map[Resource]int64)
+	for r, n := range c.total {
+		if n > c.logged[r] {
+			out[r] = n - c.logged[r]
+		}
+		c.logged[r] = n
+	}
+	return out
+}
+
+// logObserved logs the rates of events counted in dry run mode periodically,
+// until the robot stops.
+func (c *controller) logObserved() {
+	dryRun := false
+	for _, m := range c.clusters {
+		if m.DryRun {
+			dryRun = true
+		}
+		for _, r := range m.Resources {
+			if r.DryRun {
+				dryRun = true
+			}
+		}
+	}
+	if !dryRun {
+		return
+	}
+
+	interval := c.opts.DryRunLogInterval
+	if interval <= 0 {
+		interval = defaultDryRunLogInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			for _, m := range c.clusters {
+				for r, n := range m.observed.since() {
+					klog.Infof("robot: dry run of cluster %s observed %d %s events, %.2f/s",
+						m, n, r, float64(n)/interval.Seconds())
+				}
+			}
+		}
+	}
+}
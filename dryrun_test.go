@@ -0,0 +1,30 @@
+package robot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDryRun(t *testing.T) {
+	c := &controller{queue: newWorkQueue()}
+	m := &member{Cluster: Cluster{MasterUrl: "https://one.example.com"}, observed: newEventCounter()}
+
+	emit := c.emit(m, RN{RType: Pods, DryRun: true})
+	emit(QueueObject{Event: EventAdd, RType: Pods, Key: "default/one"}, nil)
+	emit(QueueObject{Event: EventUpdate, RType: Pods, Key: "default/one"}, nil)
+
+	if e, a := int64(2), m.observed.counts()[Pods]; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := int64(2), m.observed.since()[Pods]; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := 0, len(m.observed.since()); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	// pushed items are delayed by the rate limiter
+	time.Sleep(50 * time.Millisecond)
+	if e, a := 0, c.queue.(*wq).Len(); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
@@ -0,0 +1,50 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNoClusterConfig is returned for a cluster without ConfigPath,
+	// MasterUrl or Kubeconfig to access its api server.
+	ErrNoClusterConfig = errors.New("robot: no way to access the api server, set ConfigPath, MasterUrl or Kubeconfig of the cluster")
+
+	// ErrQueueClosed is returned by Pop once the queue is closed as the robot stopped.
+	ErrQueueClosed = errors.New("robot: queue is closed")
+)
+
+// ErrClusterNotFound is returned for a cluster not monitored by the robot.
+type ErrClusterNotFound struct {
+	Cluster string
+}
+
+func (e *ErrClusterNotFound) Error() string {
+	return fmt.Sprintf("robot: cluster %s not found", e.Cluster)
+}
+
+// ErrClusterUnreachable is reported when the api server of a cluster fails
+// health checks.
+type ErrClusterUnreachable struct {
+	Cluster string
+	Err     error
+}
+
+func (e *ErrClusterUnreachable) Error() string {
+	return fmt.Sprintf("robot: cluster %s is unreachable: %v", e.Cluster, e.Err)
+}
+
+// ErrSyncTimeout is returned by WaitForSync when the cache of a resource
+// in a cluster hasn't synced before ctx is done.
+type ErrSyncTimeout struct {
+	Cluster  string
+	Resource Resource
+	Err      error
+}
+
+func (e *ErrSyncTimeout) Error() string {
+	if e.Cluster == "" {
+		return fmt.Sprintf("robot: timed out waiting for %s cache to sync: %v", e.Resource, e.Err)
+	}
+	return fmt.Sprintf("robot: timed out waiting for %s cache of cluster %s to sync: %v", e.Resource, e.Cluster, e.Err)
+}
@@ -0,0 +1,50 @@
+package robot
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestErrNoClusterConfig(t *testing.T) {
+	if _, err := NewRobot(Cluster{Name: "east"}); err != ErrNoClusterConfig {
+		t.Errorf("expected %v, got %v", ErrNoClusterConfig, err)
+	}
+}
+
+func TestErrQueueClosed(t *testing.T) {
+	q := newWorkQueue()
+	q.close()
+	if _, err := q.Pop(); err != ErrQueueClosed {
+		t.Errorf("expected %v, got %v", ErrQueueClosed, err)
+	}
+}
+
+func TestErrClusterNotFound(t *testing.T) {
+	c := &controller{}
+	err := c.Resync(Pods, "east")
+	if e, ok := err.(*ErrClusterNotFound); !ok || e.Cluster != "east" {
+		t.Errorf("expected cluster east not found, got %v", err)
+	}
+}
+
+func TestErrSyncTimeout(t *testing.T) {
+	one := newInformer(Services, unsyncedInformer{})
+	one.cluster = "east"
+	s := informerSet{one}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	s.run(stop, func(error) {})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	err := s.waitForSync(ctx)
+	e, ok := err.(*ErrSyncTimeout)
+	if !ok {
+		t.Fatalf("expected a sync timeout, got %v", err)
+	}
+	if e.Cluster != "east" || e.Resource != Services {
+		t.Errorf("expected services of cluster east, got %v", e)
+	}
+}
@@ -0,0 +1,158 @@
+package robot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+// EventRecord is an event written to the event log, whose JSON is in the
+// structured logging format of log collectors, e.g. Loki and Stackdriver.
+type EventRecord struct {
+	Severity string            `json:"severity"`
+	Time     time.Time         `json:"time"`
+	Message  string            `json:"message"`
+	Cluster  string            `json:"cluster"`
+	Resource string            `json:"resource"`
+	Event    string            `json:"event"`
+	Key      string            `json:"key"`
+	Labels   map[string]string `json:"labels,omitempty"`
+
+	// Object is the object of the event, or Patch is the patch from the
+	// last object of the key for updates, see Payload.
+	Object json.RawMessage `json:"object,omitempty"`
+	Patch  json.RawMessage `json:"patch,omitempty"`
+
+	// Seq is the sequence number of the event in the event stream, see
+	// Options.EventStream, zero in the event log.
+	Seq uint64 `json:"seq,omitempty"`
+}
+
+// Payload is the content of objects written in event lines.
+type Payload int
+
+const (
+	// PayloadNone writes no object.
+	PayloadNone Payload = iota
+
+	// PayloadObject writes the whole object.
+	PayloadObject
+
+	// PayloadJSONPatch writes the JSON Patch, RFC 6902, from the last object
+	// for updates, and the whole object otherwise.
+	PayloadJSONPatch
+
+	// PayloadMergePatch writes the JSON Merge Patch, RFC 7386, from the last
+	// object for updates, and the whole object otherwise.
+	PayloadMergePatch
+)
+
+// eventLog writes events encoded by its codec to a writer.
+type eventLog struct {
+	payload     Payload
+	codec       Codec
+	compression Compression
+
+	mu sync.Mutex
+	w  io.Writer
+
+	// last are the last objects of keys written, for patches.
+	last map[string][]byte
+}
+
+func newEventLog(w io.Writer, payload Payload, codec Codec, compression Compression) *eventLog {
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	return &eventLog{w: w, payload: payload, codec: codec, compression: compression, last: make(map[string][]byte)}
+}
+
+func (l *eventLog) write(item QueueObject, obj interface{}) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record, err := l.record(item, obj)
+	if err != nil {
+		return fmt.Errorf("robot: write event log: %v", err)
+	}
+	data, err := l.codec.Encode(record)
+	if err != nil {
+		return fmt.Errorf("robot: write event log: %v", err)
+	}
+	if data, err = l.compression.compress(data); err != nil {
+		return fmt.Errorf("robot: write event log: %v", err)
+	}
+
+	if _, err := l.w.Write(data); err != nil {
+		return fmt.Errorf("robot: write event log: %v", err)
+	}
+	return nil
+}
+
+// record returns the record of the event of obj. l.mu must be held.
+func (l *eventLog) record(item QueueObject, obj interface{}) (*EventRecord, error) {
+	object, patch, err := l.encode(item, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	severity := "INFO"
+	if item.Event == EventAlert {
+		severity = "WARNING"
+	}
+	return &EventRecord{
+		Severity: severity,
+		Time:     item.CreateAt,
+		Message:  fmt.Sprintf("%s %s %s in cluster %s", item.Event, item.RType, item.Key, item.Cluster),
+		Cluster:  item.Cluster,
+		Resource: item.RType.String(),
+		Event:    item.Event.String(),
+		Key:      item.Key,
+		Labels:   item.ClusterLabels(),
+		Object:   object,
+		Patch:    patch,
+	}, nil
+}
+
+// encode returns the object of the event, or the patch from the last object
+// of the key, as the payload. l.mu must be held.
+func (l *eventLog) encode(item QueueObject, obj interface{}) (object, patch []byte, err error) {
+	if l.payload == PayloadNone {
+		return nil, nil, nil
+	}
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if obj == nil {
+		return nil, nil, nil
+	}
+	object, err = json.Marshal(obj)
+	if err != nil {
+		return nil, nil, err
+	}
+	if l.payload == PayloadObject {
+		return object, nil, nil
+	}
+
+	key := item.Cluster + "|" + item.RType.String() + "|" + item.Key
+	last, ok := l.last[key]
+	if item.Event == EventDelete {
+		delete(l.last, key)
+	} else {
+		l.last[key] = object
+	}
+	if item.Event != EventUpdate || !ok {
+		return object, nil, nil
+	}
+
+	if l.payload == PayloadMergePatch {
+		patch, err = createMergePatch(last, object)
+	} else {
+		patch, err = createJSONPatch(last, object)
+	}
+	return nil, patch, err
+}
@@ -0,0 +1,75 @@
+package robot
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestEventLog(t *testing.T) {
+	var buf bytes.Buffer
+	c := &controller{events: newEventLog(&buf, PayloadNone, nil, CompressionNone)}
+	m := &member{
+		Cluster:  Cluster{MasterUrl: "https://one.example.com", DryRun: true, Labels: map[string]string{"env": "prod"}},
+		observed: newEventCounter(),
+	}
+
+	c.emit(m, RN{RType: Pods, LogEvents: true})(QueueObject{Event: EventAdd, RType: Pods, Key: "default/one"}, &v1.Pod{})
+	c.emit(m, RN{RType: Services})(QueueObject{Event: EventAdd, RType: Services, Key: "default/one"}, &v1.Service{})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if e, a := 1, len(lines); e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+
+	var line EventRecord
+	if err := json.Unmarshal([]byte(lines[0]), &line); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "pods", line.Resource; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := "https://one.example.com", line.Cluster; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := "prod", line.Labels["env"]; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestEventLogPatch(t *testing.T) {
+	var buf bytes.Buffer
+	l := newEventLog(&buf, PayloadJSONPatch, nil, CompressionNone)
+
+	pod := &v1.Pod{}
+	pod.Name = "one"
+	if err := l.write(QueueObject{Event: EventAdd, RType: Pods, Key: "default/one"}, pod); err != nil {
+		t.Fatal(err)
+	}
+	pod = pod.DeepCopy()
+	pod.Labels = map[string]string{"a": "1"}
+	if err := l.write(QueueObject{Event: EventUpdate, RType: Pods, Key: "default/one"}, pod); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var add, update EventRecord
+	if err := json.Unmarshal([]byte(lines[0]), &add); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &update); err != nil {
+		t.Fatal(err)
+	}
+	if add.Object == nil {
+		t.Errorf("expected the object of add")
+	}
+	if update.Object != nil {
+		t.Errorf("expected no object of update, got %s", update.Object)
+	}
+	if e, a := `[{"op":"add","path":"/metadata/labels","value":{"a":"1"}}]`, string(update.Patch); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
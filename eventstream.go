@@ -0,0 +1,334 @@
+package robot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// eventsPath is the path of the event stream of the debug server, and
+// eventOffsetsPath is where consumers commit their offsets.
+const (
+	eventsPath       = "/events"
+	eventOffsetsPath = "/events/offsets"
+)
+
+// seqBlock is how many sequence numbers are reserved at OffsetsPath at once,
+// before they're used, so that they aren't used again after a crash.
+const seqBlock = 1000
+
+// EventStreamOptions are options of the event stream, see Options.EventStream.
+type EventStreamOptions struct {
+	// Size is how many last events are kept for consumers to resume from,
+	// 10000 if zero.
+	Size int
+
+	// Payload is the content of objects of events.
+	Payload Payload
+
+	// OffsetsPath is the file where offsets of consumers and the sequence
+	// numbers reserved are saved, so that they survive restarts. Not saved if empty.
+	OffsetsPath string
+}
+
+// streamOffsets are what's saved in EventStreamOptions.OffsetsPath, Seq is
+// the last sequence number which may have been used.
+type streamOffsets struct {
+	Seq     uint64            `json:"seq"`
+	Offsets map[string]uint64 `json:"offsets"`
+}
+
+// eventStream numbers events delivered, keeps the last ones for consumers,
+// and the offsets committed by them. A nil one keeps nothing.
+type eventStream struct {
+	opts    EventStreamOptions
+	records *eventLog
+
+	// saveMu serializes writes of OffsetsPath, it's taken before mu.
+	saveMu sync.Mutex
+
+	mu      sync.Mutex
+	seq     uint64
+	events  []EventRecord
+	offsets map[string]uint64
+	// appended is closed once an event is appended.
+	appended chan struct{}
+	// saved is the last sequence number reserved at OffsetsPath, which seq
+	// doesn't pass, and reserving is set while more are being reserved.
+	saved     uint64
+	reserving bool
+	reserved  *sync.Cond
+}
+
+// newEventStream creates the event stream, with the offsets saved if any.
+func newEventStream(opts EventStreamOptions) (*eventStream, error) {
+	if opts.Size <= 0 {
+		opts.Size = 10000
+	}
+	s := &eventStream{
+		opts:     opts,
+		records:  newEventLog(nil, opts.Payload, nil, CompressionNone),
+		offsets:  make(map[string]uint64),
+		appended: make(chan struct{}),
+	}
+	s.reserved = sync.NewCond(&s.mu)
+	if opts.OffsetsPath == "" {
+		return s, nil
+	}
+
+	data, err := ioutil.ReadFile(opts.OffsetsPath)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("robot: load event offsets: %v", err)
+	}
+	var saved streamOffsets
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("robot: load event offsets: %v", err)
+	}
+	s.seq, s.saved = saved.Seq, saved.Seq
+	for consumer, offset := range saved.Offsets {
+		s.offsets[consumer] = offset
+	}
+	return s, nil
+}
+
+// append numbers the event of obj, and keeps it.
+func (s *eventStream) append(item QueueObject, obj interface{}) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reserve(); err != nil {
+		return err
+	}
+
+	// Records are made in the order of sequence numbers, as patches are
+	// from the last objects.
+	s.records.mu.Lock()
+	record, err := s.records.record(item, obj)
+	s.records.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("robot: append event to stream: %v", err)
+	}
+	s.seq++
+	record.Seq = s.seq
+	s.events = append(s.events, *record)
+	if len(s.events) > s.opts.Size {
+		s.events = append(s.events[:0:0], s.events[len(s.events)-s.opts.Size:]...)
+	}
+	close(s.appended)
+	s.appended = make(chan struct{})
+	return nil
+}
+
+// reserve reserves more sequence numbers at OffsetsPath once they run out,
+// writing the file without holding s.mu. s.mu must be held.
+func (s *eventStream) reserve() error {
+	if s.opts.OffsetsPath == "" {
+		return nil
+	}
+	for s.seq >= s.saved {
+		if s.reserving {
+			s.reserved.Wait()
+			continue
+		}
+		s.reserving = true
+		s.mu.Unlock()
+		saved, err := s.save(s.seq + seqBlock)
+		s.mu.Lock()
+		s.reserving = false
+		s.reserved.Broadcast()
+		if err != nil {
+			return fmt.Errorf("robot: reserve event sequence numbers: %v", err)
+		}
+		if saved > s.saved {
+			s.saved = saved
+		}
+	}
+	return nil
+}
+
+// since returns the events after the sequence number, and a channel closed
+// once more are appended. It returns false if events after it were dropped.
+func (s *eventStream) since(after uint64) ([]EventRecord, <-chan struct{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if after > s.seq {
+		return nil, s.appended, true
+	}
+	oldest := s.seq - uint64(len(s.events)) + 1
+	if after+1 < oldest {
+		return nil, nil, false
+	}
+	events := s.events[after+1-oldest:]
+	return append([]EventRecord(nil), events...), s.appended, true
+}
+
+// CommitOffset commits the sequence number of the last event of the event
+// stream processed by the consumer, so it resumes after it.
+func (c *controller) CommitOffset(consumer string, seq uint64) error {
+	s := c.stream
+	if s == nil {
+		return errors.New("robot: event stream is disabled")
+	}
+	if consumer == "" {
+		return errors.New("robot: consumer is required")
+	}
+
+	s.mu.Lock()
+	if seq > s.seq {
+		s.mu.Unlock()
+		return fmt.Errorf("robot: offset %d is after the last event %d", seq, s.seq)
+	}
+	s.offsets[consumer] = seq
+	s.mu.Unlock()
+
+	_, err := s.save(0)
+	return err
+}
+
+// Offset returns the offset committed by the consumer, zero if none.
+func (c *controller) Offset(consumer string) uint64 {
+	s := c.stream
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.offsets[consumer]
+}
+
+// save saves the offsets, and the sequence numbers reserved up to seq, or
+// up to the ones reserved before if it's less. It returns the last one saved.
+func (s *eventStream) save(seq uint64) (uint64, error) {
+	if s.opts.OffsetsPath == "" {
+		return 0, nil
+	}
+	// The state is copied under saveMu, so a state copied earlier can't be
+	// written over a later one.
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.Lock()
+	if seq < s.saved {
+		seq = s.saved
+	}
+	saved := streamOffsets{Seq: seq, Offsets: make(map[string]uint64, len(s.offsets))}
+	for consumer, offset := range s.offsets {
+		saved.Offsets[consumer] = offset
+	}
+	s.mu.Unlock()
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return 0, fmt.Errorf("robot: save event offsets: %v", err)
+	}
+	tmp := s.opts.OffsetsPath + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return 0, fmt.Errorf("robot: save event offsets: %v", err)
+	}
+	if err := os.Rename(tmp, s.opts.OffsetsPath); err != nil {
+		return 0, fmt.Errorf("robot: save event offsets: %v", err)
+	}
+	return seq, nil
+}
+
+// close saves the offsets and the last sequence number once the robot stops,
+// so that sequence numbers continue without a gap after a clean restart.
+func (s *eventStream) close() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	for s.reserving {
+		s.reserved.Wait()
+	}
+	s.saved = s.seq
+	s.mu.Unlock()
+
+	_, err := s.save(0)
+	return err
+}
+
+// serveEvents streams events as JSON lines after the sequence number of the
+// query parameter after, or the offset committed by the consumer of the query,
+// until the client goes away or the robot stops. It's 410 Gone if events after
+// it were dropped, so the consumer must resync from the store.
+func (c *controller) serveEvents(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s := c.stream
+	if s == nil {
+		http.Error(w, "event stream is disabled, set EventStream", http.StatusNotFound)
+		return
+	}
+
+	query := req.URL.Query()
+	after := c.Offset(query.Get("consumer"))
+	if v := query.Get("after"); v != "" {
+		var err error
+		if after, err = strconv.ParseUint(v, 10, 64); err != nil {
+			http.Error(w, fmt.Sprintf("invalid after %s", v), http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	for started := false; ; started = true {
+		events, appended, ok := s.since(after)
+		if !ok {
+			if !started {
+				http.Error(w, fmt.Sprintf("events after %d were dropped", after), http.StatusGone)
+			}
+			return
+		}
+		for _, event := range events {
+			if err := encoder.Encode(event); err != nil {
+				return
+			}
+			after = event.Seq
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		select {
+		case <-appended:
+		case <-req.Context().Done():
+			return
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// serveOffsets commits the offset of the form value seq of the consumer.
+func (c *controller) serveOffsets(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	seq, err := strconv.ParseUint(req.FormValue("seq"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid seq %s", req.FormValue("seq")), http.StatusBadRequest)
+		return
+	}
+	if err := c.CommitOffset(req.FormValue("consumer"), seq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
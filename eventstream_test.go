@@ -0,0 +1,159 @@
+package robot
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestEventStream(t *testing.T) {
+	s, err := newEventStream(EventStreamOptions{Size: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, key := range []string{"default/one", "default/two", "default/three"} {
+		if err := s.append(QueueObject{Event: EventAdd, RType: Pods, Key: key}, &v1.Pod{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	events, _, ok := s.since(1)
+	if !ok || len(events) != 2 {
+		t.Fatalf("expected 2 events, got %v %v", ok, events)
+	}
+	if events[0].Seq != 2 || events[0].Key != "default/two" || events[1].Seq != 3 {
+		t.Errorf("expected events 2 and 3, got %v", events)
+	}
+	if _, _, ok := s.since(0); ok {
+		t.Errorf("expected events after 0 dropped")
+	}
+	if events, appended, ok := s.since(3); !ok || len(events) != 0 || appended == nil {
+		t.Errorf("expected no event after the last one, got %v", events)
+	}
+}
+
+func TestCommitOffset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "robot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "offsets.json")
+	s, err := newEventStream(EventStreamOptions{OffsetsPath: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := &controller{stream: s}
+	if err := c.CommitOffset("indexer", 1); err == nil {
+		t.Errorf("expected an error of an offset after the last event")
+	}
+	s.append(QueueObject{Event: EventAdd, RType: Pods, Key: "default/one"}, &v1.Pod{})
+	s.append(QueueObject{Event: EventAdd, RType: Pods, Key: "default/two"}, &v1.Pod{})
+	if err := c.CommitOffset("indexer", 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Offsets and sequence numbers survive restarts.
+	s, err = newEventStream(EventStreamOptions{OffsetsPath: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c = &controller{stream: s}
+	if e, a := uint64(1), c.Offset("indexer"); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	s.append(QueueObject{Event: EventAdd, RType: Pods, Key: "default/three"}, &v1.Pod{})
+	if events, _, _ := s.since(2); len(events) != 1 || events[0].Seq != 3 {
+		t.Errorf("expected the event 3, got %v", events)
+	}
+
+	// Sequence numbers used before a crash are never used again.
+	s, err = newEventStream(EventStreamOptions{OffsetsPath: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s.append(QueueObject{Event: EventAdd, RType: Pods, Key: "default/four"}, &v1.Pod{})
+	if events, _, ok := s.since(3); ok || len(events) != 0 {
+		t.Errorf("expected events after 3 dropped after a crash, got %v", events)
+	}
+	if s.seq <= 3 {
+		t.Errorf("expected a sequence number after 3, got %v", s.seq)
+	}
+
+	if err := (&controller{}).CommitOffset("indexer", 1); err == nil {
+		t.Errorf("expected an error when the event stream is disabled")
+	}
+}
+
+func TestServeEvents(t *testing.T) {
+	s, _ := newEventStream(EventStreamOptions{})
+	c := &controller{stream: s, stop: make(chan struct{})}
+	defer close(c.stop)
+	s.append(QueueObject{Event: EventAdd, RType: Pods, Key: "default/one"}, &v1.Pod{})
+	s.append(QueueObject{Event: EventAdd, RType: Pods, Key: "default/two"}, &v1.Pod{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(eventsPath, c.serveEvents)
+	mux.HandleFunc(eventOffsetsPath, c.serveOffsets)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+eventOffsetsPath+"?consumer=indexer&seq=1", "", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if e, a := http.StatusNoContent, resp.StatusCode; e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+
+	resp, err = http.Get(server.URL + eventsPath + "?consumer=indexer")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	lines := bufio.NewScanner(resp.Body)
+
+	next := func() EventRecord {
+		var e EventRecord
+		if !lines.Scan() {
+			t.Fatalf("expected an event, got %v", lines.Err())
+		}
+		if err := json.Unmarshal(lines.Bytes(), &e); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return e
+	}
+	if e := next(); e.Seq != 2 || e.Key != "default/two" {
+		t.Errorf("expected the event after the offset, got %v", e)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		s.append(QueueObject{Event: EventAdd, RType: Pods, Key: "default/three"}, &v1.Pod{})
+	}()
+	if e := next(); e.Seq != 3 {
+		t.Errorf("expected the event appended, got %v", e)
+	}
+
+	resp, err = http.Get(server.URL + eventsPath + "?after=x")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if e, a := http.StatusBadRequest, resp.StatusCode; e != a || !strings.HasPrefix(resp.Status, "400") {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"gitlab.mfwdev.com/servicemesh/robot"
 	"time"
 
 	"gitlab.mfwdev.com/servicemesh/robot"
@@ -11,26 +10,35 @@ import (
 func main() {
 	r, err := robot.NewRobot(
 		robot.Cluster{
-			ConfigPath:"/Users/zy/.kube/config37",
-			Resources:[]robot.RN{
-				{robot.Services, "istio-system"},
-				{robot.Pods, "istio-system"},
-				{robot.Endpoints, "default"},
+			ConfigPath: "/Users/zy/.kube/config37",
+			Resources: []robot.RN{
+				{RType: robot.Services, Namespace: "istio-system"},
+				{RType: robot.Pods, Namespace: "istio-system"},
+				{RType: robot.Endpoints, Namespace: "default"},
 			},
 		},
 		robot.Cluster{
-			ConfigPath:"/Users/zy/.kube/config39",
-			Resources:[]robot.RN{
-				{robot.Services, "istio-system"},
-				{robot.Pods, "istio-system"},
-				{robot.Pods, "default"},
+			ConfigPath: "/Users/zy/.kube/config39",
+			Resources: []robot.RN{
+				{RType: robot.Services, Namespace: "istio-system"},
+				{RType: robot.Pods, Namespace: "istio-system"},
+				{RType: robot.Pods, Namespace: "default"},
 			},
 		},
 	)
 	if err != nil {
 		panic(err)
 	}
-	go r.Run()
+	go func() {
+		for err := range r.Errors() {
+			fmt.Println(err)
+		}
+	}()
+	go func() {
+		if err := r.Run(); err != nil {
+			panic(err)
+		}
+	}()
 
 	for {
 		obj, _ := r.Pop()
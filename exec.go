@@ -0,0 +1,157 @@
+package robot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// execCredentials authenticates requests by tokens of an exec credential
+// plugin in place of the exec authenticator of client-go, whose runs of the
+// plugin are unbounded. Each run is bounded by timeout, so a hanging plugin
+// fails requests instead of blocking them forever. Tokens are cached until
+// they expire or a request is rejected with 401. Client certificates of
+// plugins aren't supported.
+type execCredentials struct {
+	plugin  *clientcmdapi.ExecConfig
+	timeout time.Duration
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// execCredential is the ExecCredential printed by plugins.
+type execCredential struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Status     *struct {
+		Token                 string       `json:"token"`
+		ExpirationTimestamp   *metav1.Time `json:"expirationTimestamp"`
+		ClientCertificateData string       `json:"clientCertificateData"`
+	} `json:"status"`
+}
+
+func (e *execCredentials) wrap(rt http.RoundTripper) http.RoundTripper {
+	return &execRoundTripper{credentials: e, base: rt}
+}
+
+// get returns the cached token, or the token of a new run of the plugin.
+func (e *execCredentials) get() (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.token != "" && (e.expiry.IsZero() || time.Now().Before(e.expiry)) {
+		return e.token, nil
+	}
+	token, expiry, err := e.run()
+	if err != nil {
+		return "", err
+	}
+	e.token, e.expiry = token, expiry
+	return token, nil
+}
+
+// expire drops the token if it's still cached, so the next request runs the plugin.
+func (e *execCredentials) expire(token string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.token == token {
+		e.token = ""
+	}
+}
+
+// run runs the plugin, and returns the token it prints and its expiry,
+// zero if it doesn't expire.
+func (e *execCredentials) run() (string, time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	info, err := json.Marshal(map[string]interface{}{
+		"apiVersion": e.plugin.APIVersion,
+		"kind":       "ExecCredential",
+		"spec":       map[string]interface{}{},
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	cmd := exec.CommandContext(ctx, e.plugin.Command, e.plugin.Args...)
+	cmd.Env = append(os.Environ(), "KUBERNETES_EXEC_INFO="+string(info))
+	for _, env := range e.plugin.Env {
+		cmd.Env = append(cmd.Env, env.Name+"="+env.Value)
+	}
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", time.Time{}, fmt.Errorf("robot: exec credential plugin %s timed out after %v", e.plugin.Command, e.timeout)
+		}
+		return "", time.Time{}, fmt.Errorf("robot: exec credential plugin %s: %v", e.plugin.Command, err)
+	}
+
+	var cred execCredential
+	if err := json.Unmarshal(stdout.Bytes(), &cred); err != nil {
+		return "", time.Time{}, fmt.Errorf("robot: decode output of exec credential plugin %s: %v", e.plugin.Command, err)
+	}
+	switch {
+	case cred.Kind != "ExecCredential" || cred.APIVersion != e.plugin.APIVersion:
+		return "", time.Time{}, fmt.Errorf("robot: exec credential plugin %s printed %s %s, expected ExecCredential %s",
+			e.plugin.Command, cred.APIVersion, cred.Kind, e.plugin.APIVersion)
+	case cred.Status == nil || cred.Status.Token == "" && cred.Status.ClientCertificateData != "":
+		return "", time.Time{}, fmt.Errorf("robot: exec credential plugin %s printed no token, client certificates aren't supported with ExecTimeout", e.plugin.Command)
+	case cred.Status.Token == "":
+		return "", time.Time{}, fmt.Errorf("robot: exec credential plugin %s printed no token", e.plugin.Command)
+	}
+	var expiry time.Time
+	if cred.Status.ExpirationTimestamp != nil {
+		expiry = cred.Status.ExpirationTimestamp.Time
+	}
+	return cred.Status.Token, expiry, nil
+}
+
+// execRoundTripper sets the token of the exec credential plugin on requests,
+// unless they're authorized already.
+type execRoundTripper struct {
+	credentials *execCredentials
+	base        http.RoundTripper
+}
+
+func (rt *execRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" {
+		return rt.base.RoundTrip(req)
+	}
+	token, err := rt.credentials.get()
+	if err != nil {
+		return nil, err
+	}
+
+	// Requests must not be modified by round trippers.
+	authorized := new(http.Request)
+	*authorized = *req
+	authorized.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		authorized.Header[k] = v
+	}
+	authorized.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := rt.base.RoundTrip(authorized)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		rt.credentials.expire(token)
+	}
+	return resp, nil
+}
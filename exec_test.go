@@ -0,0 +1,78 @@
+package robot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestExecCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "robot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	runs := filepath.Join(dir, "runs")
+	plugin := filepath.Join(dir, "plugin")
+	script := `#!/bin/sh
+echo run >> ` + runs + `
+echo '{"apiVersion":"client.authentication.k8s.io/v1beta1","kind":"ExecCredential","status":{"token":"'$TOKEN'"}}'
+`
+	if err := ioutil.WriteFile(plugin, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	var authorizations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		authorizations = append(authorizations, req.Header.Get("Authorization"))
+		if len(authorizations) == 2 {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	credentials := &execCredentials{
+		plugin: &clientcmdapi.ExecConfig{
+			APIVersion: "client.authentication.k8s.io/v1beta1",
+			Command:    plugin,
+			Env:        []clientcmdapi.ExecEnvVar{{Name: "TOKEN", Value: "secret"}},
+		},
+		timeout: 5 * time.Second,
+	}
+	client := &http.Client{Transport: credentials.wrap(http.DefaultTransport)}
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if e, a := "Bearer secret,Bearer secret,Bearer secret", strings.Join(authorizations, ","); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	// The token is cached, and the plugin is run again after a 401.
+	if data, _ := ioutil.ReadFile(runs); strings.Count(string(data), "run") != 2 {
+		t.Errorf("expected 2 runs of the plugin, got %q", data)
+	}
+
+	hanging := &execCredentials{
+		plugin:  &clientcmdapi.ExecConfig{APIVersion: "client.authentication.k8s.io/v1beta1", Command: "sleep", Args: []string{"5"}},
+		timeout: 100 * time.Millisecond,
+	}
+	client = &http.Client{Transport: hanging.wrap(http.DefaultTransport)}
+	start := time.Now()
+	if _, err := client.Get(server.URL); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a timeout of the plugin, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the plugin killed on timeout, took %v", elapsed)
+	}
+}
@@ -0,0 +1,92 @@
+package robot
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	defaultHealthCheckInterval = 10 * time.Second
+
+	// healthCheckFailures is how many successive failed checks make a cluster unhealthy.
+	healthCheckFailures = 3
+)
+
+// linkStandbys links standby members to their primaries.
+func (c *controller) linkStandbys() error {
+	for _, m := range c.clusters {
+		if m.Primary == "" {
+			continue
+		}
+		for _, p := range c.clusters {
+			if p != m && p.is(m.Primary) {
+				m.primary = p
+			}
+		}
+		if m.primary == nil {
+			return fmt.Errorf("robot: primary cluster %s of %s not found", m.Primary, m)
+		}
+		if m.primary.Primary != "" {
+			return fmt.Errorf("robot: primary cluster %s of %s is a standby", m.Primary, m)
+		}
+	}
+	return nil
+}
+
+// checkHealth checks primary clusters periodically until the robot stops.
+func (c *controller) checkHealth() {
+	primaries := make(map[*member]int)
+	for _, m := range c.clusters {
+		if m.primary != nil {
+			primaries[m.primary] = 0
+		}
+	}
+	if len(primaries) == 0 {
+		return
+	}
+
+	interval := c.opts.HealthCheckInterval
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			for m, failures := range primaries {
+				primaries[m] = c.probe(m, failures)
+			}
+		}
+	}
+}
+
+// probe checks the health of the member, and returns the number of successive failures.
+func (c *controller) probe(m *member, failures int) int {
+	c.mu.Lock()
+	client := m.client
+	c.mu.Unlock()
+
+	if _, err := client.Discovery().ServerVersion(); err != nil {
+		failures++
+		if failures == healthCheckFailures {
+			atomic.StoreInt32(&m.unhealthy, 1)
+			klog.Infof("robot: cluster %s is unreachable, its standbys are activated", m)
+			c.report(&ErrClusterUnreachable{Cluster: m.String(), Err: err})
+		}
+		return failures
+	}
+
+	if !m.isHealthy() {
+		atomic.StoreInt32(&m.unhealthy, 0)
+		klog.Infof("robot: cluster %s is healthy again, its standbys are suppressed", m)
+	}
+	return 0
+}
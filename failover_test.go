@@ -0,0 +1,54 @@
+package robot
+
+import (
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestFailover(t *testing.T) {
+	primary := &member{Cluster: Cluster{MasterUrl: "https://one.example.com"}, client: fake.NewSimpleClientset()}
+	standby := &member{Cluster: Cluster{MasterUrl: "https://two.example.com", Primary: "https://one.example.com"}}
+
+	c := &controller{clusters: []*member{primary, standby}, errs: make(chan error, 10)}
+	if err := c.linkStandbys(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var sent []QueueObject
+	emit := c.emit(standby, RN{RType: Pods})
+	c.queue = &recordQueue{sent: &sent}
+
+	emit(QueueObject{Event: EventAdd, RType: Pods, Key: "default/one"}, nil)
+	if e, a := 0, len(sent); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	primary.unhealthy = 1
+	emit(QueueObject{Event: EventAdd, RType: Pods, Key: "default/one"}, nil)
+	if e, a := 1, len(sent); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if e, a := 0, c.probe(primary, 2); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if !primary.isHealthy() {
+		t.Errorf("expected the primary to be healthy")
+	}
+
+	bad := &controller{clusters: []*member{{Cluster: Cluster{MasterUrl: "https://two.example.com", Primary: "unknown"}}}}
+	if err := bad.linkStandbys(); err == nil {
+		t.Errorf("expected an error when the primary is not found")
+	}
+}
+
+// recordQueue records pushed items.
+type recordQueue struct {
+	queue
+	sent *[]QueueObject
+}
+
+func (q *recordQueue) push(item QueueObject) {
+	*q.sent = append(*q.sent, item)
+}
@@ -0,0 +1,678 @@
+package robot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// graphqlPath is the path of the GraphQL endpoint of the GraphQL server.
+const graphqlPath = "/graphql"
+
+// maxOwnerDepth bounds following owners of an object.
+const maxOwnerDepth = 10
+
+// maxQueryDepth bounds nested selections of a query, and maxQueryBytes
+// the body of a query posted.
+const (
+	maxQueryDepth = 16
+	maxQueryBytes = 1 << 20
+)
+
+// The GraphQL endpoint serves queries of a subset of GraphQL, which has fields,
+// aliases, literal arguments and nested selections up to maxQueryDepth, but no
+// fragments, variables, introspection or mutations, so it's meant for queries
+// written by hand or by curl, not for GraphiQL or generic GraphQL clients.
+// The schema is:
+//
+//	type Query {
+//		clusters(name: String): [Cluster]
+//	}
+//	type Cluster {
+//		name: String
+//		labels: Map
+//		namespaces(name: String): [Namespace]
+//		objects(resource: String!, namespace: String, name: String): [Object]
+//	}
+//	type Namespace {
+//		name: String
+//		pods(name: String): [Object]
+//		objects(resource: String!, name: String): [Object]
+//	}
+//	type Object {
+//		cluster, resource, kind, namespace, name, uid, resourceVersion: String
+//		labels, annotations: Map
+//		field(path: String!): JSON
+//		object: JSON
+//		owner: Object
+//		deployment: Object
+//	}
+//
+// Resources are in the form of ParseResource, and objects are the cached ones,
+// e.g. owners of pods are resolved only if their resources are watched.
+
+// gqlField is a field of a selection set.
+type gqlField struct {
+	alias  string
+	name   string
+	args   map[string]string
+	fields []gqlField
+}
+
+// gqlQuery, gqlCluster, gqlNamespace and gqlObject are the values of the types of the schema.
+type (
+	gqlQuery struct {
+		clusters []*member
+	}
+
+	gqlCluster struct {
+		m *member
+	}
+
+	gqlNamespace struct {
+		m    *member
+		name string
+	}
+
+	gqlObject struct {
+		m   *member
+		r   Resource
+		obj interface{}
+	}
+)
+
+// gqlResolver resolves a field of a value, with the arguments of the field.
+type gqlResolver func(parent interface{}, args map[string]string) (interface{}, error)
+
+// gqlTypes are the resolvers of fields of each type of the schema.
+var gqlTypes = map[string]map[string]gqlResolver{
+	"Query": {
+		"clusters": func(parent interface{}, args map[string]string) (interface{}, error) {
+			var clusters []interface{}
+			for _, m := range parent.(gqlQuery).clusters {
+				if name, ok := args["name"]; !ok || m.is(name) {
+					clusters = append(clusters, gqlCluster{m})
+				}
+			}
+			return clusters, nil
+		},
+	},
+	"Cluster": {
+		"name": func(parent interface{}, _ map[string]string) (interface{}, error) {
+			return parent.(gqlCluster).m.String(), nil
+		},
+		"labels": func(parent interface{}, _ map[string]string) (interface{}, error) {
+			return parent.(gqlCluster).m.Labels, nil
+		},
+		"namespaces": func(parent interface{}, args map[string]string) (interface{}, error) {
+			m := parent.(gqlCluster).m
+			var namespaces []interface{}
+			for _, name := range m.namespaceNames() {
+				if want, ok := args["name"]; !ok || want == name {
+					namespaces = append(namespaces, gqlNamespace{m, name})
+				}
+			}
+			return namespaces, nil
+		},
+		"objects": func(parent interface{}, args map[string]string) (interface{}, error) {
+			r, err := ParseResource(args["resource"])
+			if err != nil {
+				return nil, err
+			}
+			return parent.(gqlCluster).m.gqlObjects(r, args["namespace"], args["name"]), nil
+		},
+	},
+	"Namespace": {
+		"name": func(parent interface{}, _ map[string]string) (interface{}, error) {
+			return parent.(gqlNamespace).name, nil
+		},
+		"pods": func(parent interface{}, args map[string]string) (interface{}, error) {
+			ns := parent.(gqlNamespace)
+			return ns.m.gqlObjects(Pods, ns.name, args["name"]), nil
+		},
+		"objects": func(parent interface{}, args map[string]string) (interface{}, error) {
+			r, err := ParseResource(args["resource"])
+			if err != nil {
+				return nil, err
+			}
+			ns := parent.(gqlNamespace)
+			return ns.m.gqlObjects(r, ns.name, args["name"]), nil
+		},
+	},
+	"Object": {
+		"cluster": func(parent interface{}, _ map[string]string) (interface{}, error) {
+			return parent.(gqlObject).m.String(), nil
+		},
+		"resource": func(parent interface{}, _ map[string]string) (interface{}, error) {
+			return parent.(gqlObject).r.String(), nil
+		},
+		"kind": func(parent interface{}, _ map[string]string) (interface{}, error) {
+			o := parent.(gqlObject)
+			resource, _ := o.m.apiResource(o.r)
+			return resource.Kind, nil
+		},
+		"namespace": gqlMeta(func(o metav1.Object) interface{} { return o.GetNamespace() }),
+		"name":      gqlMeta(func(o metav1.Object) interface{} { return o.GetName() }),
+		"uid":       gqlMeta(func(o metav1.Object) interface{} { return string(o.GetUID()) }),
+		"resourceVersion": gqlMeta(func(o metav1.Object) interface{} {
+			return o.GetResourceVersion()
+		}),
+		"labels":      gqlMeta(func(o metav1.Object) interface{} { return o.GetLabels() }),
+		"annotations": gqlMeta(func(o metav1.Object) interface{} { return o.GetAnnotations() }),
+		"field": func(parent interface{}, args map[string]string) (interface{}, error) {
+			content, err := objectContent(parent.(gqlObject).served())
+			if err != nil {
+				return nil, err
+			}
+			value, _, err := unstructured.NestedFieldNoCopy(content, strings.Split(args["path"], ".")...)
+			return value, err
+		},
+		"object": func(parent interface{}, _ map[string]string) (interface{}, error) {
+			return parent.(gqlObject).served(), nil
+		},
+		"owner": func(parent interface{}, _ map[string]string) (interface{}, error) {
+			if owner, ok := parent.(gqlObject).owner(); ok {
+				return owner, nil
+			}
+			return nil, nil
+		},
+		"deployment": func(parent interface{}, _ map[string]string) (interface{}, error) {
+			o := parent.(gqlObject)
+			for i := 0; i < maxOwnerDepth; i++ {
+				owner, ok := o.owner()
+				if !ok {
+					break
+				}
+				if resource, _ := owner.m.apiResource(owner.r); resource.Kind == "Deployment" {
+					return owner, nil
+				}
+				o = owner
+			}
+			return nil, nil
+		},
+	},
+}
+
+// gqlMeta returns a resolver of metadata of objects.
+func gqlMeta(get func(metav1.Object) interface{}) gqlResolver {
+	return func(parent interface{}, _ map[string]string) (interface{}, error) {
+		accessor, err := meta.Accessor(parent.(gqlObject).obj)
+		if err != nil {
+			return nil, err
+		}
+		return get(accessor), nil
+	}
+}
+
+// gqlTypeOf returns the type of a value of the schema, empty for scalars.
+func gqlTypeOf(value interface{}) string {
+	switch value.(type) {
+	case gqlQuery:
+		return "Query"
+	case gqlCluster:
+		return "Cluster"
+	case gqlNamespace:
+		return "Namespace"
+	case gqlObject:
+		return "Object"
+	}
+	return ""
+}
+
+// namespaceNames returns the sorted namespaces of cached objects of the member.
+func (m *member) namespaceNames() []string {
+	seen := make(map[string]bool)
+	for i, rn := range m.Resources {
+		for _, obj := range m.indexers[i].List() {
+			accessor, err := meta.Accessor(obj)
+			if err != nil {
+				continue
+			}
+			if rn.RType == Namespaces {
+				seen[accessor.GetName()] = true
+			} else if ns := accessor.GetNamespace(); ns != "" {
+				seen[ns] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// gqlObjects returns the cached objects of the resource in the member sorted by key,
+// filtered by namespace and name if they are not empty.
+func (m *member) gqlObjects(r Resource, namespace, name string) []interface{} {
+	var keys []string
+	objects := make(map[string]gqlObject)
+	for i, rn := range m.Resources {
+		if rn.RType != r {
+			continue
+		}
+		for _, obj := range m.indexers[i].List() {
+			accessor, err := meta.Accessor(obj)
+			if err != nil {
+				continue
+			}
+			if namespace != "" && accessor.GetNamespace() != namespace || name != "" && accessor.GetName() != name {
+				continue
+			}
+			key := accessor.GetNamespace() + "/" + accessor.GetName()
+			if _, ok := objects[key]; !ok {
+				keys = append(keys, key)
+			}
+			objects[key] = gqlObject{m: m, r: r, obj: obj}
+		}
+	}
+	sort.Strings(keys)
+	list := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		list = append(list, objects[key])
+	}
+	return list
+}
+
+// served returns the object served by field and object, Secrets are redacted
+// as /objects does.
+func (o gqlObject) served() interface{} {
+	if o.r == Secrets {
+		return redactSecret(o.obj)
+	}
+	return o.obj
+}
+
+// owner returns the cached controller of the object.
+func (o gqlObject) owner() (gqlObject, bool) {
+	accessor, err := meta.Accessor(o.obj)
+	if err != nil {
+		return gqlObject{}, false
+	}
+	ref := metav1.GetControllerOf(accessor)
+	if ref == nil {
+		return gqlObject{}, false
+	}
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return gqlObject{}, false
+	}
+
+	for i, rn := range o.m.Resources {
+		if rn.RType.Group != gv.Group {
+			continue
+		}
+		if resource, ok := o.m.apiResource(rn.RType); !ok || resource.Kind != ref.Kind {
+			continue
+		}
+		if obj, exists, err := getByName(o.m.indexers[i], accessor.GetNamespace(), ref.Name); err == nil && exists {
+			return gqlObject{m: o.m, r: rn.RType, obj: obj}, true
+		}
+	}
+	return gqlObject{}, false
+}
+
+// objectContent returns the content of an object as unstructured.
+func objectContent(obj interface{}) (map[string]interface{}, error) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u.Object, nil
+	}
+	o, ok := obj.(runtime.Object)
+	if !ok {
+		return nil, fmt.Errorf("robot: unexpected object %T", obj)
+	}
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(o)
+}
+
+// gqlResult is an object of a response, which keeps the order of fields.
+type gqlResult []gqlEntry
+
+type gqlEntry struct {
+	key   string
+	value interface{}
+}
+
+func (r gqlResult) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, entry := range r {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(entry.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(entry.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// execute resolves the fields of the value.
+func execute(value interface{}, fields []gqlField) (gqlResult, error) {
+	typ := gqlTypeOf(value)
+	result := make(gqlResult, 0, len(fields))
+	for _, f := range fields {
+		key := f.name
+		if f.alias != "" {
+			key = f.alias
+		}
+		if f.name == "__typename" {
+			result = append(result, gqlEntry{key, typ})
+			continue
+		}
+
+		resolve, ok := gqlTypes[typ][f.name]
+		if !ok {
+			return nil, fmt.Errorf("robot: unknown field %s of %s", f.name, typ)
+		}
+		resolved, err := resolve(value, f.args)
+		if err != nil {
+			return nil, fmt.Errorf("robot: resolve %s of %s: %v", f.name, typ, err)
+		}
+		completed, err := complete(f, resolved)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, gqlEntry{key, completed})
+	}
+	return result, nil
+}
+
+// complete resolves the selection of the field of the resolved value.
+func complete(f gqlField, resolved interface{}) (interface{}, error) {
+	switch v := resolved.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		list := make([]interface{}, 0, len(v))
+		for _, one := range v {
+			completed, err := complete(f, one)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, completed)
+		}
+		return list, nil
+	}
+
+	if gqlTypeOf(resolved) == "" {
+		if len(f.fields) > 0 {
+			return nil, fmt.Errorf("robot: field %s can't have selections", f.name)
+		}
+		return resolved, nil
+	}
+	if len(f.fields) == 0 {
+		return nil, fmt.Errorf("robot: field %s of %s must have selections", f.name, gqlTypeOf(resolved))
+	}
+	return execute(resolved, f.fields)
+}
+
+// gqlRequest is a GraphQL request, queries of GET requests are in the query string.
+type gqlRequest struct {
+	Query string `json:"query"`
+}
+
+type gqlError struct {
+	Message string `json:"message"`
+}
+
+type gqlResponse struct {
+	Data   interface{} `json:"data"`
+	Errors []gqlError  `json:"errors,omitempty"`
+}
+
+// serveGraphQL serves GraphQL queries of the cached objects of all clusters.
+func (c *controller) serveGraphQL(w http.ResponseWriter, req *http.Request) {
+	var request gqlRequest
+	switch req.Method {
+	case http.MethodGet:
+		request.Query = req.URL.Query().Get("query")
+	case http.MethodPost:
+		body := http.MaxBytesReader(w, req.Body, maxQueryBytes)
+		if err := json.NewDecoder(body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var response gqlResponse
+	if data, err := c.query(request.Query); err != nil {
+		response.Errors = []gqlError{{Message: err.Error()}}
+	} else {
+		response.Data = data
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		c.report(fmt.Errorf("robot: write graphql response: %v", err))
+	}
+}
+
+// query executes the GraphQL query.
+func (c *controller) query(query string) (gqlResult, error) {
+	fields, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	clusters := append([]*member(nil), c.clusters...)
+	c.mu.Unlock()
+
+	return execute(gqlQuery{clusters}, fields)
+}
+
+// parseQuery parses a query document of the supported subset of GraphQL.
+func parseQuery(query string) ([]gqlField, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &gqlParser{tokens: tokens}
+
+	if p.peek() == "query" {
+		p.next()
+		if isName(p.peek()) {
+			p.next()
+		}
+	}
+	fields, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != "" {
+		return nil, fmt.Errorf("robot: unexpected %q after the query", p.peek())
+	}
+	return fields, nil
+}
+
+// tokenize splits the query into names, numbers, quoted strings and punctuators.
+func tokenize(query string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(query); {
+		ch := query[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == ',':
+			i++
+		case ch == '#':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case strings.IndexByte("{}():!$", ch) >= 0:
+			tokens = append(tokens, query[i:i+1])
+			i++
+		case ch == '"':
+			j := i + 1
+			for ; j < len(query) && query[j] != '"'; j++ {
+				if query[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(query) {
+				return nil, fmt.Errorf("robot: unterminated string in the query")
+			}
+			tokens = append(tokens, query[i:j+1])
+			i = j + 1
+		case isNameByte(ch) || ch == '-':
+			j := i + 1
+			for j < len(query) && (isNameByte(query[j]) || query[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, query[i:j])
+			i = j
+		default:
+			return nil, fmt.Errorf("robot: unexpected character %q in the query", ch)
+		}
+	}
+	return tokens, nil
+}
+
+func isNameByte(ch byte) bool {
+	return ch == '_' || 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9'
+}
+
+func isName(token string) bool {
+	if token == "" || '0' <= token[0] && token[0] <= '9' {
+		return false
+	}
+	for i := 0; i < len(token); i++ {
+		if !isNameByte(token[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+type gqlParser struct {
+	tokens []string
+	pos    int
+	// depth is the number of selection sets being parsed.
+	depth int
+}
+
+// peek returns the next token, empty at the end.
+func (p *gqlParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *gqlParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *gqlParser) expect(token string) error {
+	if a := p.next(); a != token {
+		return fmt.Errorf("robot: expected %q in the query, got %q", token, a)
+	}
+	return nil
+}
+
+func (p *gqlParser) name() (string, error) {
+	token := p.next()
+	if !isName(token) {
+		return "", fmt.Errorf("robot: expected a name in the query, got %q", token)
+	}
+	return token, nil
+}
+
+func (p *gqlParser) selectionSet() ([]gqlField, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	if p.depth++; p.depth > maxQueryDepth {
+		return nil, fmt.Errorf("robot: selections of the query are nested over %d levels", maxQueryDepth)
+	}
+	defer func() { p.depth-- }()
+	var fields []gqlField
+	for p.peek() != "}" {
+		if p.peek() == "" {
+			return nil, fmt.Errorf("robot: unterminated selection in the query")
+		}
+		f, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	p.next()
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("robot: empty selection in the query")
+	}
+	return fields, nil
+}
+
+func (p *gqlParser) field() (f gqlField, err error) {
+	if f.name, err = p.name(); err != nil {
+		return
+	}
+	if p.peek() == ":" {
+		p.next()
+		f.alias = f.name
+		if f.name, err = p.name(); err != nil {
+			return
+		}
+	}
+	if p.peek() == "(" {
+		if f.args, err = p.arguments(); err != nil {
+			return
+		}
+	}
+	if p.peek() == "{" {
+		f.fields, err = p.selectionSet()
+	}
+	return
+}
+
+func (p *gqlParser) arguments() (map[string]string, error) {
+	p.next()
+	args := make(map[string]string)
+	for p.peek() != ")" {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		value := p.next()
+		switch {
+		case strings.HasPrefix(value, `"`):
+			if value, err = strconv.Unquote(value); err != nil {
+				return nil, fmt.Errorf("robot: invalid string of argument %s in the query: %v", name, err)
+			}
+		case value == "$":
+			return nil, fmt.Errorf("robot: variables are not supported")
+		case value == "" || strings.IndexByte("{}():!", value[0]) >= 0:
+			return nil, fmt.Errorf("robot: expected a value of argument %s in the query, got %q", name, value)
+		}
+		args[name] = value
+	}
+	p.next()
+	return args, nil
+}
@@ -0,0 +1,153 @@
+package robot
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestServeGraphQL(t *testing.T) {
+	replicaSets := Resource{Group: "apps", Version: "v1", Resource: "replicasets"}
+	deployments := Resource{Group: "apps", Version: "v1", Resource: "deployments"}
+	isController := true
+	owned := func(kind, name string) []metav1.OwnerReference {
+		return []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: kind, Name: name, Controller: &isController}}
+	}
+	object := func(kind, name string, owners []metav1.OwnerReference) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion("apps/v1")
+		u.SetKind(kind)
+		u.SetNamespace("default")
+		u.SetName(name)
+		u.SetOwnerReferences(owners)
+		return u
+	}
+
+	pods := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	pods.Add(&v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-1", OwnerReferences: owned("ReplicaSet", "web-abc")},
+		Status:     v1.PodStatus{Phase: v1.PodRunning},
+	})
+	pods.Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "single"}})
+	rs := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	rs.Add(object("ReplicaSet", "web-abc", owned("Deployment", "web")))
+	deploys := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	deploys.Add(object("Deployment", "web", nil))
+
+	c := &controller{clusters: []*member{
+		{
+			Cluster:  Cluster{Name: "one", Resources: []RN{{RType: Pods}, {RType: replicaSets}, {RType: deployments}}},
+			indexers: []cache.Indexer{pods, rs, deploys},
+			served: map[Resource]metav1.APIResource{
+				replicaSets: {Name: "replicasets", Namespaced: true, Kind: "ReplicaSet"},
+				deployments: {Name: "deployments", Namespaced: true, Kind: "Deployment"},
+			},
+		},
+		{
+			Cluster:  Cluster{Name: "two", Resources: []RN{{RType: Pods}}},
+			indexers: []cache.Indexer{cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})},
+		},
+	}}
+	server := httptest.NewServer(http.HandlerFunc(c.serveGraphQL))
+	defer server.Close()
+
+	query := `query Inventory {
+		clusters(name: "one") {
+			name
+			namespaces(name: "default") {
+				name
+				pods {
+					name
+					phase: field(path: "status.phase")
+					owner { kind name }
+					deployment { __typename name }
+				}
+			}
+		}
+	}`
+	body, _ := json.Marshal(gqlRequest{Query: query})
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(string(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := `{"data":{"clusters":[{"name":"one","namespaces":[{"name":"default","pods":[{"name":"web-1","phase":"Running",` +
+		`"owner":{"kind":"ReplicaSet","name":"web-abc"},"deployment":{"__typename":"Object","name":"web"}}]}]}]}}` + "\n"
+	if a := string(data); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	for query, e := range map[string]string{
+		`{ clusters { name } }`:                             `{"data":{"clusters":[{"name":"one"},{"name":"two"}]}}`,
+		`{ clusters(name: "one") { namespaces { name } } }`: `{"data":{"clusters":[{"namespaces":[{"name":"default"},{"name":"other"}]}]}}`,
+		`{ clusters(name: "one") { objects(resource: "apps/v1/deployments") { deployment { name } } } }`: `{"data":{"clusters":[{"objects":[{"deployment":null}]}]}}`,
+		`{ clusters { unknown } }`:           `{"data":null,"errors":[{"message":"robot: unknown field unknown of Cluster"}]}`,
+		`{ clusters }`:                       `{"data":null,"errors":[{"message":"robot: field clusters of Cluster must have selections"}]}`,
+		`{ clusters(name: $name) { name } }`: `{"data":null,"errors":[{"message":"robot: variables are not supported"}]}`,
+		`{ clusters { name }`:                `{"data":null,"errors":[{"message":"robot: unterminated selection in the query"}]}`,
+	} {
+		resp, err := http.Get(server.URL + "?query=" + url.QueryEscape(query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a := strings.TrimSpace(string(data)); e != a {
+			t.Errorf("expected %v of %s, got %v", e, query, a)
+		}
+	}
+}
+
+func TestGraphQLLimits(t *testing.T) {
+	deep := strings.Repeat("{ a ", 100000) + strings.Repeat("}", 100000)
+	if _, err := parseQuery(deep); err == nil {
+		t.Errorf("expected an error of a query nested too deep")
+	}
+
+	c := &controller{}
+	body, _ := json.Marshal(gqlRequest{Query: strings.Repeat(" ", maxQueryBytes)})
+	req := httptest.NewRequest(http.MethodPost, graphqlPath, strings.NewReader(string(body)))
+	w := httptest.NewRecorder()
+	c.serveGraphQL(w, req)
+	if e, a := http.StatusBadRequest, w.Code; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestGraphQLRedactsSecrets(t *testing.T) {
+	secrets := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	secrets.Add(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "token"},
+		Data:       map[string][]byte{"password": []byte("hunter2")},
+	})
+	c := &controller{clusters: []*member{{
+		Cluster:  Cluster{Name: "one", Resources: []RN{{RType: Secrets}}},
+		indexers: []cache.Indexer{secrets},
+	}}}
+
+	query := `{ clusters { objects(resource: "v1/secrets") { password: field(path: "data.password") object } } }`
+	req := httptest.NewRequest(http.MethodGet, graphqlPath+"?query="+url.QueryEscape(query), nil)
+	w := httptest.NewRecorder()
+	c.serveGraphQL(w, req)
+	if body := w.Body.String(); strings.Contains(body, "hunter2") || strings.Contains(body, "aHVudGVyMg") {
+		t.Errorf("expected the data of the Secret redacted, got %s", body)
+	} else if !strings.Contains(body, `"data":{"password":null}`) {
+		t.Errorf("expected the Secret served, got %s", body)
+	}
+}
@@ -0,0 +1,65 @@
+package robot
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/cache"
+)
+
+// HandlerFunc returns the handler of events of the resource in the cluster,
+// instead of def, the default handler which sends events with send, e.g. to
+// wrap def with side effects, or to send events keyed differently.
+type HandlerFunc func(cluster string, r RN, def cache.ResourceEventHandler, send func(QueueObject, interface{})) cache.ResourceEventHandler
+
+// handler returns the handler of events of the resource,
+// overridden by Options.Handlers, which drops objects of namespaces tombstoned.
+func (m *member) handler(r RN, emit emitFunc, report func(error)) cache.ResourceEventHandler {
+	return m.tombstones.handler(m.newHandler(r, emit, report))
+}
+
+func (m *member) newHandler(r RN, emit emitFunc, report func(error)) cache.ResourceEventHandler {
+	var def cache.ResourceEventHandler = initHandle(r.RType, m.key, emit, report)
+	if r.GenerationChanged {
+		def = generationChanged(def)
+	}
+
+	override, ok := m.handlers[r.RType]
+	if !ok {
+		return def
+	}
+	h := override(m.String(), r, def, emit)
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			defer handleCrash(report, "%s add handler of cluster %s", r.RType, m)
+
+			h.OnAdd(obj)
+		},
+		UpdateFunc: func(old, new interface{}) {
+			defer handleCrash(report, "%s update handler of cluster %s", r.RType, m)
+
+			h.OnUpdate(old, new)
+		},
+		DeleteFunc: func(obj interface{}) {
+			defer handleCrash(report, "%s delete handler of cluster %s", r.RType, m)
+
+			h.OnDelete(obj)
+		},
+	}
+}
+
+// generationChanged passes updates to h only if metadata.generation changed.
+// Updates of objects without generations, which are unknown, are all passed.
+func generationChanged(h cache.ResourceEventHandler) cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: h.OnAdd,
+		UpdateFunc: func(old, new interface{}) {
+			oldMeta, err1 := meta.Accessor(old)
+			curMeta, err2 := meta.Accessor(new)
+			if err1 == nil && err2 == nil && curMeta.GetGeneration() != 0 &&
+				oldMeta.GetGeneration() == curMeta.GetGeneration() {
+				return
+			}
+			h.OnUpdate(old, new)
+		},
+		DeleteFunc: h.OnDelete,
+	}
+}
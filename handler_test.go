@@ -0,0 +1,92 @@
+package robot
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestHandlerOverride(t *testing.T) {
+	var sent []QueueObject
+	var added []string
+	m := &member{
+		Cluster: Cluster{MasterUrl: "https://one.example.com"},
+		handlers: map[Resource]HandlerFunc{
+			Pods: func(cluster string, r RN, def cache.ResourceEventHandler, send func(QueueObject, interface{})) cache.ResourceEventHandler {
+				return cache.ResourceEventHandlerFuncs{
+					AddFunc: func(obj interface{}) {
+						added = append(added, cluster)
+						send(QueueObject{Event: EventAdd, RType: r.RType, Key: obj.(*v1.Pod).Name}, obj)
+					},
+					DeleteFunc: func(obj interface{}) {
+						panic("delete")
+					},
+				}
+			},
+		},
+	}
+	emit := func(item QueueObject, obj interface{}) {
+		sent = append(sent, item)
+	}
+	var errs []error
+	report := func(err error) {
+		errs = append(errs, err)
+	}
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "one"}}
+	h := m.handler(RN{RType: Pods}, emit, report)
+	h.OnAdd(pod)
+	h.OnDelete(pod)
+
+	if e, a := 1, len(sent); e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	if e, a := "one", sent[0].Key; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := 1, len(added); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := 1, len(errs); e != a {
+		t.Errorf("expected %v errors, got %v", e, a)
+	}
+
+	// Resources without overrides use the default handler.
+	m.handler(RN{RType: Services}, emit, report).OnAdd(&v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "one"}})
+	if e, a := "default/one", sent[len(sent)-1].Key; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestGenerationChanged(t *testing.T) {
+	var sent []QueueObject
+	emit := func(item QueueObject, obj interface{}) {
+		sent = append(sent, item)
+	}
+	m := &member{Cluster: Cluster{MasterUrl: "https://one.example.com"}}
+	h := m.handler(RN{RType: Pods, GenerationChanged: true}, emit, func(error) {})
+
+	pod := func(rv string, generation int64) *v1.Pod {
+		return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "one", ResourceVersion: rv, Generation: generation}}
+	}
+	h.OnAdd(pod("1", 1))
+	// status only
+	h.OnUpdate(pod("1", 1), pod("2", 1))
+	// spec
+	h.OnUpdate(pod("2", 1), pod("3", 2))
+	// no generations
+	h.OnUpdate(pod("3", 0), pod("4", 0))
+	h.OnDelete(pod("4", 2))
+
+	var events []event
+	for _, item := range sent {
+		events = append(events, item.Event)
+	}
+	e := []event{EventAdd, EventUpdate, EventUpdate, EventDelete}
+	if a := events; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
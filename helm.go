@@ -0,0 +1,204 @@
+package robot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ReleaseAction is what happened to a Helm release.
+type ReleaseAction string
+
+const (
+	ReleaseInstalled   ReleaseAction = "installed"
+	ReleaseUpgraded    ReleaseAction = "upgraded"
+	ReleaseRolledBack  ReleaseAction = "rolled back"
+	ReleaseFailed      ReleaseAction = "failed"
+	ReleaseUninstalled ReleaseAction = "uninstalled"
+)
+
+// Release is a revision of a Helm release.
+type Release struct {
+	Cluster     string
+	Namespace   string
+	Name        string
+	Revision    int
+	Status      string
+	Description string
+
+	Chart        string
+	ChartVersion string
+	AppVersion   string
+}
+
+// ReleaseEvent is an event of a Helm release.
+type ReleaseEvent struct {
+	Action  ReleaseAction
+	Release Release
+}
+
+// helmRelease is the part of a release kept by the storage of Helm 3.
+type helmRelease struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Info      struct {
+		Status      string `json:"status"`
+		Description string `json:"description"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			Name       string `json:"name"`
+			Version    string `json:"version"`
+			AppVersion string `json:"appVersion"`
+		} `json:"metadata"`
+	} `json:"chart"`
+}
+
+const (
+	helmStatusDeployed    = "deployed"
+	helmStatusFailed      = "failed"
+	helmStatusUninstalled = "uninstalled"
+
+	helmStatusUninstalling = "uninstalling"
+)
+
+// HelmReleases returns a HandlerFunc of Secrets or ConfigMaps, which decodes
+// the release storage objects of Helm 3 and calls fn with events of releases,
+// before the default handler sends the events of the objects.
+// A revision is installed, upgraded or rolled back once it's deployed, so
+// the releases deployed are sent at start too.
+//
+//	Options{Handlers: map[Resource]HandlerFunc{Secrets: HelmReleases(fn)}}
+func HelmReleases(fn func(ReleaseEvent)) HandlerFunc {
+	return func(cluster string, r RN, def cache.ResourceEventHandler, send func(QueueObject, interface{})) cache.ResourceEventHandler {
+		decode := func(obj interface{}) (Release, bool) {
+			release, ok, err := decodeRelease(obj)
+			if err != nil || !ok {
+				return Release{}, false
+			}
+			release.Cluster = cluster
+			return release, true
+		}
+		return cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				if release, ok := decode(obj); ok {
+					if action, ok := releaseAction("", release); ok {
+						fn(ReleaseEvent{action, release})
+					}
+				}
+				def.OnAdd(obj)
+			},
+			UpdateFunc: func(old, new interface{}) {
+				if release, ok := decode(new); ok {
+					var status string
+					if last, ok := decode(old); ok {
+						status = last.Status
+					}
+					if action, ok := releaseAction(status, release); ok {
+						fn(ReleaseEvent{action, release})
+					}
+				}
+				def.OnUpdate(old, new)
+			},
+			DeleteFunc: func(obj interface{}) {
+				last := obj
+				if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+					last = tombstone.Obj
+				}
+				// Deleting the deployed or uninstalling revision uninstalls
+				// the release, other revisions are history pruned.
+				if release, ok := decode(last); ok && (release.Status == helmStatusDeployed || release.Status == helmStatusUninstalling) {
+					fn(ReleaseEvent{ReleaseUninstalled, release})
+				}
+				def.OnDelete(obj)
+			},
+		}
+	}
+}
+
+// releaseAction returns the action of the release turning from the status, if any.
+func releaseAction(status string, release Release) (ReleaseAction, bool) {
+	if status == release.Status {
+		return "", false
+	}
+	switch release.Status {
+	case helmStatusDeployed:
+		switch {
+		case strings.HasPrefix(release.Description, "Rollback"):
+			return ReleaseRolledBack, true
+		case release.Revision == 1:
+			return ReleaseInstalled, true
+		}
+		return ReleaseUpgraded, true
+	case helmStatusFailed:
+		return ReleaseFailed, true
+	case helmStatusUninstalled:
+		return ReleaseUninstalled, true
+	}
+	return "", false
+}
+
+// decodeRelease decodes the release of a storage object of Helm 3,
+// it returns false if obj isn't one.
+func decodeRelease(obj interface{}) (Release, bool, error) {
+	var owner, data string
+	switch o := obj.(type) {
+	case *v1.Secret:
+		owner, data = o.Labels["owner"], string(o.Data["release"])
+	case *v1.ConfigMap:
+		owner, data = o.Labels["owner"], o.Data["release"]
+	case *unstructured.Unstructured:
+		owner = o.GetLabels()["owner"]
+		data, _, _ = unstructured.NestedString(o.Object, "data", "release")
+		if o.GetKind() == "Secret" {
+			decoded, err := base64.StdEncoding.DecodeString(data)
+			if err != nil {
+				return Release{}, false, err
+			}
+			data = string(decoded)
+		}
+	default:
+		return Release{}, false, nil
+	}
+	if owner != "helm" || data == "" {
+		return Release{}, false, nil
+	}
+
+	b, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return Release{}, false, fmt.Errorf("robot: decode helm release: %v", err)
+	}
+	if bytes.HasPrefix(b, []byte{0x1f, 0x8b}) {
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return Release{}, false, fmt.Errorf("robot: decode helm release: %v", err)
+		}
+		if b, err = ioutil.ReadAll(r); err != nil {
+			return Release{}, false, fmt.Errorf("robot: decode helm release: %v", err)
+		}
+	}
+	var release helmRelease
+	if err := json.Unmarshal(b, &release); err != nil {
+		return Release{}, false, fmt.Errorf("robot: decode helm release: %v", err)
+	}
+
+	return Release{
+		Namespace:    release.Namespace,
+		Name:         release.Name,
+		Revision:     release.Version,
+		Status:       release.Info.Status,
+		Description:  release.Info.Description,
+		Chart:        release.Chart.Metadata.Name,
+		ChartVersion: release.Chart.Metadata.Version,
+		AppVersion:   release.Chart.Metadata.AppVersion,
+	}, true, nil
+}
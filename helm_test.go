@@ -0,0 +1,91 @@
+package robot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// helmSecret returns a release storage Secret of Helm 3.
+func helmSecret(t *testing.T, name string, revision int, status, description string) *v1.Secret {
+	release := map[string]interface{}{
+		"name":      name,
+		"namespace": "default",
+		"version":   revision,
+		"info":      map[string]interface{}{"status": status, "description": description},
+		"chart": map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "nginx", "version": "1.2.3", "appVersion": "1.19"},
+		},
+	}
+	b, err := json.Marshal(release)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(b)
+	w.Close()
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "sh.helm.release.v1." + name,
+			Labels:    map[string]string{"owner": "helm", "name": name, "status": status},
+		},
+		Type: "helm.sh/release.v1",
+		Data: map[string][]byte{"release": []byte(base64.StdEncoding.EncodeToString(buf.Bytes()))},
+	}
+}
+
+func TestHelmReleases(t *testing.T) {
+	var events []ReleaseEvent
+	var sent []QueueObject
+	m := &member{
+		Cluster:  Cluster{Name: "east"},
+		handlers: map[Resource]HandlerFunc{Secrets: HelmReleases(func(e ReleaseEvent) { events = append(events, e) })},
+	}
+	h := m.handler(RN{RType: Secrets}, func(item QueueObject, obj interface{}) {
+		sent = append(sent, item)
+	}, func(err error) {
+		t.Errorf("unexpected error: %v", err)
+	})
+
+	installing := helmSecret(t, "web", 1, "pending-install", "Initial install underway")
+	installed := helmSecret(t, "web", 1, "deployed", "Install complete")
+	h.OnAdd(installing)
+	h.OnUpdate(installing, installed)
+	h.OnUpdate(installed, installed)
+	h.OnUpdate(installed, helmSecret(t, "web", 1, "superseded", "Upgrade complete"))
+	h.OnAdd(helmSecret(t, "web", 2, "deployed", "Upgrade complete"))
+	h.OnAdd(helmSecret(t, "web", 3, "failed", "Upgrade failed"))
+	h.OnAdd(helmSecret(t, "web", 4, "deployed", "Rollback to 2"))
+	h.OnDelete(helmSecret(t, "web", 3, "failed", "Upgrade failed"))
+	h.OnDelete(cache.DeletedFinalStateUnknown{Key: "default/web", Obj: helmSecret(t, "web", 4, "uninstalling", "Deletion in progress")})
+	h.OnAdd(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "token"}})
+
+	var actions []ReleaseAction
+	for _, e := range events {
+		actions = append(actions, e.Action)
+	}
+	e := []ReleaseAction{ReleaseInstalled, ReleaseUpgraded, ReleaseFailed, ReleaseRolledBack, ReleaseUninstalled}
+	if a := actions; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	release := Release{
+		Cluster: "east", Namespace: "default", Name: "web", Revision: 1, Status: "deployed", Description: "Install complete",
+		Chart: "nginx", ChartVersion: "1.2.3", AppVersion: "1.19",
+	}
+	if a := events[0].Release; release != a {
+		t.Errorf("expected %v, got %v", release, a)
+	}
+	// Events of the objects are still sent.
+	if e, a := 10, len(sent); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
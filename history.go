@@ -0,0 +1,125 @@
+package robot
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+// historyRetention is how long the history of a deleted object is kept.
+const historyRetention = time.Hour
+
+// ObjectVersion is a version of an object in its history.
+type ObjectVersion struct {
+	Event  event
+	Time   time.Time
+	Object interface{}
+}
+
+type historyKey struct {
+	resource Resource
+	key      string
+}
+
+// history keeps the last versions of each object of a cluster,
+// a nil history keeps nothing.
+type history struct {
+	size int
+
+	mu       sync.Mutex
+	versions map[historyKey][]ObjectVersion
+	// deleted are when deleted objects were deleted.
+	deleted map[historyKey]time.Time
+	pruned  time.Time
+}
+
+func newHistory(size int) *history {
+	if size <= 0 {
+		return nil
+	}
+	return &history{
+		size:     size,
+		versions: make(map[historyKey][]ObjectVersion),
+		deleted:  make(map[historyKey]time.Time),
+		pruned:   time.Now(),
+	}
+}
+
+func (h *history) record(item QueueObject, obj interface{}) {
+	if h == nil || item.Event == EventOrphan || item.Event == EventAlert || item.Event == EventReachability || item.Event == EventInvalid || item.Event == EventPolicy {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	key := historyKey{item.RType, item.Key}
+	versions := h.versions[key]
+	if n := len(versions); n > 0 && item.Event == EventUpdate && sameVersion(versions[n-1].Object, obj) {
+		// Replayed by Resync, it's not a new version.
+		return
+	}
+	versions = append(versions, ObjectVersion{item.Event, now, obj})
+	if len(versions) > h.size {
+		// Copy to release the dropped versions.
+		versions = append([]ObjectVersion(nil), versions[len(versions)-h.size:]...)
+	}
+	h.versions[key] = versions
+
+	if item.Event == EventDelete {
+		h.deleted[key] = now
+	} else {
+		delete(h.deleted, key)
+	}
+
+	if now.Sub(h.pruned) > historyRetention/10 {
+		for key, at := range h.deleted {
+			if now.Sub(at) > historyRetention {
+				delete(h.versions, key)
+				delete(h.deleted, key)
+			}
+		}
+		h.pruned = now
+	}
+}
+
+// get returns the versions of the object, from the oldest.
+func (h *history) get(resource Resource, key string) []ObjectVersion {
+	if h == nil {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]ObjectVersion(nil), h.versions[historyKey{resource, key}]...)
+}
+
+func (c *controller) History(cluster string, resource Resource, key string) ([]ObjectVersion, error) {
+	c.mu.Lock()
+	m := c.memberOf(cluster)
+	c.mu.Unlock()
+	if m == nil {
+		return nil, &ErrClusterNotFound{cluster}
+	}
+	if m.history == nil {
+		return nil, fmt.Errorf("robot: history is disabled")
+	}
+	return m.history.get(resource, key), nil
+}
+
+// sameVersion returns whether the objects have the same resourceVersion.
+func sameVersion(a, b interface{}) bool {
+	am, err := meta.Accessor(a)
+	if err != nil {
+		return false
+	}
+	bm, err := meta.Accessor(b)
+	if err != nil {
+		return false
+	}
+	return am.GetResourceVersion() != "" && am.GetResourceVersion() == bm.GetResourceVersion()
+}
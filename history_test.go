@@ -0,0 +1,45 @@
+package robot
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestHistory(t *testing.T) {
+	m := &member{Cluster: Cluster{MasterUrl: "https://one.example.com"}, history: newHistory(2)}
+	c := &controller{clusters: []*member{m}}
+
+	pod := func(rv string) *v1.Pod {
+		return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "one", ResourceVersion: rv}}
+	}
+	for _, one := range []struct {
+		event event
+		obj   *v1.Pod
+	}{
+		{EventAdd, pod("1")},
+		{EventUpdate, pod("2")},
+		{EventUpdate, pod("3")},
+		{EventUpdate, pod("3")},
+	} {
+		m.history.record(QueueObject{Event: one.event, RType: Pods, Key: "default/one"}, one.obj)
+	}
+
+	versions, err := c.History("https://one.example.com", Pods, "default/one")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := 2, len(versions); e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	for i, rv := range []string{"2", "3"} {
+		if e, a := rv, versions[i].Object.(*v1.Pod).ResourceVersion; e != a {
+			t.Errorf("expected %v, got %v", e, a)
+		}
+	}
+
+	if _, err := c.History("https://two.example.com", Pods, "default/one"); err == nil {
+		t.Errorf("expected error of unknown cluster")
+	}
+}
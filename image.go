@@ -0,0 +1,150 @@
+package robot
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// imageIndex indexes pods by images and digests of their containers.
+const imageIndex = "image"
+
+// ImageLocation is a pod running an image.
+type ImageLocation struct {
+	Cluster   string
+	Namespace string
+	Pod       string
+}
+
+// ImageFunc is called with an image the first time a pod runs it, see Options.OnNewImage.
+type ImageFunc func(image string, at ImageLocation)
+
+func imageIndexFunc(obj interface{}) ([]string, error) {
+	pod, ok := podOf(obj)
+	if !ok {
+		return nil, nil
+	}
+	return podImages(pod), nil
+}
+
+// podOf returns obj as a pod, false if it isn't one.
+func podOf(obj interface{}) (*v1.Pod, bool) {
+	switch o := obj.(type) {
+	case *v1.Pod:
+		return o, true
+	case *unstructured.Unstructured:
+		pod := &v1.Pod{}
+		return pod, fromUnstructured(o, "Pod", pod)
+	}
+	return nil, false
+}
+
+// fromUnstructured converts u into obj if it's of the kind of the core group.
+func fromUnstructured(u *unstructured.Unstructured, kind string, obj interface{}) bool {
+	if u.GetKind() != kind || u.GroupVersionKind().Group != "" {
+		return false
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, obj) == nil
+}
+
+// podImages returns the images of containers of the pod, and the digests of
+// the images they run, e.g. sha256:4f4e.
+func podImages(pod *v1.Pod) []string {
+	var images []string
+	seen := make(map[string]bool)
+	add := func(image string) {
+		if image != "" && !seen[image] {
+			seen[image] = true
+			images = append(images, image)
+		}
+	}
+	for _, c := range pod.Spec.InitContainers {
+		add(c.Image)
+	}
+	for _, c := range pod.Spec.Containers {
+		add(c.Image)
+	}
+	for _, statuses := range [][]v1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			if i := strings.LastIndex(status.ImageID, "@"); i >= 0 {
+				add(status.ImageID[i+1:])
+			} else if strings.HasPrefix(status.ImageID, "sha256:") {
+				add(status.ImageID)
+			}
+		}
+	}
+	return images
+}
+
+// imageSet is the images seen in pods of all clusters.
+type imageSet struct {
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func newImageSet() *imageSet {
+	return &imageSet{seen: make(map[string]bool)}
+}
+
+// observe calls fn with the images of obj which have not been seen,
+// if obj is a pod.
+func (s *imageSet) observe(cluster string, obj interface{}, fn ImageFunc) {
+	pod, ok := podOf(obj)
+	if !ok {
+		return
+	}
+
+	var images []string
+	s.mu.Lock()
+	for _, image := range podImages(pod) {
+		if !s.seen[image] {
+			s.seen[image] = true
+			images = append(images, image)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, image := range images {
+		fn(image, ImageLocation{Cluster: cluster, Namespace: pod.Namespace, Pod: pod.Name})
+	}
+}
+
+// ImageLocations returns the cached pods of all clusters running the image,
+// which is an image of containers, e.g. nginx:1.19, or a digest, e.g. sha256:4f4e.
+func (c *controller) ImageLocations(image string) []ImageLocation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var locations []ImageLocation
+	for _, m := range c.clusters {
+		for i, r := range m.Resources {
+			if r.RType != Pods {
+				continue
+			}
+			objs, err := m.indexers[i].ByIndex(imageIndex, image)
+			if err != nil {
+				continue
+			}
+			for _, obj := range objs {
+				if pod, ok := podOf(obj); ok {
+					locations = append(locations, ImageLocation{Cluster: m.String(), Namespace: pod.Namespace, Pod: pod.Name})
+				}
+			}
+		}
+	}
+	sort.Slice(locations, func(i, j int) bool {
+		a, b := locations[i], locations[j]
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Pod < b.Pod
+	})
+	return locations
+}
@@ -0,0 +1,74 @@
+package robot
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+func imagePod(namespace, name string, images ...string) *v1.Pod {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	for _, image := range images {
+		pod.Spec.Containers = append(pod.Spec.Containers, v1.Container{Image: image})
+	}
+	return pod
+}
+
+func TestImageLocations(t *testing.T) {
+	one := &member{Cluster: Cluster{Name: "one", Resources: []RN{{RType: Pods}}}}
+	two := &member{Cluster: Cluster{Name: "two", Resources: []RN{{RType: Pods}}}}
+	for _, m := range []*member{one, two} {
+		m.indexers = []cache.Indexer{m.newIndexer()}
+	}
+
+	web := imagePod("default", "web", "nginx:1.19", "envoy:1.14")
+	web.Status.ContainerStatuses = []v1.ContainerStatus{{ImageID: "docker-pullable://nginx@sha256:4f4e"}}
+	one.indexers[0].Add(web)
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(imagePod("default", "api", "nginx:1.19"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := &unstructured.Unstructured{Object: content}
+	api.SetAPIVersion("v1")
+	api.SetKind("Pod")
+	two.indexers[0].Add(api)
+
+	c := &controller{clusters: []*member{two, one}}
+	for image, e := range map[string][]ImageLocation{
+		"nginx:1.19":  {{"one", "default", "web"}, {"two", "default", "api"}},
+		"sha256:4f4e": {{"one", "default", "web"}},
+		"envoy:1.14":  {{"one", "default", "web"}},
+		"redis":       nil,
+	} {
+		if a := c.ImageLocations(image); !reflect.DeepEqual(e, a) {
+			t.Errorf("expected %v of %s, got %v", e, image, a)
+		}
+	}
+}
+
+func TestOnNewImage(t *testing.T) {
+	var images []string
+	var sent []QueueObject
+	c := &controller{
+		opts: Options{OnNewImage: func(image string, at ImageLocation) {
+			images = append(images, image+" "+at.Cluster+"/"+at.Namespace+"/"+at.Pod)
+		}},
+		queue:  &recordQueue{sent: &sent},
+		images: newImageSet(),
+	}
+	one := c.emit(&member{Cluster: Cluster{Name: "one"}}, RN{RType: Pods})
+	two := c.emit(&member{Cluster: Cluster{Name: "two"}}, RN{RType: Pods})
+	one(QueueObject{Event: EventAdd, RType: Pods}, imagePod("default", "web", "nginx:1.19"))
+	two(QueueObject{Event: EventAdd, RType: Pods}, imagePod("default", "web", "nginx:1.19"))
+	two(QueueObject{Event: EventUpdate, RType: Pods}, imagePod("default", "web", "nginx:1.19", "envoy:1.14"))
+
+	e := []string{"nginx:1.19 one/default/web", "envoy:1.14 two/default/web"}
+	if a := images; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
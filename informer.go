@@ -0,0 +1,292 @@
+package robot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+)
+
+type informer struct {
+	cache.Controller
+
+	resource Resource
+	rn       RN
+
+	// cluster is the name of the cluster of the informer.
+	cluster string
+
+	// indexer is the cache of the informer, nil if unknown.
+	indexer cache.Indexer
+
+	// after are the informers of the cluster whose caches must sync before
+	// the informer runs, see Options.DependsOn.
+	after []*informer
+
+	// exited is closed once the informer returns, e.g. after a recovered panic,
+	// so that waiting for its cache doesn't block forever.
+	exited chan struct{}
+
+	// stop stops the informer alone, while done of run stops all informers
+	// of the cluster.
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newInformer(resource Resource, controller cache.Controller) *informer {
+	return &informer{
+		Controller: controller,
+		resource:   resource,
+		exited:     make(chan struct{}),
+		stop:       make(chan struct{}),
+	}
+}
+
+// halt stops the informer, it's safe to call halt multiple times.
+func (i *informer) halt() {
+	i.stopOnce.Do(func() {
+		close(i.stop)
+	})
+}
+
+type informerSet []*informer
+
+// run starts the informers, each after the informers it depends on. It doesn't
+// wait for caches to sync, so a cache which never syncs neither blocks nor
+// panics, it's seen by HasSynced and WaitForSync instead.
+func (s informerSet) run(done chan struct{}, report func(error)) {
+	for i, one := range s {
+		stop := make(chan struct{})
+		go func(one *informer) {
+			defer close(stop)
+			select {
+			case <-done:
+			case <-one.stop:
+			case <-one.exited:
+			}
+		}(one)
+
+		go func(i int, one *informer) {
+			defer close(one.exited)
+			defer handleCrash(report, "informer %d of %s", i, one.resource)
+
+			if !one.awaitDependencies(stop) {
+				return
+			}
+			one.Run(stop)
+		}(i, one)
+	}
+}
+
+// awaitDependencies blocks until the informers the informer runs after have
+// synced or exited, and returns false if stop is closed before that.
+func (i *informer) awaitDependencies(stop <-chan struct{}) bool {
+	for _, one := range i.after {
+		err := wait.PollImmediateUntil(100*time.Millisecond, func() (bool, error) {
+			select {
+			case <-one.exited:
+				return true, nil
+			default:
+			}
+			return one.HasSynced(), nil
+		}, stop)
+		if err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// order sets the informers each informer runs after by the dependencies.
+func (s informerSet) order(dependsOn map[Resource][]Resource) {
+	for _, one := range s {
+		one.after = nil
+		for _, r := range dependencies(dependsOn, one.resource) {
+			for _, other := range s {
+				if other.resource == r {
+					one.after = append(one.after, other)
+				}
+			}
+		}
+	}
+}
+
+// dependencies returns the resources the resource depends on, the ones of
+// All apply to every resource but themselves.
+func dependencies(dependsOn map[Resource][]Resource, r Resource) []Resource {
+	deps := dependsOn[r]
+	all := dependsOn[All]
+	for _, one := range all {
+		if one == r {
+			return deps
+		}
+	}
+	return append(all[:len(all):len(all)], deps...)
+}
+
+// checkDependencies returns an error if the resources depend on each other
+// in a cycle.
+func checkDependencies(dependsOn map[Resource][]Resource, resources []Resource) error {
+	watched := make(map[Resource]bool, len(resources))
+	for _, r := range resources {
+		watched[r] = true
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[Resource]int)
+	var visit func(r Resource, path []Resource) error
+	visit = func(r Resource, path []Resource) error {
+		switch state[r] {
+		case visiting:
+			var s []string
+			for _, one := range append(path, r) {
+				s = append(s, one.String())
+			}
+			return fmt.Errorf("robot: resources depend on each other: %s", strings.Join(s, " -> "))
+		case visited:
+			return nil
+		}
+		state[r] = visiting
+		for _, one := range dependencies(dependsOn, r) {
+			if !watched[one] {
+				continue
+			}
+			if err := visit(one, append(path, r)); err != nil {
+				return err
+			}
+		}
+		state[r] = visited
+		return nil
+	}
+	for _, r := range resources {
+		if err := visit(r, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// halt stops informers of the given resources, all informers if none is given,
+// and returns the others.
+func (s informerSet) halt(resources ...Resource) informerSet {
+	halted := s.filter(resources...)
+
+	var out informerSet
+	for _, one := range s {
+		stopped := false
+		for _, h := range halted {
+			if h == one {
+				stopped = true
+			}
+		}
+		if stopped {
+			one.halt()
+		} else {
+			out = append(out, one)
+		}
+	}
+	return out
+}
+
+// filter returns informers of the given resources, all informers if none is given.
+func (s informerSet) filter(resources ...Resource) informerSet {
+	if len(resources) == 0 {
+		return s
+	}
+
+	var out informerSet
+	for _, one := range s {
+		for _, r := range resources {
+			if r == All || r == one.resource {
+				out = append(out, one)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func (s informerSet) waitForSync(ctx context.Context, resources ...Resource) error {
+	for _, one := range s.filter(resources...) {
+		err := wait.PollImmediateUntil(100*time.Millisecond, func() (bool, error) {
+			select {
+			case <-one.exited:
+				return false, fmt.Errorf("robot: informer of %s exited before its cache synced", one.resource)
+			default:
+			}
+			return one.HasSynced(), nil
+		}, ctx.Done())
+
+		if err == wait.ErrWaitTimeout {
+			return &ErrSyncTimeout{Cluster: one.cluster, Resource: one.resource, Err: ctx.Err()}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newIndexerInformer is like cache.NewIndexerInformer, but uses the given indexer
+// so that the cached objects survive the informer being recreated.
+// keyFunc must be the key function of the indexer.
+func newIndexerInformer(lw cache.ListerWatcher, objType runtime.Object, keyFunc cache.KeyFunc, h cache.ResourceEventHandler, indexer cache.Indexer) cache.Controller {
+	// Passing indexer as the known objects, so that a relist results in the
+	// correct set of update/delete deltas.
+	fifo := cache.NewDeltaFIFO(keyFunc, indexer)
+
+	return cache.New(&cache.Config{
+		Queue:         fifo,
+		ListerWatcher: lw,
+		ObjectType:    objType,
+		RetryOnError:  false,
+
+		Process: func(obj interface{}) error {
+			// from oldest to newest
+			for _, d := range obj.(cache.Deltas) {
+				switch d.Type {
+				case cache.Sync, cache.Added, cache.Updated:
+					if old, exists, err := indexer.Get(d.Object); err == nil && exists {
+						if err := indexer.Update(d.Object); err != nil {
+							return err
+						}
+						if recreated(old, d.Object) {
+							// The object was deleted and created again in a watch gap,
+							// send the missed delete.
+							h.OnDelete(old)
+							h.OnAdd(d.Object)
+						} else {
+							h.OnUpdate(old, d.Object)
+						}
+					} else {
+						if err := indexer.Add(d.Object); err != nil {
+							return err
+						}
+						h.OnAdd(d.Object)
+					}
+				case cache.Deleted:
+					if err := indexer.Delete(d.Object); err != nil {
+						return err
+					}
+					h.OnDelete(d.Object)
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// recreated returns whether the objects of the same key are different objects,
+// which means the old one has been deleted.
+func recreated(old, new interface{}) bool {
+	oldUID, newUID := MetaUIDFunc(old), MetaUIDFunc(new)
+	return oldUID != "" && newUID != "" && oldUID != newUID
+}
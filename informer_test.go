@@ -0,0 +1,222 @@
+package robot
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+type unsyncedInformer struct {
+	fakeInformer
+}
+
+func (unsyncedInformer) HasSynced() bool { return false }
+
+func TestWaitForSync(t *testing.T) {
+	s := informerSet{
+		newInformer(Pods, fakeInformer{}),
+		newInformer(Services, unsyncedInformer{}),
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	s.run(stop, func(error) {})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	if err := s.waitForSync(ctx, Pods); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := s.waitForSync(ctx, All); err == nil {
+		t.Errorf("expected an error when a cache never syncs")
+	}
+}
+
+func TestRelistSendsMissedDeletes(t *testing.T) {
+	lists := []*v1.PodList{
+		{Items: []v1.Pod{
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "one", UID: "1", ResourceVersion: "1"}},
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "two", UID: "2", ResourceVersion: "2"}},
+		}},
+		// "one" was recreated and "two" was deleted in the watch gap.
+		{Items: []v1.Pod{
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "one", UID: "3", ResourceVersion: "3"}},
+		}},
+	}
+	var mu sync.Mutex
+	listed := 0
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			listed++
+			if listed > len(lists) {
+				return lists[len(lists)-1], nil
+			}
+			return lists[listed-1], nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			w := watch.NewFake()
+			if listed == 1 {
+				// The first watch ends at once, so the reflector lists again.
+				w.Stop()
+			}
+			return w, nil
+		},
+	}
+
+	events := make(chan QueueObject, 10)
+	emit := func(item QueueObject, obj interface{}) {
+		events <- item
+	}
+	indexer := cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, cache.Indexers{})
+	informer := newIndexerInformer(lw, &v1.Pod{}, cache.DeletionHandlingMetaNamespaceKeyFunc, initHandle(Pods, cache.DeletionHandlingMetaNamespaceKeyFunc, emit, func(error) {}), indexer)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go informer.Run(stop)
+
+	var got []string
+	for len(got) < 5 {
+		select {
+		case item := <-events:
+			got = append(got, item.Event.String()+" "+item.Key)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected 5 events, got %v", got)
+		}
+	}
+
+	expected := map[string]int{"add default/one": 2, "add default/two": 1, "delete default/one": 1, "delete default/two": 1}
+	counts := make(map[string]int)
+	for _, one := range got {
+		counts[one]++
+	}
+	for key, e := range expected {
+		if a := counts[key]; e != a {
+			t.Errorf("expected %v %q, got %v", e, key, a)
+		}
+	}
+}
+
+func TestHaltResources(t *testing.T) {
+	m := &member{
+		informers: informerSet{newInformer(Pods, fakeInformer{}), newInformer(Services, fakeInformer{})},
+		stop:      make(chan struct{}),
+	}
+	pods, services := m.informers[0], m.informers[1]
+	m.run(func(error) {})
+
+	m.haltResources(Pods)
+	select {
+	case <-pods.exited:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the informer of pods to exit")
+	}
+	if e, a := 1, len(m.informers); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	select {
+	case <-services.exited:
+		t.Fatalf("expected the informer of services to keep running")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	m.halt()
+	select {
+	case <-services.exited:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the informer of services to exit")
+	}
+}
+
+type syncingInformer struct {
+	synced chan struct{}
+}
+
+func (i syncingInformer) Run(stop <-chan struct{}) { <-stop }
+
+func (i syncingInformer) HasSynced() bool {
+	select {
+	case <-i.synced:
+		return true
+	default:
+		return false
+	}
+}
+
+func (syncingInformer) LastSyncResourceVersion() string { return "" }
+
+type startedInformer struct {
+	started chan struct{}
+}
+
+func (i startedInformer) Run(stop <-chan struct{}) {
+	close(i.started)
+	<-stop
+}
+
+func (startedInformer) HasSynced() bool { return true }
+
+func (startedInformer) LastSyncResourceVersion() string { return "" }
+
+func TestDependsOn(t *testing.T) {
+	synced := make(chan struct{})
+	started := make(chan struct{})
+	s := informerSet{
+		newInformer(Endpoints, startedInformer{started}),
+		newInformer(Services, syncingInformer{synced}),
+	}
+	s.order(map[Resource][]Resource{Endpoints: {Services}})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	s.run(stop, func(error) {})
+
+	select {
+	case <-started:
+		t.Fatalf("expected Endpoints to wait for Services to sync")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	close(synced)
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Errorf("expected Endpoints to start once Services synced")
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	dependsOn := map[Resource][]Resource{All: {Namespaces}, Endpoints: {Services}}
+
+	if e, a := []Resource{Namespaces, Services}, dependencies(dependsOn, Endpoints); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if a := dependencies(dependsOn, Namespaces); len(a) != 0 {
+		t.Errorf("expected no dependencies of Namespaces, got %v", a)
+	}
+	if err := checkDependencies(dependsOn, []Resource{Namespaces, Services, Endpoints}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	dependsOn[Namespaces] = []Resource{Endpoints}
+	if err := checkDependencies(dependsOn, []Resource{Namespaces, Services, Endpoints}); err == nil {
+		t.Errorf("expected an error of a cycle")
+	}
+	// Resources not watched don't count.
+	if err := checkDependencies(dependsOn, []Resource{Namespaces, Services}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
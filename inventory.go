@@ -0,0 +1,246 @@
+package robot
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/cache"
+)
+
+// QueryResult is the result of a query of the inventory.
+type QueryResult struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// inventoryBuffer is how many writes wait for the database, and
+// maxInventoryBatch how many are committed in a transaction at most.
+const (
+	inventoryBuffer   = 4096
+	maxInventoryBatch = 512
+)
+
+// inventory mirrors cached objects into a table of each resource of a SQLite
+// database, see Options.Inventory. Writes are applied by a goroutine in
+// batches of transactions, so a slow database doesn't stall informers
+// until inventoryBuffer writes wait.
+type inventory struct {
+	db     *sql.DB
+	report func(error)
+
+	writes chan inventoryWrite
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// inventoryWrite is a statement of a write, or a flush if flushed is set,
+// which is closed once the writes before are applied.
+type inventoryWrite struct {
+	query   string
+	args    []interface{}
+	desc    string
+	flushed chan struct{}
+}
+
+// inventoryTable returns the table of the resource, e.g. pods or deployments_apps.
+func inventoryTable(r Resource) string {
+	return strings.NewReplacer(".", "_", "-", "_").Replace(r.String())
+}
+
+// newInventory creates the tables of the resources in db, and starts applying
+// writes, errors of which are reported by report.
+func newInventory(db *sql.DB, resources []Resource, report func(error)) (*inventory, error) {
+	for _, r := range resources {
+		_, err := db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (
+	cluster TEXT NOT NULL,
+	namespace TEXT NOT NULL,
+	name TEXT NOT NULL,
+	labels TEXT NOT NULL,
+	json TEXT NOT NULL,
+	PRIMARY KEY (cluster, namespace, name)
+)`, inventoryTable(r)))
+		if err != nil {
+			return nil, fmt.Errorf("robot: create inventory table of %s: %v", r, err)
+		}
+	}
+	i := &inventory{
+		db:     db,
+		report: report,
+		writes: make(chan inventoryWrite, inventoryBuffer),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go i.run()
+	return i, nil
+}
+
+// write mirrors the event of obj into the table of its resource,
+// a nil inventory writes nothing.
+func (i *inventory) write(item QueueObject, obj interface{}) error {
+	if i == nil {
+		return nil
+	}
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil
+	}
+	table := inventoryTable(item.RType)
+
+	if item.Event == EventDelete {
+		i.enqueue(inventoryWrite{
+			query: fmt.Sprintf(`DELETE FROM "%s" WHERE cluster = ? AND namespace = ? AND name = ?`, table),
+			args:  []interface{}{item.Cluster, accessor.GetNamespace(), accessor.GetName()},
+			desc:  fmt.Sprintf("delete %s of %s from inventory", item.Key, item.RType),
+		})
+		return nil
+	}
+
+	labels, err := json.Marshal(accessor.GetLabels())
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	i.enqueue(inventoryWrite{
+		query: fmt.Sprintf(`INSERT OR REPLACE INTO "%s" (cluster, namespace, name, labels, json) VALUES (?, ?, ?, ?, ?)`, table),
+		args:  []interface{}{item.Cluster, accessor.GetNamespace(), accessor.GetName(), string(labels), string(content)},
+		desc:  fmt.Sprintf("write %s of %s to inventory", item.Key, item.RType),
+	})
+	return nil
+}
+
+// enqueue queues w, it's dropped once the inventory is closed.
+func (i *inventory) enqueue(w inventoryWrite) {
+	select {
+	case i.writes <- w:
+	case <-i.stop:
+	}
+}
+
+// flush waits until writes queued are applied.
+func (i *inventory) flush() {
+	flushed := make(chan struct{})
+	i.enqueue(inventoryWrite{flushed: flushed})
+	select {
+	case <-flushed:
+	case <-i.done:
+	}
+}
+
+// close applies writes queued, and stops applying more.
+func (i *inventory) close() {
+	if i == nil {
+		return
+	}
+	i.once.Do(func() { close(i.stop) })
+	<-i.done
+}
+
+// run applies writes in batches until the inventory is closed.
+func (i *inventory) run() {
+	defer close(i.done)
+	for {
+		select {
+		case w := <-i.writes:
+			batch := []inventoryWrite{w}
+			for more := true; more && len(batch) < maxInventoryBatch; {
+				select {
+				case w := <-i.writes:
+					batch = append(batch, w)
+				default:
+					more = false
+				}
+			}
+			i.apply(batch)
+		case <-i.stop:
+			var batch []inventoryWrite
+			for more := true; more; {
+				select {
+				case w := <-i.writes:
+					batch = append(batch, w)
+				default:
+					more = false
+				}
+			}
+			i.apply(batch)
+			return
+		}
+	}
+}
+
+// apply executes the batch in a transaction.
+func (i *inventory) apply(batch []inventoryWrite) {
+	defer func() {
+		for _, w := range batch {
+			if w.flushed != nil {
+				close(w.flushed)
+			}
+		}
+	}()
+
+	tx, err := i.db.Begin()
+	if err != nil {
+		i.report(fmt.Errorf("robot: write inventory: %v", err))
+		return
+	}
+	for _, w := range batch {
+		if w.flushed != nil {
+			continue
+		}
+		if _, err := tx.Exec(w.query, w.args...); err != nil {
+			i.report(fmt.Errorf("robot: %s: %v", w.desc, err))
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		i.report(fmt.Errorf("robot: write inventory: %v", err))
+	}
+}
+
+// Query runs the query against the inventory, values of bytes are returned as strings.
+func (c *controller) Query(query string, args ...interface{}) (*QueryResult, error) {
+	if c.inventory == nil {
+		return nil, errors.New("robot: inventory is disabled")
+	}
+	// Read the writes of the events so far.
+	c.inventory.flush()
+
+	rows, err := c.inventory.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := &QueryResult{Columns: columns}
+	for rows.Next() {
+		row := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range row {
+			pointers[i] = &row[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		for i, value := range row {
+			if b, ok := value.([]byte); ok {
+				row[i] = string(b)
+			}
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,153 @@
+package robot
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// fakeDB is a database driver which keeps rows inserted by cluster/namespace/name,
+// and returns their names sorted for any query.
+type fakeDB struct {
+	mu     sync.Mutex
+	tables []string
+	rows   map[string]bool
+
+	// locked blocks writes of rows while it's held, like a locked database.
+	locked sync.Mutex
+}
+
+var fakeDatabase = &fakeDB{rows: make(map[string]bool)}
+
+func init() {
+	sql.Register("robotfake", fakeDatabase)
+}
+
+func (d *fakeDB) Open(string) (driver.Conn, error) { return d, nil }
+func (d *fakeDB) Close() error                     { return nil }
+func (d *fakeDB) Begin() (driver.Tx, error)        { return d, nil }
+func (d *fakeDB) Commit() error                    { return nil }
+func (d *fakeDB) Rollback() error                  { return nil }
+
+func (d *fakeDB) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{d, query}, nil
+}
+
+type fakeStmt struct {
+	d     *fakeDB
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if !strings.HasPrefix(s.query, "CREATE TABLE") {
+		s.d.locked.Lock()
+		s.d.locked.Unlock()
+	}
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	switch {
+	case strings.HasPrefix(s.query, "CREATE TABLE"):
+		s.d.tables = append(s.d.tables, strings.Fields(s.query)[5])
+	case strings.HasPrefix(s.query, "INSERT"):
+		s.d.rows[args[0].(string)+"/"+args[1].(string)+"/"+args[2].(string)] = true
+	case strings.HasPrefix(s.query, "DELETE"):
+		delete(s.d.rows, args[0].(string)+"/"+args[1].(string)+"/"+args[2].(string))
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	rows := &fakeRows{}
+	for key := range s.d.rows {
+		rows.keys = append(rows.keys, key)
+	}
+	sort.Strings(rows.keys)
+	return rows, nil
+}
+
+type fakeRows struct {
+	keys []string
+}
+
+func (r *fakeRows) Columns() []string { return []string{"key"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.keys) == 0 {
+		return io.EOF
+	}
+	dest[0] = []byte(r.keys[0])
+	r.keys = r.keys[1:]
+	return nil
+}
+
+func TestInventory(t *testing.T) {
+	db, err := sql.Open("robotfake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	deployments := Resource{Group: "apps", Version: "v1", Resource: "deployments"}
+	robot, err := NewRobotWithOptions(Options{Inventory: db}, Cluster{
+		MasterUrl: "https://one.example.com",
+		Resources: []RN{{RType: Pods}, {RType: deployments}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(fakeDatabase.tables)
+	if e, a := []string{`"deployments_apps"`, `"pods"`}, fakeDatabase.tables; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	c := robot.(*controller)
+	defer c.inventory.close()
+	emit := c.emit(c.clusters[0], RN{RType: Pods})
+	one := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "one"}}
+	two := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "two"}}
+
+	// Events aren't held up by a locked database.
+	fakeDatabase.locked.Lock()
+	emitted := make(chan struct{})
+	go func() {
+		defer close(emitted)
+		emit(QueueObject{Event: EventAdd, RType: Pods, Key: "default/one"}, one)
+		emit(QueueObject{Event: EventAdd, RType: Pods, Key: "default/two"}, two)
+		emit(QueueObject{Event: EventDelete, RType: Pods, Key: "default/two"}, cache.DeletedFinalStateUnknown{Key: "default/two", Obj: two})
+	}()
+	select {
+	case <-emitted:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected events emitted while the database is locked")
+	}
+	fakeDatabase.locked.Unlock()
+
+	result, err := c.Query(`SELECT cluster || '/' || namespace || '/' || name FROM pods`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := [][]interface{}{{"https://one.example.com/default/one"}}
+	if a := result.Rows; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if _, err := (&controller{}).Query("SELECT 1"); err == nil {
+		t.Errorf("expected error of a disabled inventory")
+	}
+}
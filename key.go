@@ -0,0 +1,95 @@
+package robot
+
+import (
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/cache"
+)
+
+// KeyFunc returns the key of an object of the cluster, which keys the object
+// in the queue and the store, see Options.KeyFunc.
+type KeyFunc func(cluster string, obj interface{}) (string, error)
+
+// NamespaceKey keys objects by namespace/name, or name if cluster scoped.
+// Keys of objects of different clusters may collide. It's the default.
+func NamespaceKey(_ string, obj interface{}) (string, error) {
+	return cache.MetaNamespaceKeyFunc(obj)
+}
+
+// ClusterKey keys objects by cluster/namespace/name, or cluster/name if
+// cluster scoped, which are unique across clusters.
+func ClusterKey(cluster string, obj interface{}) (string, error) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return "", err
+	}
+	return cluster + "/" + key, nil
+}
+
+// UIDKey keys objects by namespace/name/uid, or name/uid if cluster scoped,
+// so an object deleted and created again has a new key.
+func UIDKey(_ string, obj interface{}) (string, error) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return "", err
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return "", err
+	}
+	return key + "/" + string(accessor.GetUID()), nil
+}
+
+// sameFunc reports whether the key functions are the same function.
+func sameFunc(a, b KeyFunc) bool {
+	return a != nil && reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+// key returns the key of obj in the member, the key of a tombstone is its Key.
+func (m *member) key(obj interface{}) (string, error) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		return tombstone.Key, nil
+	}
+	if m.keyFunc == nil {
+		return NamespaceKey(m.String(), obj)
+	}
+	return m.keyFunc(m.String(), obj)
+}
+
+// nameIndex indexes objects by namespace/name regardless of their keys.
+const nameIndex = "name"
+
+func nameIndexFunc(obj interface{}) ([]string, error) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return nil, err
+	}
+	return []string{key}, nil
+}
+
+// newIndexer returns an indexer of objects of the member.
+func (m *member) newIndexer() cache.Indexer {
+	return cache.NewIndexer(m.key, cache.Indexers{nameIndex: nameIndexFunc, imageIndex: imageIndexFunc})
+}
+
+// getByName returns the object of the namespace and name in the indexer,
+// by keys if the indexer has no nameIndex.
+func getByName(indexer cache.Indexer, namespace, name string) (interface{}, bool, error) {
+	key := name
+	if namespace != "" {
+		key = namespace + "/" + name
+	}
+	objs, err := indexer.ByIndex(nameIndex, key)
+	if err != nil {
+		return indexer.GetByKey(key)
+	}
+	switch len(objs) {
+	case 0:
+		return nil, false, nil
+	case 1:
+		return objs[0], true, nil
+	}
+	return nil, false, fmt.Errorf("robot: %d objects of %s", len(objs), key)
+}
@@ -0,0 +1,64 @@
+package robot
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestKeyFuncs(t *testing.T) {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "one", UID: "1"}}
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "one", UID: "2"}}
+	for _, test := range []struct {
+		keyFunc KeyFunc
+		obj     interface{}
+		key     string
+	}{
+		{keyFunc: NamespaceKey, obj: pod, key: "default/one"},
+		{keyFunc: ClusterKey, obj: pod, key: "east/default/one"},
+		{keyFunc: ClusterKey, obj: node, key: "east/one"},
+		{keyFunc: UIDKey, obj: pod, key: "default/one/1"},
+		{keyFunc: UIDKey, obj: node, key: "one/2"},
+		{keyFunc: UIDKey, obj: cache.DeletedFinalStateUnknown{Key: "default/one/1", Obj: pod}, key: "default/one/1"},
+	} {
+		m := &member{Cluster: Cluster{Name: "east"}, keyFunc: test.keyFunc}
+		key, err := m.key(test.obj)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			continue
+		}
+		if e, a := test.key, key; e != a {
+			t.Errorf("expected %v, got %v", e, a)
+		}
+	}
+}
+
+func TestClusterKeys(t *testing.T) {
+	var sent []QueueObject
+	emit := func(item QueueObject, obj interface{}) {
+		sent = append(sent, item)
+	}
+	m := &member{Cluster: Cluster{Name: "east"}, keyFunc: ClusterKey}
+	indexer := m.newIndexer()
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "one"}}
+	indexer.Add(pod)
+	m.handler(RN{RType: Pods}, emit, func(error) {}).OnAdd(pod)
+
+	if e, a := "east/default/one", sent[0].Key; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if _, exists, _ := indexer.GetByKey("east/default/one"); !exists {
+		t.Errorf("expected the object stored by its key")
+	}
+	if obj, exists, _ := getByName(indexer, "default", "one"); !exists || obj != pod {
+		t.Errorf("expected %v, got %v", pod, obj)
+	}
+	if e, a := 1, len((&namespacedIndexer{indexer, "default"}).ListKeys()); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := 0, len((&namespacedIndexer{indexer, "east"}).ListKeys()); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
@@ -0,0 +1,126 @@
+package robot
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds of buckets of latency histograms.
+var latencyBuckets = []time.Duration{
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+}
+
+// Histogram is a histogram of latencies from receiving events to finishing them.
+type Histogram struct {
+	// Buckets are the upper bounds of the buckets.
+	Buckets []time.Duration
+
+	// Counts are the numbers of events in each bucket, the last one counts
+	// events over all bounds.
+	Counts []int64
+
+	Count int64
+	Sum   time.Duration
+
+	// OverSLO is the number of events over Options.LatencySLO.
+	OverSLO int64
+}
+
+func newHistogram() *Histogram {
+	return &Histogram{
+		Buckets: latencyBuckets,
+		Counts:  make([]int64, len(latencyBuckets)+1),
+	}
+}
+
+func (h *Histogram) observe(latency time.Duration, slo time.Duration) {
+	i := sort.Search(len(h.Buckets), func(i int) bool {
+		return latency <= h.Buckets[i]
+	})
+	h.Counts[i]++
+	h.Count++
+	h.Sum += latency
+	if slo > 0 && latency > slo {
+		h.OverSLO++
+	}
+}
+
+// Mean returns the mean latency, zero if there is no event.
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// latencyRecorder records latencies of events of a cluster per resource,
+// a nil recorder records nothing.
+type latencyRecorder struct {
+	slo time.Duration
+
+	mu         sync.Mutex
+	histograms map[Resource]*Histogram
+}
+
+func newLatencyRecorder(slo time.Duration) *latencyRecorder {
+	return &latencyRecorder{slo: slo, histograms: make(map[Resource]*Histogram)}
+}
+
+func (l *latencyRecorder) observe(r Resource, latency time.Duration) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h, ok := l.histograms[r]
+	if !ok {
+		h = newHistogram()
+		l.histograms[r] = h
+	}
+	h.observe(latency, l.slo)
+}
+
+// snapshot returns a copy of the histograms.
+func (l *latencyRecorder) snapshot() map[Resource]Histogram {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := make(map[Resource]Histogram, len(l.histograms))
+	for r, h := range l.histograms {
+		one := *h
+		one.Counts = append([]int64(nil), h.Counts...)
+		out[r] = one
+	}
+	return out
+}
+
+// Finish marks the object processed, and records its latency since received.
+func (c *controller) Finish(obj QueueObject) {
+	c.queue.Finish(obj)
+
+	if obj.CreateAt.IsZero() {
+		return
+	}
+	c.mu.Lock()
+	m := c.memberOf(obj.Cluster)
+	c.mu.Unlock()
+	if m != nil {
+		m.latency.observe(obj.RType, time.Since(obj.CreateAt))
+		m.finished(obj)
+	}
+}
@@ -0,0 +1,31 @@
+package robot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFinishRecordsLatency(t *testing.T) {
+	m := &member{Cluster: Cluster{MasterUrl: "https://one.example.com"}, latency: newLatencyRecorder(time.Second)}
+	c := &controller{queue: newWorkQueue(), clusters: []*member{m}}
+	defer c.queue.close()
+
+	for _, ago := range []time.Duration{20 * time.Millisecond, 2 * time.Second} {
+		item := QueueObject{Event: EventAdd, RType: Pods, Key: "default/one", CreateAt: time.Now().Add(-ago), Cluster: m.String()}
+		c.Finish(item)
+	}
+
+	h := m.latency.snapshot()[Pods]
+	if e, a := int64(2), h.Count; e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	if e, a := int64(1), h.Counts[1]; e != a {
+		t.Errorf("expected %v in the bucket of 50ms, got %v", e, a)
+	}
+	if e, a := int64(1), h.OverSLO; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if h.Mean() < time.Second {
+		t.Errorf("expected mean over a second, got %v", h.Mean())
+	}
+}
@@ -0,0 +1,443 @@
+package robot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// merged is a Robot of robots, see Merge.
+type merged struct {
+	robots []Robot
+
+	// core has the queue events of the robots are forwarded to, the pool of
+	// Process and errors of the robots, it has no cluster.
+	core *controller
+
+	queue
+}
+
+var _ Robot = &merged{}
+
+// Merge returns a Robot of the robots, e.g. one per cloud provider of different
+// auth setups, whose queue and store aggregate theirs, so a fleet is a single
+// source of events. Running it runs the robots, which must not be run otherwise,
+// and stopping it stops them.
+// Methods of a cluster go to the robot of the cluster, whose name must be
+// unique across the robots. Events finished or requeued are finished or
+// requeued by the robot they come from, so its latencies are of the consumer.
+// Event streams stay of each robot, as their sequence numbers are, so
+// CommitOffset of the merged robot fails and consumers use the ones of the robots.
+func Merge(robots ...Robot) (Robot, error) {
+	if len(robots) == 0 {
+		return nil, errors.New("robot: no robot to merge")
+	}
+	names := make(map[string]bool)
+	for _, r := range robots {
+		for _, status := range r.Status() {
+			if names[status.Cluster] {
+				return nil, fmt.Errorf("robot: duplicate cluster %s in merged robots", status.Cluster)
+			}
+			names[status.Cluster] = true
+		}
+	}
+
+	m := &merged{robots: robots}
+	m.queue = &mergedQueue{wq: newWorkQueue(), m: m}
+	m.core = &controller{
+		queue: m.queue,
+		stop:  make(chan struct{}),
+		errs:  make(chan error, 100),
+	}
+	return m, nil
+}
+
+// mergedQueue is the queue of a merged robot, which passes events finished
+// or requeued to the robots they come from.
+type mergedQueue struct {
+	*wq
+	m *merged
+}
+
+func (q *mergedQueue) Finish(obj QueueObject) {
+	q.wq.Finish(obj)
+	if r := q.m.robotOf(obj.Cluster); r != nil {
+		r.Finish(obj)
+	}
+}
+
+// ReQueue requeues the object by the robot it comes from, which forwards it
+// again, or gives it up.
+func (q *mergedQueue) ReQueue(obj QueueObject) error {
+	q.wq.Finish(obj)
+	r := q.m.robotOf(obj.Cluster)
+	if r == nil {
+		return &ErrClusterNotFound{obj.Cluster}
+	}
+	return r.ReQueue(obj)
+}
+
+// Run runs the robots, and forwards their events and errors until Stop is called.
+func (m *merged) Run() error {
+	c := m.core
+	c.mu.Lock()
+	if c.running {
+		c.mu.Unlock()
+		return errors.New("robot: Run has been called already")
+	}
+	c.running = true
+	c.mu.Unlock()
+
+	defer m.queue.close()
+
+	var wg sync.WaitGroup
+	for _, r := range m.robots {
+		wg.Add(3)
+		go func(r Robot) {
+			defer wg.Done()
+			if err := r.Run(); err != nil {
+				// Its queue isn't closed as it failed to run.
+				c.report(err)
+				r.close()
+			}
+		}(r)
+		go func(r Robot) {
+			defer wg.Done()
+			m.forwardEvents(r)
+		}(r)
+		go func(r Robot) {
+			defer wg.Done()
+			m.forwardErrors(r)
+		}(r)
+	}
+
+	<-c.stop
+	for _, r := range m.robots {
+		r.Stop()
+	}
+	wg.Wait()
+	return nil
+}
+
+// forwardEvents moves events of the robot to the queue until it's closed,
+// they're finished by the robot once finished by the consumer.
+func (m *merged) forwardEvents(r Robot) {
+	for {
+		item, err := r.Pop()
+		if err != nil {
+			return
+		}
+		m.push(item)
+	}
+}
+
+// forwardErrors forwards errors of the robot until the merged robot stops.
+func (m *merged) forwardErrors(r Robot) {
+	for {
+		select {
+		case err := <-r.Errors():
+			m.core.report(err)
+		case <-m.core.stop:
+			return
+		}
+	}
+}
+
+func (m *merged) Stop() {
+	m.core.Stop()
+}
+
+func (m *merged) Activate() {
+	for _, r := range m.robots {
+		r.Activate()
+	}
+}
+
+func (m *merged) WaitForSync(ctx context.Context, resources ...Resource) error {
+	for _, r := range m.robots {
+		if err := r.WaitForSync(ctx, resources...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// robotOf returns the robot of the cluster, nil if it's not found.
+func (m *merged) robotOf(cluster string) Robot {
+	for _, r := range m.robots {
+		if r.Client(cluster) != nil {
+			return r
+		}
+	}
+	return nil
+}
+
+func (m *merged) WatchObject(ctx context.Context, cluster string, resource Resource, namespace, name string) (<-chan ObjectEvent, error) {
+	r := m.robotOf(cluster)
+	if r == nil {
+		return nil, &ErrClusterNotFound{cluster}
+	}
+	return r.WatchObject(ctx, cluster, resource, namespace, name)
+}
+
+func (m *merged) WaitForObject(ctx context.Context, cluster string, resource Resource, key string, predicate Predicate) (interface{}, error) {
+	r := m.robotOf(cluster)
+	if r == nil {
+		return nil, &ErrClusterNotFound{cluster}
+	}
+	return r.WaitForObject(ctx, cluster, resource, key, predicate)
+}
+
+func (m *merged) Resync(resource Resource, clusters ...string) error {
+	if len(clusters) == 0 {
+		for _, r := range m.robots {
+			if err := r.Resync(resource); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, cluster := range clusters {
+		r := m.robotOf(cluster)
+		if r == nil {
+			return &ErrClusterNotFound{cluster}
+		}
+		if err := r.Resync(resource, cluster); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *merged) History(cluster string, resource Resource, key string) ([]ObjectVersion, error) {
+	r := m.robotOf(cluster)
+	if r == nil {
+		return nil, &ErrClusterNotFound{cluster}
+	}
+	return r.History(cluster, resource, key)
+}
+
+func (m *merged) Status() []ClusterStatus {
+	var out []ClusterStatus
+	for _, r := range m.robots {
+		out = append(out, r.Status()...)
+	}
+	return out
+}
+
+func (m *merged) Client(cluster string) kubernetes.Interface {
+	if r := m.robotOf(cluster); r != nil {
+		return r.Client(cluster)
+	}
+	return nil
+}
+
+func (m *merged) Apply(cluster string, resource Resource, obj runtime.Object, opts WriteOptions) (*unstructured.Unstructured, error) {
+	r := m.robotOf(cluster)
+	if r == nil {
+		return nil, &ErrClusterNotFound{cluster}
+	}
+	return r.Apply(cluster, resource, obj, opts)
+}
+
+func (m *merged) Patch(cluster string, resource Resource, namespace, name string, pt types.PatchType, data []byte, opts WriteOptions) (*unstructured.Unstructured, error) {
+	r := m.robotOf(cluster)
+	if r == nil {
+		return nil, &ErrClusterNotFound{cluster}
+	}
+	return r.Patch(cluster, resource, namespace, name, pt, data, opts)
+}
+
+func (m *merged) Delete(cluster string, resource Resource, namespace, name string, opts WriteOptions) error {
+	r := m.robotOf(cluster)
+	if r == nil {
+		return &ErrClusterNotFound{cluster}
+	}
+	return r.Delete(cluster, resource, namespace, name, opts)
+}
+
+func (m *merged) Process(fn ProcessFunc, opts PoolOptions) {
+	m.core.Process(fn, opts)
+}
+
+func (m *merged) Workers() int {
+	return m.core.Workers()
+}
+
+func (m *merged) ImageLocations(image string) []ImageLocation {
+	var out []ImageLocation
+	for _, r := range m.robots {
+		out = append(out, r.ImageLocations(image)...)
+	}
+	return out
+}
+
+func (m *merged) Costs() []CostTotal {
+	var out []CostTotal
+	for _, r := range m.robots {
+		out = append(out, r.Costs()...)
+	}
+	return out
+}
+
+// Query runs the query against the inventory of each robot, and returns
+// their rows together.
+func (m *merged) Query(query string, args ...interface{}) (*QueryResult, error) {
+	result := &QueryResult{}
+	for _, r := range m.robots {
+		one, err := r.Query(query, args...)
+		if err != nil {
+			return nil, err
+		}
+		result.Columns = one.Columns
+		result.Rows = append(result.Rows, one.Rows...)
+	}
+	return result, nil
+}
+
+// View returns a view of the views of the robots of the clusters of the filter.
+func (m *merged) View(filter ViewFilter) (View, error) {
+	for _, cluster := range filter.Clusters {
+		if m.robotOf(cluster) == nil {
+			return nil, &ErrClusterNotFound{cluster}
+		}
+	}
+
+	var views []View
+	for _, r := range m.robots {
+		one := filter
+		if len(filter.Clusters) > 0 {
+			one.Clusters = nil
+			for _, cluster := range filter.Clusters {
+				if m.robotOf(cluster) == r {
+					one.Clusters = append(one.Clusters, cluster)
+				}
+			}
+			if len(one.Clusters) == 0 {
+				continue
+			}
+		}
+		v, err := r.View(one)
+		if err != nil {
+			for _, v := range views {
+				v.Close()
+			}
+			return nil, err
+		}
+		views = append(views, v)
+	}
+	return newMergedView(views), nil
+}
+
+// CommitOffset fails, as each robot has its own event stream.
+func (m *merged) CommitOffset(consumer string, seq uint64) error {
+	return errors.New("robot: event streams are of each merged robot")
+}
+
+// Offset returns zero, as each robot has its own event stream.
+func (m *merged) Offset(consumer string) uint64 {
+	return 0
+}
+
+func (m *merged) Errors() <-chan error {
+	return m.core.errs
+}
+
+func (m *merged) List(r Resource) (l []interface{}) {
+	for _, one := range m.robots {
+		l = append(l, one.List(r)...)
+	}
+	return
+}
+
+func (m *merged) ListKeys(r Resource) (keys []string) {
+	for _, one := range m.robots {
+		keys = append(keys, one.ListKeys(r)...)
+	}
+	return
+}
+
+func (m *merged) GetByKey(r Resource, key string) (items []interface{}, exists bool) {
+	for _, one := range m.robots {
+		if found, ok := one.GetByKey(r, key); ok {
+			items = append(items, found...)
+			exists = true
+		}
+	}
+	return
+}
+
+// mergedView is a View of views of merged robots, whose events are forwarded
+// to its queue. It's closed once all of them are closed.
+type mergedView struct {
+	views []View
+
+	*wq
+	closeOnce sync.Once
+}
+
+func newMergedView(views []View) *mergedView {
+	v := &mergedView{views: views, wq: newWorkQueue()}
+
+	var wg sync.WaitGroup
+	for _, one := range views {
+		wg.Add(1)
+		go func(one View) {
+			defer wg.Done()
+			for {
+				item, err := one.Pop()
+				if err != nil {
+					return
+				}
+				v.push(item)
+				one.Finish(item)
+			}
+		}(one)
+	}
+	go func() {
+		wg.Wait()
+		v.closeQueue()
+	}()
+	return v
+}
+
+func (v *mergedView) closeQueue() {
+	v.closeOnce.Do(v.close)
+}
+
+func (v *mergedView) Close() {
+	for _, one := range v.views {
+		one.Close()
+	}
+	v.closeQueue()
+}
+
+func (v *mergedView) List(r Resource) (l []interface{}) {
+	for _, one := range v.views {
+		l = append(l, one.List(r)...)
+	}
+	return
+}
+
+func (v *mergedView) ListKeys(r Resource) (keys []string) {
+	for _, one := range v.views {
+		keys = append(keys, one.ListKeys(r)...)
+	}
+	return
+}
+
+func (v *mergedView) GetByKey(r Resource, key string) (items []interface{}, exists bool) {
+	for _, one := range v.views {
+		if found, ok := one.GetByKey(r, key); ok {
+			items = append(items, found...)
+			exists = true
+		}
+	}
+	return
+}
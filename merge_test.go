@@ -0,0 +1,177 @@
+package robot
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newMergedController(cluster string, pods ...string) *controller {
+	m := &member{
+		Cluster:   Cluster{Name: cluster, Resources: []RN{{RType: Pods}}},
+		client:    fake.NewSimpleClientset(),
+		informers: informerSet{newInformer(Pods, fakeInformer{})},
+		stop:      make(chan struct{}),
+		observed:  newEventCounter(),
+	}
+	m.indexers = append(m.indexers, m.newIndexer())
+	for _, name := range pods {
+		m.indexers[0].Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}})
+	}
+	return &controller{
+		queue:    newWorkQueue(),
+		stop:     make(chan struct{}),
+		errs:     make(chan error, 1),
+		clusters: []*member{m},
+		store:    mapIndexerSet{Pods: m.indexers},
+		views:    newViewSet(),
+	}
+}
+
+func TestMerge(t *testing.T) {
+	errBoom := errors.New("boom")
+	east := newMergedController("east", "one")
+	west := newMergedController("west", "one", "two")
+
+	if _, err := Merge(); err == nil {
+		t.Errorf("expected an error of no robot")
+	}
+	if _, err := Merge(east, newMergedController("east")); err == nil {
+		t.Errorf("expected an error of duplicate clusters")
+	}
+
+	r, err := Merge(east, west)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if r.Client("west") != west.Client("west") {
+		t.Errorf("expected the client of the robot of the cluster")
+	}
+	if r.Client("north") != nil {
+		t.Errorf("expected no client of an unknown cluster")
+	}
+	if e, a := 3, len(r.List(Pods)); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if items, ok := r.GetByKey(Pods, "default/one"); !ok || len(items) != 2 {
+		t.Errorf("expected the object of both robots, got %v", items)
+	}
+	if e, a := 2, len(r.Status()); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- r.Run()
+	}()
+
+	east.push(QueueObject{Event: EventAdd, RType: Pods, Key: "default/one", Cluster: "east"})
+	west.push(QueueObject{Event: EventAdd, RType: Pods, Key: "default/two", Cluster: "west"})
+	east.report(errBoom)
+
+	popped := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		item, err := r.Pop()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		popped[item.Cluster] = true
+		r.Finish(item)
+	}
+	if !popped["east"] || !popped["west"] {
+		t.Errorf("expected events of both robots, got %v", popped)
+	}
+
+	select {
+	case err := <-r.Errors():
+		if err != errBoom {
+			t.Errorf("expected %v, got %v", errBoom, err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected the error of a robot forwarded")
+	}
+
+	r.Stop()
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := r.Pop(); err == nil {
+		t.Errorf("expected the queue closed once stopped")
+	}
+}
+
+func TestMergeAcks(t *testing.T) {
+	east := newMergedController("east")
+	east.clusters[0].latency = newLatencyRecorder(0)
+	r, err := Merge(east)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- r.Run()
+	}()
+	defer func() {
+		r.Stop()
+		<-done
+	}()
+
+	obj := QueueObject{Event: EventAdd, RType: Pods, Key: "default/one", Cluster: "east", CreateAt: time.Now()}
+	east.push(obj)
+	item, _ := r.Pop()
+	if len(east.clusters[0].latency.snapshot()) != 0 {
+		t.Errorf("expected no latency until the event is finished by the consumer")
+	}
+
+	// The event requeued is forwarded again by the robot.
+	if err := r.ReQueue(item); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if item, _ = r.Pop(); item != obj {
+		t.Errorf("expected %v, got %v", obj, item)
+	}
+	r.Finish(item)
+	if e, a := int64(1), east.clusters[0].latency.snapshot()[Pods].Count; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestMergedView(t *testing.T) {
+	east := newMergedController("east", "one")
+	west := newMergedController("west", "one", "two")
+	r, err := Merge(east, west)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := r.View(ViewFilter{Clusters: []string{"north"}}); err == nil {
+		t.Errorf("expected an error of an unknown cluster")
+	}
+	v, err := r.View(ViewFilter{Clusters: []string{"west"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := 2, len(v.List(Pods)); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	west.emit(west.clusters[0], RN{RType: Pods})(QueueObject{Event: EventUpdate, RType: Pods, Key: "default/two"}, &v1.Pod{})
+	item, err := v.Pop()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if item.Cluster != "west" {
+		t.Errorf("expected %v, got %v", "west", item.Cluster)
+	}
+	v.Finish(item)
+
+	v.Close()
+	if _, err := v.Pop(); err == nil {
+		t.Errorf("expected the queue closed")
+	}
+}
@@ -0,0 +1,334 @@
+package robot
+
+import (
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// namespaceScope watches a resource in each namespace matching NamespaceSelector,
+// it's a cache.Controller running an informer per namespace.
+type namespaceScope struct {
+	rn      RN
+	indexer cache.Indexer
+	emit    emitFunc
+	report  func(error)
+
+	// informer watches the matching namespaces.
+	informer cache.Controller
+
+	// newInformer creates the informer of the resource in a namespace.
+	newInformer func(namespace string) cache.Controller
+
+	mu        sync.Mutex
+	stop      <-chan struct{}
+	informers map[string]cache.Controller
+	stops     map[string]chan struct{}
+}
+
+func (m *member) newNamespaceScope(client kubernetes.Interface, r RN, indexer cache.Indexer, emit emitFunc, report func(error)) *namespaceScope {
+	s := &namespaceScope{
+		rn:        r,
+		indexer:   indexer,
+		emit:      emit,
+		report:    report,
+		informers: make(map[string]cache.Controller),
+		stops:     make(map[string]chan struct{}),
+	}
+
+	s.newInformer = func(namespace string) cache.Controller {
+		rn := r
+		rn.Namespace = namespace
+		return rn.createInformer(m.listWatch(client, rn), m.object(rn.RType), m.key, &namespacedIndexer{m.tombstones.indexer(indexer), namespace}, m.handler(r, emit, report))
+	}
+
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.LabelSelector = r.NamespaceSelector
+			return client.CoreV1().Namespaces().List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.LabelSelector = r.NamespaceSelector
+			return client.CoreV1().Namespaces().Watch(options)
+		},
+	}
+	_, s.informer = cache.NewInformer(lw, &v1.Namespace{}, 0, cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if ns, ok := obj.(*v1.Namespace); ok {
+				s.add(ns.Name)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			// Namespaces no longer matching the selector are deleted from the watch too.
+			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			if err == nil {
+				s.remove(key)
+			}
+		},
+	})
+	return s
+}
+
+func (s *namespaceScope) Run(stop <-chan struct{}) {
+	s.mu.Lock()
+	s.stop = stop
+	s.mu.Unlock()
+
+	s.informer.Run(stop)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for namespace, stop := range s.stops {
+		close(stop)
+		delete(s.stops, namespace)
+		delete(s.informers, namespace)
+	}
+}
+
+func (s *namespaceScope) HasSynced() bool {
+	if !s.informer.HasSynced() {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, one := range s.informers {
+		if !one.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// LastSyncResourceVersion is empty, as there is no single resourceVersion
+// of the resource in many namespaces.
+func (s *namespaceScope) LastSyncResourceVersion() string {
+	return ""
+}
+
+// add starts watching the namespace.
+func (s *namespaceScope) add(namespace string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.informers[namespace]; ok || s.stop == nil {
+		return
+	}
+
+	informer := s.newInformer(namespace)
+	stop := make(chan struct{})
+	s.informers[namespace] = informer
+	s.stops[namespace] = stop
+
+	parent := s.stop
+	go func() {
+		defer handleCrash(s.report, "informer of %s in namespace %s", s.rn.RType, namespace)
+
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-parent:
+			case <-done:
+			}
+			s.mu.Lock()
+			if s.stops[namespace] == stop {
+				close(stop)
+				delete(s.stops, namespace)
+			}
+			s.mu.Unlock()
+		}()
+
+		informer.Run(stop)
+	}()
+}
+
+// remove stops watching the namespace, and sends deletes of its cached objects.
+func (s *namespaceScope) remove(namespace string) {
+	s.mu.Lock()
+	if stop, ok := s.stops[namespace]; ok {
+		close(stop)
+		delete(s.stops, namespace)
+	}
+	delete(s.informers, namespace)
+	s.mu.Unlock()
+
+	deleteNamespace(s.indexer, namespace, s.rn.RType, s.emit)
+}
+
+// deleteNamespace deletes the objects of the namespace from the indexer,
+// and sends deletes of them.
+func deleteNamespace(indexer cache.Indexer, namespace string, resource Resource, emit emitFunc) {
+	indexer = &namespacedIndexer{indexer, namespace}
+	for _, key := range indexer.ListKeys() {
+		obj, exists, err := indexer.GetByKey(key)
+		if err != nil || !exists {
+			continue
+		}
+		if err := indexer.Delete(obj); err != nil {
+			continue
+		}
+		emit(QueueObject{Event: EventDelete, RType: resource, Key: key, CreateAt: time.Now()}, obj)
+	}
+}
+
+// newNamespaceDeletes returns an informer of namespaces, which sends deletes of
+// the cached objects in a namespace when it's deleted.
+func (m *member) newNamespaceDeletes(client kubernetes.Interface, emitter func(RN) emitFunc, report func(error)) cache.Controller {
+	lw := cache.NewListWatchFromClient(client.CoreV1().RESTClient(), "namespaces", "", fields.Everything())
+	_, informer := cache.NewInformer(lw, &v1.Namespace{}, 0, cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj interface{}) {
+			defer handleCrash(report, "namespace delete handler of cluster %s", m)
+
+			namespace, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			if err != nil {
+				return
+			}
+			at := time.Now()
+			if ns, ok := obj.(*v1.Namespace); ok && ns.DeletionTimestamp != nil {
+				at = ns.DeletionTimestamp.Time
+			}
+			m.namespaceDeleted(namespace, at, emitter)
+		},
+	})
+	return informer
+}
+
+// namespaceDeleted sends deletes of the cached objects in the namespace
+// deleted at the time. The namespace is tombstoned first, so informers don't
+// add its objects back from events delivered late, and the deletes sent
+// follow any add or update of them.
+func (m *member) namespaceDeleted(namespace string, at time.Time, emitter func(RN) emitFunc) {
+	m.tombstones.add(namespace, at)
+	for i, r := range m.Resources {
+		deleteNamespace(m.indexers[i], namespace, r.RType, emitter(r))
+	}
+}
+
+// namespaceTombstoneTTL is how long deleted namespaces are tombstoned.
+const namespaceTombstoneTTL = time.Hour
+
+// namespaceTombstones are the namespaces deleted lately, whose objects created
+// before the deletion are dropped by informers. Objects of a namespace created
+// again with the same name are newer, so they're kept. A nil one drops nothing.
+type namespaceTombstones struct {
+	// mu is held for reading by informers while they add objects and send
+	// their events, and for writing while a namespace is tombstoned.
+	mu      sync.RWMutex
+	deleted map[string]time.Time
+}
+
+func newNamespaceTombstones() *namespaceTombstones {
+	return &namespaceTombstones{deleted: make(map[string]time.Time)}
+}
+
+func (t *namespaceTombstones) add(namespace string, at time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for ns, deleted := range t.deleted {
+		if time.Since(deleted) > namespaceTombstoneTTL {
+			delete(t.deleted, ns)
+		}
+	}
+	t.deleted[namespace] = at
+}
+
+// stale reports whether obj is of a namespace deleted after it was created,
+// t.mu must be held.
+func (t *namespaceTombstones) stale(obj interface{}) bool {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+	deleted, ok := t.deleted[accessor.GetNamespace()]
+	return ok && !accessor.GetCreationTimestamp().Time.After(deleted)
+}
+
+// indexer returns the indexer, which drops objects stale of informers.
+func (t *namespaceTombstones) indexer(indexer cache.Indexer) cache.Indexer {
+	if t == nil {
+		return indexer
+	}
+	return &tombstonedIndexer{indexer, t}
+}
+
+// handler returns h, which drops adds and updates of stale objects.
+func (t *namespaceTombstones) handler(h cache.ResourceEventHandler) cache.ResourceEventHandler {
+	if t == nil {
+		return h
+	}
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			t.mu.RLock()
+			defer t.mu.RUnlock()
+			if !t.stale(obj) {
+				h.OnAdd(obj)
+			}
+		},
+		UpdateFunc: func(old, new interface{}) {
+			t.mu.RLock()
+			defer t.mu.RUnlock()
+			if !t.stale(new) {
+				h.OnUpdate(old, new)
+			}
+		},
+		DeleteFunc: h.OnDelete,
+	}
+}
+
+// tombstonedIndexer is the indexer of informers, which doesn't add objects
+// of namespaces tombstoned.
+type tombstonedIndexer struct {
+	cache.Indexer
+	tombstones *namespaceTombstones
+}
+
+func (i *tombstonedIndexer) Add(obj interface{}) error {
+	i.tombstones.mu.RLock()
+	defer i.tombstones.mu.RUnlock()
+	if i.tombstones.stale(obj) {
+		return nil
+	}
+	return i.Indexer.Add(obj)
+}
+
+func (i *tombstonedIndexer) Update(obj interface{}) error {
+	i.tombstones.mu.RLock()
+	defer i.tombstones.mu.RUnlock()
+	if i.tombstones.stale(obj) {
+		return nil
+	}
+	return i.Indexer.Update(obj)
+}
+
+// namespacedIndexer is a view of the objects of a namespace in the indexer,
+// so that a relist of the namespace doesn't delete objects of other namespaces.
+type namespacedIndexer struct {
+	cache.Indexer
+	namespace string
+}
+
+func (i *namespacedIndexer) ListKeys() []string {
+	var keys []string
+	for _, key := range i.Indexer.ListKeys() {
+		obj, exists, err := i.Indexer.GetByKey(key)
+		if err != nil || !exists {
+			continue
+		}
+		if accessor, err := meta.Accessor(obj); err == nil && accessor.GetNamespace() == i.namespace {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
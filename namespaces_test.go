@@ -0,0 +1,106 @@
+package robot
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestNamespaceScopeRemove(t *testing.T) {
+	indexer := cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, cache.Indexers{})
+	_ = indexer.Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "one", Name: "a"}})
+	_ = indexer.Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "one", Name: "b"}})
+	_ = indexer.Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "one-two", Name: "a"}})
+
+	if e, a := 2, len((&namespacedIndexer{indexer, "one"}).ListKeys()); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	var sent []QueueObject
+	s := &namespaceScope{
+		rn:        RN{RType: Pods, NamespaceSelector: "team=one"},
+		indexer:   indexer,
+		emit:      func(item QueueObject, obj interface{}) { sent = append(sent, item) },
+		informers: make(map[string]cache.Controller),
+		stops:     make(map[string]chan struct{}),
+	}
+	s.remove("one")
+
+	if e, a := 2, len(sent); e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	for _, item := range sent {
+		if e, a := EventDelete, item.Event; e != a {
+			t.Errorf("expected %v, got %v", e, a)
+		}
+	}
+	if e, a := []string{"one-two/a"}, indexer.ListKeys(); len(a) != 1 || e[0] != a[0] {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestNamespaceDeleted(t *testing.T) {
+	m := &member{
+		Cluster:    Cluster{Name: "one", Resources: []RN{{RType: Pods}, {RType: ConfigMaps}}},
+		tombstones: newNamespaceTombstones(),
+	}
+	for range m.Resources {
+		m.indexers = append(m.indexers, m.newIndexer())
+	}
+	deleted := time.Now().Add(-time.Minute)
+	created := metav1.NewTime(deleted.Add(-time.Hour))
+	_ = m.indexers[0].Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "gone", Name: "a", CreationTimestamp: created}})
+	_ = m.indexers[0].Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "kept", Name: "a", CreationTimestamp: created}})
+	_ = m.indexers[1].Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "gone", Name: "b", CreationTimestamp: created}})
+
+	var sent []QueueObject
+	m.namespaceDeleted("gone", deleted, func(RN) emitFunc {
+		return func(item QueueObject, obj interface{}) { sent = append(sent, item) }
+	})
+
+	if e, a := 2, len(sent); e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	for _, item := range sent {
+		if item.Event != EventDelete || !strings.HasPrefix(item.Key, "gone/") {
+			t.Errorf("expected a delete in namespace gone, got %v", item)
+		}
+	}
+	if e, a := []string{"kept/a"}, m.indexers[0].ListKeys(); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := 0, len(m.indexers[1].ListKeys()); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	// Events of the namespace delivered late by informers don't add its objects back,
+	// but objects of a namespace created again are kept.
+	indexer := m.tombstones.indexer(m.indexers[0])
+	var added []string
+	h := m.tombstones.handler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			key, _ := cache.MetaNamespaceKeyFunc(obj)
+			added = append(added, key)
+		},
+	})
+	late := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "gone", Name: "a", CreationTimestamp: created}}
+	again := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "gone", Name: "b", CreationTimestamp: metav1.Now()}}
+	for _, pod := range []*v1.Pod{late, again} {
+		_ = indexer.Add(pod)
+		h.OnAdd(pod)
+	}
+	keys := m.indexers[0].ListKeys()
+	sort.Strings(keys)
+	if e, a := []string{"gone/b", "kept/a"}, keys; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := []string{"gone/b"}, added; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
@@ -0,0 +1,241 @@
+package robot
+
+import (
+	"database/sql"
+	"io"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Options configure the robot, zero values mean the defaults.
+type Options struct {
+	// MaxVersionSkew is the max difference of minor versions between api servers
+	// of the clusters, an error is reported when it's exceeded. Zero disables the check.
+	MaxVersionSkew int
+	// DryRunLogInterval is how often events counted in dry run mode are logged,
+	// a minute if zero.
+	DryRunLogInterval time.Duration
+
+	// HealthCheckInterval is how often primary clusters of standbys are checked,
+	// 10s if zero.
+	HealthCheckInterval time.Duration
+
+	// Sharding splits clusters among robot instances, each instance only
+	// monitors its own share. Disabled if nil.
+	Sharding *Sharding
+
+	// CircuitBreaker pauses watching a cluster whose api server fails heavily.
+	CircuitBreaker CircuitBreaker
+
+	// Checkpointer persists the resourceVersions up to which consumers finished
+	// events, so that after restart objects unchanged since are not sent as
+	// EventAdd again. Resources are still listed. Disabled if nil.
+	Checkpointer Checkpointer
+
+	// CheckpointInterval is how often resourceVersions are saved, 10s if zero.
+	CheckpointInterval time.Duration
+
+	// StreamingList lists resources by watch streams with initial events (the
+	// WatchList feature) on clusters supporting it, which reduces memory pressure
+	// of api servers on large lists. Clusters without it fall back to list.
+	StreamingList bool
+
+	// AuditLog is the file to append every emitted event to as a JSON line,
+	// so that what the robot observed can be reconstructed. Disabled if empty.
+	AuditLog string
+
+	// AuditLogMaxSize rotates the audit log once it's larger than that many bytes,
+	// 100MB if zero.
+	AuditLogMaxSize int64
+
+	// AuditLogMaxAge rotates the audit log once it's older than that,
+	// never if zero.
+	AuditLogMaxAge time.Duration
+
+	// AuditLogMaxBackups is how many rotated audit logs are kept, all if zero.
+	AuditLogMaxBackups int
+
+	// NamespaceDeletes sends deletes of the cached objects in a namespace once it's
+	// deleted, as watches may miss deletes during a mass teardown. Events of its
+	// objects delivered late are dropped, so they aren't cached again. Consumers
+	// may receive a delete of an object twice then.
+	NamespaceDeletes bool
+
+	// PreflightAccess reviews whether each cluster allows listing and watching
+	// each resource before watching them, by SelfSubjectAccessReviews.
+	// Resources denied are skipped and reported in an error listing the missing
+	// permissions, rather than retried by reflectors forever.
+	PreflightAccess bool
+
+	// Replicas treats objects of the same key in clusters of resources, which
+	// are replicated intentionally, as one logical object: adds of it in clusters
+	// are consolidated into one add, a replica added later or deleted is sent as
+	// an update, and it's deleted once deleted in all clusters. Events list the
+	// clusters holding it in QueueObject.Replicas. Replicas are objects of the
+	// same key, so it can't be used with ClusterKey or UIDKey. Disabled if nil.
+	Replicas *ReplicaOptions
+
+	// DependsOn declares resources whose caches must sync before the informers
+	// of resources depending on them start in each cluster, so consumers don't
+	// see objects before the ones they refer to, e.g. Endpoints before their
+	// Services. Resources of All are depended on by every other resource, e.g.
+	// {All: {Namespaces}, Endpoints: {Services}}. Cycles are errors.
+	DependsOn map[Resource][]Resource
+
+	// KeyFunc keys objects in the queue and the store, NamespaceKey by default,
+	// e.g. ClusterKey for keys unique across clusters, or UIDKey for keys unique
+	// across an object being deleted and created again.
+	KeyFunc KeyFunc
+
+	// WarmStandby runs informers and keeps the store warm, but sends no event
+	// until Activate is called, e.g. by a replica once elected the leader, which
+	// takes over at once and sends only changes after.
+	WarmStandby bool
+
+	// Inventory is a SQLite database which cached objects are mirrored into,
+	// e.g. opened by sql.Open with a SQLite driver and ":memory:", for ad hoc
+	// queries by Query. Each resource has a table, e.g. pods or deployments_apps,
+	// of columns cluster, namespace, name, labels and json, the last two are JSON.
+	// Objects are written in batches of transactions in the background.
+	// Disabled if nil.
+	Inventory *sql.DB
+
+	// Schemas are JSON Schemas of objects of resources, e.g. the OpenAPI v3 schema
+	// of a CRD. Objects violating them are sent as EventInvalid events besides
+	// their events, with the violations in QueueObject.Validation. Typed objects
+	// of built in resources have no apiVersion and kind unless Unstructured.
+	// The keywords supported are type, properties, required, additionalProperties,
+	// items, enum, pattern, minimum, maximum, minLength, maxLength, minItems,
+	// maxItems and nullable, others are ignored.
+	Schemas map[Resource][]byte
+
+	// Policy evaluates objects by Rego policies of an OPA server, objects
+	// violating them are sent as EventPolicy events besides their events, with
+	// the violations in QueueObject.Policy. Objects are evaluated asynchronously,
+	// so their events are not delayed. Disabled if nil.
+	Policy *PolicyOptions
+
+	// CostLabel is the label which requests of pods are summed by, e.g. team or
+	// cost-center, in each cluster for chargeback. The sums are returned by Costs,
+	// served at /costs of the debug server and exposed by expvar as
+	// robot_requests. Disabled if empty.
+	CostLabel string
+
+	// OnNewImage is called with an image and the pod running it, the first time
+	// the image is seen in a pod of any cluster, including pods running at start.
+	OnNewImage ImageFunc
+
+	// OrphanCheckInterval is how often the caches are checked for orphaned
+	// objects, which are sent as EventOrphan. Disabled if zero.
+	OrphanCheckInterval time.Duration
+
+	// Probe probes the reachability of Services by dialing the addresses of
+	// their Endpoints periodically, and sends EventReachability events of them,
+	// so traffic managers see what's reachable besides what's ready.
+	// Disabled if nil.
+	Probe *ProbeOptions
+
+	// DNS exports DNS records of Services of all clusters rendered from the
+	// store, so the robot is the data source of multi-cluster service DNS.
+	// Disabled if nil.
+	DNS *DNSOptions
+
+	// Drift snapshots resources of all clusters periodically, and reports objects
+	// drifted since the previous snapshot, and from a cluster which is the
+	// source of truth, e.g. for compliance. Disabled if nil.
+	Drift *DriftOptions
+
+	// LatencySLO is the objective of latency from receiving an event to finishing
+	// it, events over it are counted in Histogram.OverSLO of ClusterStatus.
+	LatencySLO time.Duration
+
+	// DebugAddr is the address of the debug server, which serves net/http/pprof
+	// at /debug/pprof/, expvar counters at /debug/vars, cached objects of
+	// a resource at /objects/, e.g. /objects/v1/pods?cluster=one, whose Secrets
+	// have their data redacted as it's unauthenticated, and targets of
+	// cached Pods and Services for the HTTP service discovery of Prometheus at
+	// /prometheus/sd, e.g. /prometheus/sd?role=pod. Disabled if empty.
+	DebugAddr string
+
+	// GraphQLAddr is the address of the GraphQL server, which serves queries
+	// of cached objects of all clusters at /graphql. Disabled if empty.
+	// It supports a subset of GraphQL without variables, fragments or
+	// introspection, see serveGraphQL.
+	GraphQLAddr string
+
+	// XDSAddr is the address of the xDS server, which serves Envoy clusters of
+	// each port of Services and their endpoints in all clusters by the REST-JSON
+	// protocol of xDS v3, at /v3/discovery:clusters and /v3/discovery:endpoints,
+	// so Envoy routes across clusters by the robot as its control plane.
+	// Endpoints are grouped in a locality of each cluster. Disabled if empty.
+	XDSAddr string
+
+	// EventLog is where events of resources with RN.LogEvents are written,
+	// one structured JSON line each by default, e.g. os.Stdout for a log
+	// collector. Each event is written by a single Write, so a writer may
+	// produce each as a message, e.g. to Kafka, see EventLogCodec.
+	EventLog io.Writer
+
+	// EventLogPayload is the content of objects written to EventLog, none by default.
+	// Patches cut the size of updates of large objects, e.g. Endpoints.
+	EventLogPayload Payload
+
+	// EventLogCodec encodes events written to EventLog, JSONCodec if nil,
+	// e.g. ProtobufCodec or AvroCodec for a mandated wire format.
+	EventLogCodec Codec
+
+	// EventLogCompression compresses each event written to EventLog,
+	// e.g. CompressionGzip for large objects. Responses of the debug
+	// server are compressed by gzip if requests accept it.
+	EventLogCompression Compression
+
+	// EventStream numbers events sent by monotonically increasing sequence
+	// numbers, and keeps the last ones for external consumers, which stream
+	// them as JSON lines at /events of the debug server, e.g.
+	// /events?consumer=indexer, and resume after the offset they committed by
+	// CommitOffset, or POST /events/offsets?consumer=indexer&seq=42, after
+	// reconnects. Sequence numbers are reserved at OffsetsPath before they're
+	// used, so they keep increasing after restarts and crashes. The last events
+	// are kept in memory only, consumers behind the last event get 410 Gone
+	// after a restart and resync from the store. Disabled if nil.
+	EventStream *EventStreamOptions
+
+	// HistorySize is how many last versions of each object are kept for History,
+	// disabled if zero. Histories of deleted objects are kept for an hour.
+	HistorySize int
+
+	// Handlers override the handlers of events of resources, e.g. to use custom
+	// keys or add side effects, see HandlerFunc.
+	Handlers map[Resource]HandlerFunc
+
+	// Versions are alternative versions of resources, which are watched on
+	// clusters not serving a resource, e.g. "v2beta2" of autoscaling/v2 HPAs.
+	// Objects are converted to the version of the resource by Converter,
+	// so that consumers see the same version from all clusters.
+	Versions map[Resource][]string
+
+	// Converter converts objects of alternative versions, it's required if
+	// Versions is set. Relabel only changes their apiVersion.
+	Converter Converter
+
+	// Unstructured caches and emits objects of all resources as unstructured
+	// with their apiVersion and kind, instead of typed objects of built in
+	// resources, so consumers aren't coupled to the versions of client-go types.
+	Unstructured bool
+
+	// Scheme has typed Go structs of custom resources, objects of resources not
+	// built in whose kinds are registered in it are decoded and defaulted as
+	// typed objects instead of unstructured. Converter still works on unstructured
+	// objects before they're decoded.
+	Scheme *runtime.Scheme
+
+	// OnInitialSnapshot is called with all objects of each resource in each cluster
+	// once its cache has synced, including after the informers are rebuilt, so
+	// consumers can bulk load them. Events of the objects are still sent.
+	OnInitialSnapshot SnapshotFunc
+
+	// FieldManager is the manager of fields written by Apply and Patch,
+	// "robot" if empty.
+	FieldManager string
+}
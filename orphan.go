@@ -0,0 +1,173 @@
+package robot
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// orphanConfirmations is how many successive checks must find an object
+// orphaned before it's sent, so objects in the middle of a cascading
+// deletion are not.
+const orphanConfirmations = 2
+
+// orphan is an object whose owner isn't found in the cluster.
+type orphan struct {
+	rn  RN
+	key string
+	obj interface{}
+}
+
+type orphanKey struct {
+	rn  RN
+	key string
+}
+
+func (c *controller) detectOrphans() {
+	if c.opts.OrphanCheckInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(c.opts.OrphanCheckInterval)
+	defer ticker.Stop()
+
+	// suspects counts successive checks finding each object orphaned.
+	suspects := make(map[*member]map[orphanKey]int)
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			for _, m := range c.clusters {
+				c.mu.Lock()
+				found := m.orphans()
+				c.mu.Unlock()
+
+				last := suspects[m]
+				next := make(map[orphanKey]int, len(found))
+				for _, one := range found {
+					key := orphanKey{one.rn, one.key}
+					next[key] = last[key] + 1
+					if next[key] == orphanConfirmations {
+						c.replay(m, one.rn)(QueueObject{Event: EventOrphan, RType: one.rn.RType, Key: one.key, CreateAt: time.Now()}, one.obj)
+					}
+				}
+				suspects[m] = next
+			}
+		}
+	}
+}
+
+// builtinKinds are the kinds of built in resources,
+// for clusters whose discovery failed.
+var builtinKinds = map[Resource]string{
+	Services:          "Service",
+	Endpoints:         "Endpoints",
+	Pods:              "Pod",
+	ConfigMaps:        "ConfigMap",
+	Secrets:           "Secret",
+	Nodes:             "Node",
+	PersistentVolumes: "PersistentVolume",
+	Namespaces:        "Namespace",
+}
+
+// apiResource returns the discovery information of the resource.
+func (m *member) apiResource(r Resource) (metav1.APIResource, bool) {
+	if resource, ok := m.served[m.alternative(r)]; ok {
+		return resource, true
+	}
+	if kind, ok := builtinKinds[r]; ok {
+		return metav1.APIResource{Name: r.Resource, Namespaced: !r.clusterScoped(), Kind: kind}, true
+	}
+	return metav1.APIResource{}, false
+}
+
+// orphans returns the cached objects of the member whose owner isn't cached,
+// only synced resources covering the namespace of the owner are looked up.
+// c.mu must be held.
+func (m *member) orphans() []orphan {
+	if !m.running {
+		return nil
+	}
+
+	synced := make(map[RN]bool)
+	for _, one := range m.informers {
+		if one.HasSynced() {
+			synced[one.rn] = true
+		}
+	}
+
+	// lookup returns whether the owner of the kind is cached, and its uid.
+	lookup := func(group, kind, namespace, name string) (found, known bool, uid string) {
+		for i, r := range m.Resources {
+			if !synced[r] || r.NamespaceSelector != "" || r.RType.Group != group {
+				continue
+			}
+			resource, ok := m.apiResource(r.RType)
+			if !ok || resource.Kind != kind {
+				continue
+			}
+			ns := ""
+			if resource.Namespaced {
+				if r.Namespace != "" && r.Namespace != namespace {
+					continue
+				}
+				ns = namespace
+			}
+			obj, exists, err := getByName(m.indexers[i], ns, name)
+			if err != nil {
+				continue
+			}
+			if !exists {
+				return false, true, ""
+			}
+			accessor, err := meta.Accessor(obj)
+			if err != nil {
+				continue
+			}
+			return true, true, string(accessor.GetUID())
+		}
+		return false, false, ""
+	}
+
+	var out []orphan
+	for i, r := range m.Resources {
+		if !synced[r] {
+			continue
+		}
+		for _, obj := range m.indexers[i].List() {
+			accessor, err := meta.Accessor(obj)
+			if err != nil {
+				continue
+			}
+			key, err := m.key(obj)
+			if err != nil {
+				continue
+			}
+			namespace := accessor.GetNamespace()
+
+			orphaned := false
+			if r.RType == Endpoints {
+				// Endpoints are owned by the Service of the same name.
+				found, known, _ := lookup("", "Service", namespace, accessor.GetName())
+				orphaned = known && !found
+			}
+			for _, ref := range accessor.GetOwnerReferences() {
+				gv, err := schema.ParseGroupVersion(ref.APIVersion)
+				if err != nil {
+					continue
+				}
+				found, known, uid := lookup(gv.Group, ref.Kind, namespace, ref.Name)
+				if known && (!found || uid != string(ref.UID)) {
+					orphaned = true
+				}
+			}
+			if orphaned {
+				out = append(out, orphan{rn: r, key: key, obj: obj})
+			}
+		}
+	}
+	return out
+}
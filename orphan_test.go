@@ -0,0 +1,108 @@
+package robot
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestOrphans(t *testing.T) {
+	replicaSets := Resource{Group: "apps", Version: "v1", Resource: "replicasets"}
+	m := &member{
+		Cluster: Cluster{Resources: []RN{{RType: Services}, {RType: Endpoints}, {RType: Pods}, {RType: replicaSets, Namespace: "default"}}},
+		served: map[Resource]metav1.APIResource{
+			replicaSets: {Name: "replicasets", Namespaced: true, Kind: "ReplicaSet"},
+		},
+		running: true,
+	}
+	for _, r := range m.Resources {
+		m.indexers = append(m.indexers, cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, cache.Indexers{}))
+		one := newInformer(r.RType, fakeInformer{})
+		one.rn = r
+		m.informers = append(m.informers, one)
+	}
+
+	owner := func(name, uid string) []metav1.OwnerReference {
+		return []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: name, UID: apitypes.UID("uid-" + uid)}}
+	}
+	_ = m.indexers[0].Add(&v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "one"}})
+	_ = m.indexers[1].Add(&v1.Endpoints{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "one"}})
+	_ = m.indexers[1].Add(&v1.Endpoints{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "two"}})
+	rs := &unstructured.Unstructured{}
+	rs.SetNamespace("default")
+	rs.SetName("rs")
+	rs.SetUID("uid-rs")
+	_ = m.indexers[3].Add(rs)
+	_ = m.indexers[2].Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "owned", OwnerReferences: owner("rs", "rs")}})
+	_ = m.indexers[2].Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "gone", OwnerReferences: owner("gone", "gone")}})
+	_ = m.indexers[2].Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "replaced", OwnerReferences: owner("rs", "old")}})
+	// ReplicaSets of other namespaces aren't watched, so they're unknown.
+	_ = m.indexers[2].Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "unknown", OwnerReferences: owner("gone", "gone")}})
+
+	found := make(map[string]bool)
+	for _, one := range m.orphans() {
+		found[one.rn.RType.String()+" "+one.key] = true
+	}
+	expected := map[string]bool{"endpoints default/two": true, "pods default/gone": true, "pods default/replaced": true}
+	if len(found) != len(expected) {
+		t.Errorf("expected %v, got %v", expected, found)
+	}
+	for key := range expected {
+		if !found[key] {
+			t.Errorf("expected %v, got %v", expected, found)
+		}
+	}
+}
+
+func TestDetectOrphansSkipsBookkeeping(t *testing.T) {
+	replicaSets := Resource{Group: "apps", Version: "v1", Resource: "replicasets"}
+	m := &member{
+		Cluster: Cluster{Name: "one", Resources: []RN{{RType: Pods}, {RType: replicaSets}}},
+		served: map[Resource]metav1.APIResource{
+			replicaSets: {Name: "replicasets", Namespaced: true, Kind: "ReplicaSet"},
+		},
+		running: true,
+		history: newHistory(10),
+	}
+	for _, r := range m.Resources {
+		m.indexers = append(m.indexers, m.newIndexer())
+		one := newInformer(r.RType, fakeInformer{})
+		one.rn = r
+		m.informers = append(m.informers, one)
+	}
+	_ = m.indexers[0].Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "default", Name: "gone", ResourceVersion: "1",
+		OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "gone", UID: "uid-gone"}},
+	}})
+
+	c := &controller{
+		opts:     Options{OrphanCheckInterval: time.Millisecond},
+		queue:    newWorkQueue(),
+		stop:     make(chan struct{}),
+		modified: newModifiedTimes(),
+		clusters: []*member{m},
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.detectOrphans()
+	}()
+	item, _ := c.Pop()
+	close(c.stop)
+	<-done
+
+	if e, a := EventOrphan, item.Event; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if a := c.modified.get(All, nil); !a.IsZero() {
+		t.Errorf("expected no modified time of an orphan event, got %v", a)
+	}
+	if e, a := 0, len(m.history.get(Pods, "default/gone")); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
@@ -0,0 +1,111 @@
+package robot
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// patchOperation is an operation of a JSON Patch, RFC 6902.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// createJSONPatch returns the JSON Patch from the original document to the modified,
+// arrays are replaced as a whole.
+func createJSONPatch(original, modified []byte) ([]byte, error) {
+	var a, b interface{}
+	if err := json.Unmarshal(original, &a); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(modified, &b); err != nil {
+		return nil, err
+	}
+	ops := diffJSON("", a, b, []patchOperation{})
+	return json.Marshal(ops)
+}
+
+func diffJSON(path string, a, b interface{}, ops []patchOperation) []patchOperation {
+	am, aok := a.(map[string]interface{})
+	bm, bok := b.(map[string]interface{})
+	if !aok || !bok {
+		if !reflect.DeepEqual(a, b) {
+			ops = append(ops, patchOperation{Op: "replace", Path: path, Value: rawJSON(b)})
+		}
+		return ops
+	}
+
+	for _, k := range sortedKeys(am) {
+		p := path + "/" + escapePointer(k)
+		if v, ok := bm[k]; ok {
+			ops = diffJSON(p, am[k], v, ops)
+		} else {
+			ops = append(ops, patchOperation{Op: "remove", Path: p})
+		}
+	}
+	for _, k := range sortedKeys(bm) {
+		if _, ok := am[k]; !ok {
+			ops = append(ops, patchOperation{Op: "add", Path: path + "/" + escapePointer(k), Value: rawJSON(bm[k])})
+		}
+	}
+	return ops
+}
+
+// rawJSON encodes a decoded JSON value again.
+func rawJSON(v interface{}) json.RawMessage {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+// createMergePatch returns the JSON Merge Patch, RFC 7386, from the original
+// document to the modified.
+func createMergePatch(original, modified []byte) ([]byte, error) {
+	var a, b interface{}
+	if err := json.Unmarshal(original, &a); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(modified, &b); err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergePatch(a, b))
+}
+
+func mergePatch(a, b interface{}) interface{} {
+	am, aok := a.(map[string]interface{})
+	bm, bok := b.(map[string]interface{})
+	if !aok || !bok {
+		return b
+	}
+
+	patch := make(map[string]interface{})
+	for k, v := range am {
+		if _, ok := bm[k]; !ok {
+			patch[k] = nil
+		} else if !reflect.DeepEqual(v, bm[k]) {
+			patch[k] = mergePatch(v, bm[k])
+		}
+	}
+	for k, v := range bm {
+		if _, ok := am[k]; !ok {
+			patch[k] = v
+		}
+	}
+	return patch
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// escapePointer escapes a key as a reference token of JSON Pointer, RFC 6901.
+func escapePointer(k string) string {
+	return strings.Replace(strings.Replace(k, "~", "~0", -1), "/", "~1", -1)
+}
@@ -0,0 +1,34 @@
+package robot
+
+import (
+	"testing"
+)
+
+func TestCreatePatches(t *testing.T) {
+	original := []byte(`{"metadata":{"name":"one","labels":{"a/b":"1","c":"2"}},"subsets":[1,2]}`)
+	modified := []byte(`{"metadata":{"name":"one","labels":{"a/b":"1","d":"3"}},"subsets":[1,2,3]}`)
+
+	patch, err := createJSONPatch(original, modified)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e := `[{"op":"remove","path":"/metadata/labels/c"},{"op":"add","path":"/metadata/labels/d","value":"3"},{"op":"replace","path":"/subsets","value":[1,2,3]}]`
+	if a := string(patch); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	patch, err = createMergePatch(original, modified)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e = `{"metadata":{"labels":{"c":null,"d":"3"}},"subsets":[1,2,3]}`
+	if a := string(patch); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestEscapePointer(t *testing.T) {
+	if e, a := "a~1b~0c", escapePointer("a/b~c"); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
@@ -0,0 +1,275 @@
+package robot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+// PolicyOptions are options of evaluating objects by Rego policies of an OPA
+// server, see Options.Policy.
+type PolicyOptions struct {
+	// URL is the URL of the OPA server, e.g. "http://localhost:8181".
+	URL string
+
+	// Policies are Rego modules by ids, which are uploaded to the OPA server
+	// once the robot runs. None if the server has the policies already.
+	Policies map[string]string
+
+	// Path is the path of the rule of violations, e.g. "kubernetes/violation"
+	// of a rule violation[{"msg": msg}] in package kubernetes as of Gatekeeper.
+	// The input is {"cluster": cluster, "review": {"operation": operation, "object": object}}.
+	Path string
+
+	// Resources are the resources evaluated, all if empty.
+	Resources []Resource
+
+	// Workers is how many objects are evaluated at a time, 4 if zero.
+	Workers int
+
+	// Client is the client of the OPA server, http.DefaultClient if nil.
+	Client *http.Client
+
+	// OnViolation is called with each EventPolicy, besides sending it,
+	// e.g. to send violations to a sink.
+	OnViolation func(item QueueObject, obj interface{})
+}
+
+// PolicyViolation is a violation of a policy.
+type PolicyViolation struct {
+	Message string
+	Details json.RawMessage
+}
+
+// PolicyResult is the context of an EventPolicy.
+type PolicyResult struct {
+	Violations []PolicyViolation
+}
+
+// policyQueueSize is how many objects wait for evaluation at most,
+// events of more objects are not evaluated.
+const policyQueueSize = 1000
+
+// policyJob is an event of an object to evaluate.
+type policyJob struct {
+	item QueueObject
+	obj  interface{}
+	send emitFunc
+}
+
+// policyEvaluator evaluates objects by the OPA server, and remembers the
+// violations of objects, so that an object is reported once until its
+// violations change. A nil one evaluates nothing.
+type policyEvaluator struct {
+	opts      PolicyOptions
+	resources map[Resource]bool
+	jobs      chan policyJob
+
+	mu       sync.Mutex
+	violated map[string]string
+}
+
+func newPolicyEvaluator(opts PolicyOptions) *policyEvaluator {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	opts.URL = strings.TrimSuffix(opts.URL, "/")
+	opts.Path = strings.Trim(opts.Path, "/")
+
+	resources := make(map[Resource]bool, len(opts.Resources))
+	for _, r := range opts.Resources {
+		resources[r] = true
+	}
+	return &policyEvaluator{
+		opts:      opts,
+		resources: resources,
+		jobs:      make(chan policyJob, policyQueueSize),
+		violated:  make(map[string]string),
+	}
+}
+
+// enqueue queues the event of obj to evaluate, whose violations are sent by send.
+func (p *policyEvaluator) enqueue(item QueueObject, obj interface{}, send emitFunc) {
+	if p == nil || (len(p.resources) > 0 && !p.resources[item.RType]) {
+		return
+	}
+	switch item.Event {
+	case EventAdd, EventUpdate:
+	case EventDelete:
+		p.mu.Lock()
+		delete(p.violated, item.Cluster+"|"+item.RType.String()+"|"+item.Key)
+		p.mu.Unlock()
+		return
+	default:
+		return
+	}
+
+	select {
+	case p.jobs <- policyJob{item, obj, send}:
+	default:
+		metrics.Add(metricPolicyDropped, 1)
+	}
+}
+
+// evaluatePolicies uploads the policies, and evaluates objects queued until
+// the robot stops.
+func (c *controller) evaluatePolicies() {
+	p := c.policy
+	if p == nil {
+		return
+	}
+
+	ids := make([]string, 0, len(p.opts.Policies))
+	for id := range p.opts.Policies {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if err := p.upload(id, p.opts.Policies[id]); err != nil {
+			c.report(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < p.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-c.stop:
+					return
+				case job := <-p.jobs:
+					c.evaluate(job)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// evaluate evaluates the object of the job, and sends an EventPolicy if its
+// violations changed.
+func (c *controller) evaluate(job policyJob) {
+	p := c.policy
+	defer handleCrash(c.report, "policy evaluation of %s %s", job.item.RType, job.item.Key)
+
+	violations, err := p.query(job.item, job.obj)
+	if err != nil {
+		c.report(err)
+		return
+	}
+
+	var s []string
+	for _, one := range violations {
+		s = append(s, one.Message+string(one.Details))
+	}
+	summary := strings.Join(s, "\n")
+	key := job.item.Cluster + "|" + job.item.RType.String() + "|" + job.item.Key
+
+	p.mu.Lock()
+	changed := summary != p.violated[key]
+	if len(violations) == 0 {
+		delete(p.violated, key)
+	} else {
+		p.violated[key] = summary
+	}
+	p.mu.Unlock()
+	if !changed || len(violations) == 0 {
+		return
+	}
+
+	item := job.item
+	item.Event = EventPolicy
+	item.Policy = &PolicyResult{violations}
+	job.send(item, job.obj)
+	if p.opts.OnViolation != nil {
+		p.opts.OnViolation(item, job.obj)
+	}
+}
+
+// upload puts the Rego module of the id to the OPA server.
+func (p *policyEvaluator) upload(id, module string) error {
+	req, err := http.NewRequest(http.MethodPut, p.opts.URL+"/v1/policies/"+id, strings.NewReader(module))
+	if err != nil {
+		return fmt.Errorf("robot: upload policy %s: %v", id, err)
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	resp, err := p.opts.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("robot: upload policy %s: %v", id, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("robot: upload policy %s: %s: %s", id, resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// query returns the violations of the object of the event.
+func (p *policyEvaluator) query(item QueueObject, obj interface{}) ([]PolicyViolation, error) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	operation := "CREATE"
+	if item.Event == EventUpdate {
+		operation = "UPDATE"
+	}
+	input := map[string]interface{}{
+		"input": map[string]interface{}{
+			"cluster": item.Cluster,
+			"review":  map[string]interface{}{"operation": operation, "object": obj},
+		},
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.opts.Client.Post(p.opts.URL+"/v1/data/"+p.opts.Path, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("robot: evaluate policies of %s %s: %v", item.RType, item.Key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("robot: evaluate policies of %s %s: %s: %s", item.RType, item.Key, resp.Status, bytes.TrimSpace(body))
+	}
+
+	var result struct {
+		Result []json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("robot: evaluate policies of %s %s: %v", item.RType, item.Key, err)
+	}
+
+	violations := make([]PolicyViolation, 0, len(result.Result))
+	for _, raw := range result.Result {
+		var violation struct {
+			Msg     string          `json:"msg"`
+			Details json.RawMessage `json:"details"`
+		}
+		var msg string
+		switch {
+		case json.Unmarshal(raw, &msg) == nil:
+			violations = append(violations, PolicyViolation{Message: msg})
+		case json.Unmarshal(raw, &violation) == nil && violation.Msg != "":
+			violations = append(violations, PolicyViolation{Message: violation.Msg, Details: violation.Details})
+		default:
+			violations = append(violations, PolicyViolation{Message: string(raw)})
+		}
+	}
+	sort.Slice(violations, func(i, j int) bool { return violations[i].Message < violations[j].Message })
+	return violations, nil
+}
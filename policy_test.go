@@ -0,0 +1,115 @@
+package robot
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeOPA serves policies uploaded, and violations of objects without a team label.
+func fakeOPA(t *testing.T, policies map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.Method == http.MethodPut && req.URL.Path == "/v1/policies/labels":
+			body, _ := ioutil.ReadAll(req.Body)
+			policies["labels"] = string(body)
+			w.Write([]byte("{}"))
+		case req.Method == http.MethodPost && req.URL.Path == "/v1/data/kubernetes/violation":
+			var input struct {
+				Input struct {
+					Cluster string `json:"cluster"`
+					Review  struct {
+						Object struct {
+							Metadata metav1.ObjectMeta `json:"metadata"`
+						} `json:"object"`
+					} `json:"review"`
+				} `json:"input"`
+			}
+			if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+				t.Error(err)
+			}
+			result := []interface{}{}
+			if input.Input.Review.Object.Metadata.Labels["team"] == "" {
+				result = append(result, map[string]interface{}{"msg": "team label is required", "details": map[string]string{"cluster": input.Input.Cluster}})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"result": result})
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+}
+
+func TestPolicy(t *testing.T) {
+	policies := make(map[string]string)
+	server := fakeOPA(t, policies)
+	defer server.Close()
+
+	var violated []QueueObject
+	robot, err := NewRobotWithOptions(Options{Policy: &PolicyOptions{
+		URL:         server.URL,
+		Path:        "/kubernetes/violation",
+		Policies:    map[string]string{"labels": "package kubernetes"},
+		Resources:   []Resource{Pods},
+		OnViolation: func(item QueueObject, obj interface{}) { violated = append(violated, item) },
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := robot.(*controller)
+	var sent []QueueObject
+	c.queue = &recordQueue{sent: &sent}
+	m := &member{Cluster: Cluster{Name: "east"}}
+	emit := c.emit(m, RN{RType: Pods})
+
+	if err := c.policy.upload("labels", "package kubernetes"); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := "package kubernetes", policies["labels"]; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	pod := func(team string) *v1.Pod {
+		return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Labels: map[string]string{"team": team}}}
+	}
+	evaluate := func() {
+		for len(c.policy.jobs) > 0 {
+			c.evaluate(<-c.policy.jobs)
+		}
+	}
+	emit(QueueObject{Event: EventAdd, RType: Pods, Key: "default/web"}, pod(""))
+	evaluate()
+	emit(QueueObject{Event: EventUpdate, RType: Pods, Key: "default/web"}, pod(""))
+	evaluate()
+	emit(QueueObject{Event: EventUpdate, RType: Pods, Key: "default/web"}, pod("a"))
+	evaluate()
+	emit(QueueObject{Event: EventUpdate, RType: Pods, Key: "default/web"}, pod(""))
+	evaluate()
+
+	var events []event
+	for _, item := range sent {
+		events = append(events, item.Event)
+	}
+	e := []event{EventAdd, EventPolicy, EventUpdate, EventUpdate, EventUpdate, EventPolicy}
+	if a := events; !reflect.DeepEqual(e, a) {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	violation := PolicyViolation{Message: "team label is required", Details: json.RawMessage(`{"cluster":"east"}`)}
+	if e, a := []PolicyViolation{violation}, sent[1].Policy.Violations; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := 2, len(violated); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	// Other resources are not evaluated.
+	c.policy.enqueue(QueueObject{Event: EventAdd, RType: ConfigMaps, Key: "default/web"}, &v1.ConfigMap{}, nil)
+	if e, a := 0, len(c.policy.jobs); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
@@ -0,0 +1,269 @@
+package robot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const defaultScaleInterval = 5 * time.Second
+
+// ProcessFunc processes an object popped from the queue, the object is requeued
+// if it returns an error. ctx is done once PoolOptions.Timeout passes.
+type ProcessFunc func(ctx context.Context, obj QueueObject) error
+
+// PoolOptions are options of the worker pool of Process.
+type PoolOptions struct {
+	// Name names the pool in metrics, "default" by default.
+	Name string
+
+	// MinWorkers is the number of workers kept when the queue is idle, 1 by default.
+	MinWorkers int
+
+	// MaxWorkers bounds the number of workers, MinWorkers by default.
+	MaxWorkers int
+
+	// ScaleInterval is the interval of scaling workers, 5s by default.
+	ScaleInterval time.Duration
+
+	// TargetLatency scales workers up while objects wait in the queue and
+	// the mean latency of processing them is over it, zero disables it.
+	TargetLatency time.Duration
+
+	// OnScale is called with the number of workers when it's changed.
+	OnScale func(workers int)
+
+	// Timeout is the deadline of processing each object, zero disables it.
+	// Workers give up objects timed out and requeue them, even if fn ignores ctx
+	// and keeps running, so a stuck fn can't wedge the pool.
+	Timeout time.Duration
+
+	// DeadLetter is called with objects which timed out and are requeued too
+	// many times, instead of reporting them to Errors. They are finished and
+	// forgotten by the queue before.
+	DeadLetter func(obj QueueObject, err error)
+}
+
+// pool is a pool of workers processing objects of the queue, scaled between
+// opts.MinWorkers and opts.MaxWorkers by the depth of the queue and latency.
+type pool struct {
+	c    *controller
+	fn   ProcessFunc
+	opts PoolOptions
+
+	wg sync.WaitGroup
+
+	mu sync.Mutex
+	// workers is the number of running workers, which is over target
+	// while workers are retiring.
+	workers int
+	target  int
+	// closed is set once the queue is closed, no worker is started then.
+	closed bool
+	// busy and processed are of objects processed since the last scaling.
+	busy      time.Duration
+	processed int
+}
+
+func newPool(c *controller, fn ProcessFunc, opts PoolOptions) *pool {
+	if opts.MinWorkers <= 0 {
+		opts.MinWorkers = 1
+	}
+	if opts.MaxWorkers < opts.MinWorkers {
+		opts.MaxWorkers = opts.MinWorkers
+	}
+	if opts.ScaleInterval <= 0 {
+		opts.ScaleInterval = defaultScaleInterval
+	}
+	if opts.Name == "" {
+		opts.Name = "default"
+	}
+	return &pool{c: c, fn: fn, opts: opts}
+}
+
+// Process processes objects of the queue with fn by a pool of workers,
+// see PoolOptions. It blocks until the robot stops and workers exit.
+func (c *controller) Process(fn ProcessFunc, opts PoolOptions) {
+	p := newPool(c, fn, opts)
+
+	p.mu.Lock()
+	p.scaleTo(p.opts.MinWorkers)
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(p.opts.ScaleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.scale()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	p.wg.Wait()
+	close(done)
+
+	p.mu.Lock()
+	p.target = 0
+	p.changed()
+	p.mu.Unlock()
+}
+
+// Workers returns the number of workers of Process.
+func (c *controller) Workers() int {
+	return int(atomic.LoadInt32(&c.workers))
+}
+
+// scale scales workers by the depth of the queue and latency since the last time:
+// they are doubled when objects wait more than workers or are slow,
+// and reduced by one when the queue is empty.
+func (p *pool) scale() {
+	depth := p.c.len()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var latency time.Duration
+	if p.processed > 0 {
+		latency = p.busy / time.Duration(p.processed)
+	}
+	p.busy, p.processed = 0, 0
+
+	target := p.target
+	switch {
+	case depth > p.target:
+		target = p.target * 2
+	case depth > 0 && p.opts.TargetLatency > 0 && latency > p.opts.TargetLatency:
+		target = p.target * 2
+	case depth == 0:
+		target = p.target - 1
+	}
+	if target > p.opts.MaxWorkers {
+		target = p.opts.MaxWorkers
+	}
+	if target < p.opts.MinWorkers {
+		target = p.opts.MinWorkers
+	}
+	p.scaleTo(target)
+}
+
+// scaleTo starts workers up to target, or lets workers over it retire
+// once they finish their objects. p.mu must be held.
+func (p *pool) scaleTo(target int) {
+	if target == p.target || p.closed {
+		return
+	}
+	for ; p.workers < target; p.workers++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+	p.target = target
+	p.changed()
+}
+
+// changed publishes the number of workers. p.mu must be held.
+func (p *pool) changed() {
+	old := atomic.SwapInt32(&p.c.workers, int32(p.target))
+	metrics.Add(metricWorkers, int64(int32(p.target)-old))
+	klog.Infof("robot: scaled workers to %d", p.target)
+	if p.opts.OnScale != nil {
+		p.opts.OnScale(p.target)
+	}
+}
+
+func (p *pool) work() {
+	defer p.wg.Done()
+
+	for !p.retire() {
+		obj, err := p.c.Pop()
+		if err != nil {
+			p.exit()
+			return
+		}
+
+		start := time.Now()
+		if err := p.process(obj); err != nil {
+			timedOut := err == context.DeadlineExceeded
+			if timedOut {
+				processTimeouts.Add(p.opts.Name, 1)
+			}
+			if rerr := p.c.ReQueue(obj); rerr != nil {
+				if timedOut && p.opts.DeadLetter != nil {
+					p.opts.DeadLetter(obj, err)
+				} else {
+					p.c.report(fmt.Errorf("robot: process %s of cluster %s: %v: %v", obj.Key, obj.Cluster, err, rerr))
+				}
+			}
+		} else {
+			p.c.Finish(obj)
+		}
+		p.observe(time.Since(start))
+	}
+}
+
+// retire reports whether the worker is over the target and should exit.
+func (p *pool) retire() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.workers > p.target {
+		p.workers--
+		return true
+	}
+	return false
+}
+
+// process calls fn with obj, or returns context.DeadlineExceeded
+// once opts.Timeout passes without waiting for fn.
+func (p *pool) process(obj QueueObject) error {
+	if p.opts.Timeout <= 0 {
+		return p.call(context.Background(), obj)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.opts.Timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- p.call(ctx, obj)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// call calls fn with obj, a panic in it is reported and returned as an error.
+func (p *pool) call(ctx context.Context, obj QueueObject) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("robot: panic in processing %s of cluster %s: %v", obj.Key, obj.Cluster, r)
+			p.c.report(err)
+		}
+	}()
+	return p.fn(ctx, obj)
+}
+
+// exit removes the worker once the queue is closed.
+func (p *pool) exit() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.workers--
+	p.closed = true
+}
+
+func (p *pool) observe(latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.busy += latency
+	p.processed++
+}
@@ -0,0 +1,172 @@
+package robot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProcessScales(t *testing.T) {
+	c := &controller{queue: newWorkQueue(), errs: make(chan error, 100)}
+	for i := 0; i < 40; i++ {
+		c.push(QueueObject{Event: EventAdd, RType: Pods, Key: fmt.Sprintf("default/%d", i)})
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	var mu sync.Mutex
+	processed, most := 0, 0
+	opts := PoolOptions{
+		MinWorkers:    1,
+		MaxWorkers:    4,
+		ScaleInterval: 10 * time.Millisecond,
+		OnScale: func(workers int) {
+			mu.Lock()
+			defer mu.Unlock()
+			if workers > most {
+				most = workers
+			}
+		},
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Process(func(ctx context.Context, obj QueueObject) error {
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			processed++
+			if processed == 40 {
+				c.close()
+			}
+			return nil
+		}, opts)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected Process to return once the queue is closed")
+	}
+	if e, a := 40, processed; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := 4, most; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := 0, c.Workers(); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestProcessScalesDown(t *testing.T) {
+	c := &controller{queue: newWorkQueue()}
+	p := newPool(c, func(context.Context, QueueObject) error { return nil }, PoolOptions{MinWorkers: 1, MaxWorkers: 4})
+	p.mu.Lock()
+	p.target, p.workers = 4, 4
+	p.mu.Unlock()
+
+	p.scale()
+	if e, a := 3, c.Workers(); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if !p.retire() {
+		t.Errorf("expected a worker over the target to retire")
+	}
+	if p.retire() {
+		t.Errorf("expected no more worker to retire")
+	}
+}
+
+func TestProcessRequeues(t *testing.T) {
+	q := newWorkQueue()
+	c := &controller{queue: q, errs: make(chan error, 1)}
+	obj := QueueObject{Event: EventAdd, RType: Pods, Key: "default/one"}
+	c.push(obj)
+
+	var calls int
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Process(func(context.Context, QueueObject) error {
+			calls++
+			if calls == 1 {
+				return errors.New("failed")
+			}
+			c.close()
+			return nil
+		}, PoolOptions{})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the object failed to be processed again")
+	}
+	if e, a := 2, calls; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := 0, q.Len(); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestProcessRecoversPanics(t *testing.T) {
+	c := &controller{queue: newWorkQueue(), errs: make(chan error, 1)}
+	p := newPool(c, func(context.Context, QueueObject) error { panic("boom") }, PoolOptions{})
+	if err := p.process(QueueObject{Key: "default/one"}); err == nil {
+		t.Errorf("expected an error of a panic")
+	}
+	select {
+	case <-c.errs:
+	default:
+		t.Errorf("expected the panic to be reported")
+	}
+}
+
+func TestProcessTimeout(t *testing.T) {
+	q := newWorkQueue()
+	obj := QueueObject{Event: EventAdd, RType: Pods, Key: "default/one"}
+	q.push(obj)
+
+	release := make(chan struct{})
+	defer close(release)
+	var dead []QueueObject
+	c := &controller{queue: q, errs: make(chan error, 1)}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Process(func(context.Context, QueueObject) error {
+			<-release
+			return nil
+		}, PoolOptions{
+			Name:    "timeout",
+			Timeout: 10 * time.Millisecond,
+			DeadLetter: func(obj QueueObject, err error) {
+				if e, a := context.DeadlineExceeded, err; e != a {
+					t.Errorf("expected %v, got %v", e, a)
+				}
+				dead = append(dead, obj)
+				c.close()
+			},
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the object timed out to be dead lettered")
+	}
+	if e, a := 1, len(dead); e != a || dead[0] != obj {
+		t.Fatalf("expected %v dead lettered, got %v", obj, dead)
+	}
+	// The object is pushed once and requeued twice before it's given up.
+	if e, a := "3", processTimeouts.Get("timeout"); a == nil || e != a.String() {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := 0, q.NumRequeues(obj); e != a {
+		t.Errorf("expected the object given up to be forgotten, got %v requeues", a)
+	}
+}
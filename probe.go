@@ -0,0 +1,240 @@
+package robot
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ProbeOptions are options of probing the reachability of Services by
+// dialing the addresses of their Endpoints, see Options.Probe.
+type ProbeOptions struct {
+	// Selector selects the Services probed by labels, e.g. "probe=true",
+	// all if empty.
+	Selector string
+
+	// Interval is how often Services are probed, 10s if zero.
+	Interval time.Duration
+
+	// Timeout is the timeout of dialing an address, 1s if zero.
+	Timeout time.Duration
+
+	// Dial dials the TCP addresses, net.Dialer.DialContext if nil.
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Reachability is the context of an EventReachability, the addresses of the
+// Endpoints of a Service as "ip:port".
+type Reachability struct {
+	Reachable   []string
+	Unreachable []string
+
+	// NotReady are the addresses not ready in the Endpoints, which are not probed.
+	NotReady []string
+}
+
+// probeTarget is a Service probed.
+type probeTarget struct {
+	m   *member
+	rn  RN
+	key string
+	obj interface{}
+
+	addresses []string
+	notReady  []string
+}
+
+// probeKey is a Service, or an address, of a member.
+type probeKey struct {
+	m   *member
+	key string
+}
+
+// probeServices probes Services every interval until the robot stops,
+// and sends an EventReachability of a Service once it's probed first and
+// whenever its reachability changes.
+func (c *controller) probeServices() {
+	if c.opts.Probe == nil {
+		return
+	}
+	opts := *c.opts.Probe
+	if opts.Interval <= 0 {
+		opts.Interval = 10 * time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = time.Second
+	}
+	if opts.Dial == nil {
+		opts.Dial = (&net.Dialer{}).DialContext
+	}
+	// It's validated by NewRobotWithOptions.
+	selector, _ := labels.Parse(opts.Selector)
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	var last map[probeKey]Reachability
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			last = c.probeOnce(opts, selector, last)
+		}
+	}
+}
+
+// probeOnce probes the Services selected in all clusters, sends the events of
+// those whose reachability changed from last, and returns their reachability.
+func (c *controller) probeOnce(opts ProbeOptions, selector labels.Selector, last map[probeKey]Reachability) map[probeKey]Reachability {
+	var targets []probeTarget
+	c.mu.Lock()
+	for _, m := range c.clusters {
+		targets = append(targets, m.probeTargets(selector)...)
+	}
+	c.mu.Unlock()
+
+	var mu sync.Mutex
+	reachable := make(map[probeKey]bool)
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		for _, address := range target.addresses {
+			key := probeKey{target.m, address}
+			mu.Lock()
+			_, probed := reachable[key]
+			reachable[key] = false
+			mu.Unlock()
+			if probed {
+				continue
+			}
+
+			wg.Add(1)
+			go func(key probeKey, address string) {
+				defer wg.Done()
+				ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+				defer cancel()
+				conn, err := opts.Dial(ctx, "tcp", address)
+				if err != nil {
+					return
+				}
+				conn.Close()
+				mu.Lock()
+				reachable[key] = true
+				mu.Unlock()
+			}(key, address)
+		}
+	}
+	wg.Wait()
+
+	next := make(map[probeKey]Reachability, len(targets))
+	for _, target := range targets {
+		var r Reachability
+		for _, address := range target.addresses {
+			if reachable[probeKey{target.m, address}] {
+				r.Reachable = append(r.Reachable, address)
+			} else {
+				r.Unreachable = append(r.Unreachable, address)
+			}
+		}
+		r.NotReady = target.notReady
+
+		key := probeKey{target.m, target.key}
+		next[key] = r
+		if previous, ok := last[key]; ok && reflect.DeepEqual(previous, r) {
+			continue
+		}
+		c.replay(target.m, target.rn)(QueueObject{Event: EventReachability, RType: Services, Key: target.key, CreateAt: time.Now(), Reachability: &r}, target.obj)
+	}
+	return next
+}
+
+// probeTargets returns the cached Services of the member selected, with the
+// addresses of their Endpoints. c.mu must be held.
+func (m *member) probeTargets(selector labels.Selector) []probeTarget {
+	if !m.running {
+		return nil
+	}
+
+	var endpoints []int
+	for i, r := range m.Resources {
+		if r.RType == Endpoints {
+			endpoints = append(endpoints, i)
+		}
+	}
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	var targets []probeTarget
+	for i, r := range m.Resources {
+		if r.RType != Services {
+			continue
+		}
+		for _, obj := range m.indexers[i].List() {
+			accessor, err := meta.Accessor(obj)
+			if err != nil || !selector.Matches(labels.Set(accessor.GetLabels())) {
+				continue
+			}
+			key, err := m.key(obj)
+			if err != nil {
+				continue
+			}
+			target := probeTarget{m: m, rn: r, key: key, obj: obj}
+			for _, j := range endpoints {
+				found, exists, err := getByName(m.indexers[j], accessor.GetNamespace(), accessor.GetName())
+				if err != nil || !exists {
+					continue
+				}
+				if one, ok := endpointsOf(found); ok {
+					target.addresses, target.notReady = endpointAddresses(one)
+					break
+				}
+			}
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// endpointsOf returns obj as Endpoints, false if it isn't one.
+func endpointsOf(obj interface{}) (*v1.Endpoints, bool) {
+	switch o := obj.(type) {
+	case *v1.Endpoints:
+		return o, true
+	case *unstructured.Unstructured:
+		endpoints := &v1.Endpoints{}
+		return endpoints, fromUnstructured(o, "Endpoints", endpoints)
+	}
+	return nil, false
+}
+
+// endpointAddresses returns the ready and not ready TCP addresses of the
+// Endpoints, sorted.
+func endpointAddresses(endpoints *v1.Endpoints) (ready, notReady []string) {
+	for _, subset := range endpoints.Subsets {
+		for _, port := range subset.Ports {
+			if port.Protocol != "" && port.Protocol != v1.ProtocolTCP {
+				continue
+			}
+			p := strconv.Itoa(int(port.Port))
+			for _, address := range subset.Addresses {
+				ready = append(ready, net.JoinHostPort(address.IP, p))
+			}
+			for _, address := range subset.NotReadyAddresses {
+				notReady = append(notReady, net.JoinHostPort(address.IP, p))
+			}
+		}
+	}
+	sort.Strings(ready)
+	sort.Strings(notReady)
+	return ready, notReady
+}
@@ -0,0 +1,95 @@
+package robot
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestProbe(t *testing.T) {
+	robot, err := NewRobotWithOptions(Options{Probe: &ProbeOptions{Selector: "probe=true"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := robot.(*controller)
+	var sent []QueueObject
+	c.queue = &recordQueue{sent: &sent}
+
+	m := &member{
+		Cluster: Cluster{Name: "east", Resources: []RN{{RType: Services}, {RType: Endpoints}}},
+		running: true,
+	}
+	for range m.Resources {
+		m.indexers = append(m.indexers, m.newIndexer())
+	}
+	c.clusters = []*member{m}
+
+	probed := metav1.ObjectMeta{Namespace: "default", Name: "web", Labels: map[string]string{"probe": "true"}}
+	_ = m.indexers[0].Add(&v1.Service{ObjectMeta: probed})
+	_ = m.indexers[0].Add(&v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other"}})
+	_ = m.indexers[1].Add(&v1.Endpoints{
+		ObjectMeta: probed,
+		Subsets: []v1.EndpointSubset{{
+			Addresses:         []v1.EndpointAddress{{IP: "10.0.0.1"}, {IP: "10.0.0.2"}},
+			NotReadyAddresses: []v1.EndpointAddress{{IP: "10.0.0.3"}},
+			Ports:             []v1.EndpointPort{{Port: 80, Protocol: v1.ProtocolTCP}, {Port: 53, Protocol: v1.ProtocolUDP}},
+		}},
+	})
+
+	down := map[string]bool{"10.0.0.2:80": true}
+	opts := ProbeOptions{
+		Timeout: time.Second,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			if down[address] {
+				return nil, errors.New("connection refused")
+			}
+			client, server := net.Pipe()
+			server.Close()
+			return client, nil
+		},
+	}
+	selector, _ := labels.Parse("probe=true")
+
+	last := c.probeOnce(opts, selector, nil)
+	last = c.probeOnce(opts, selector, last)
+	if e, a := 1, len(sent); e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	if e, a := EventReachability, sent[0].Event; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := "default/web", sent[0].Key; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	e := &Reachability{Reachable: []string{"10.0.0.1:80"}, Unreachable: []string{"10.0.0.2:80"}, NotReady: []string{"10.0.0.3:80"}}
+	if a := sent[0].Reachability; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %+v, got %+v", e, a)
+	}
+
+	delete(down, "10.0.0.2:80")
+	c.probeOnce(opts, selector, last)
+	if e, a := 2, len(sent); e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	if e, a := []string{"10.0.0.1:80", "10.0.0.2:80"}, sent[1].Reachability.Reachable; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	// Probes don't change the Services, so they aren't modified.
+	if a := c.modified.get(All, nil); !a.IsZero() {
+		t.Errorf("expected no modified time of reachability events, got %v", a)
+	}
+}
+
+func TestProbeSelector(t *testing.T) {
+	_, err := NewRobotWithOptions(Options{Probe: &ProbeOptions{Selector: "a b"}})
+	if err == nil {
+		t.Error("expected an error")
+	}
+}
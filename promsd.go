@@ -0,0 +1,145 @@
+package robot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// prometheusSDPath is the path of the Prometheus HTTP service discovery
+// endpoint of the debug server.
+const prometheusSDPath = "/prometheus/sd"
+
+// targetGroup is a target group of the http_sd format of Prometheus.
+type targetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// invalidLabelChars are characters not allowed in names of Prometheus labels.
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// servePrometheusSD serves targets of the cached Pods and Services in the
+// http_sd format, of the role in the query, "pod" or "service", both if not
+// given, and of the cluster in the query if given.
+// A Pod has a target of each TCP port of its containers at its IP, or a target
+// of its IP if it has none, and a Service has a target of each TCP port at its
+// name in the cluster DNS. Labels are cluster, namespace and __meta_robot_*.
+func (c *controller) servePrometheusSD(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	role := req.URL.Query().Get("role")
+	if role != "" && role != "pod" && role != "service" {
+		http.Error(w, fmt.Sprintf("invalid role %q, expected pod or service", role), http.StatusBadRequest)
+		return
+	}
+
+	groups := c.targetGroups(role, req.URL.Query().Get("cluster"))
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(groups); err != nil {
+		c.report(fmt.Errorf("robot: write prometheus targets: %v", err))
+	}
+}
+
+// targetGroups returns the target groups of the role in the cluster, of all
+// roles or clusters if empty, sorted by targets.
+func (c *controller) targetGroups(role, cluster string) []targetGroup {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	groups := []targetGroup{}
+	for _, m := range c.clusters {
+		if cluster != "" && !m.is(cluster) {
+			continue
+		}
+		for i, r := range m.Resources {
+			switch {
+			case r.RType == Pods && role != "service":
+				for _, obj := range m.indexers[i].List() {
+					if pod, ok := podOf(obj); ok {
+						groups = append(groups, podTargets(m.String(), pod)...)
+					}
+				}
+			case r.RType == Services && role != "pod":
+				for _, obj := range m.indexers[i].List() {
+					if service, ok := serviceOf(obj); ok {
+						groups = append(groups, serviceTargets(m.String(), service)...)
+					}
+				}
+			}
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Labels["cluster"] != groups[j].Labels["cluster"] {
+			return groups[i].Labels["cluster"] < groups[j].Labels["cluster"]
+		}
+		return groups[i].Targets[0] < groups[j].Targets[0]
+	})
+	return groups
+}
+
+// targetLabels returns the labels of a target of the object.
+func targetLabels(cluster, role, namespace, name string, objectLabels map[string]string) map[string]string {
+	labels := map[string]string{
+		"cluster":   cluster,
+		"namespace": namespace,
+
+		"__meta_robot_role":    role,
+		"__meta_robot_" + role: name,
+	}
+	for k, v := range objectLabels {
+		labels["__meta_robot_"+role+"_label_"+invalidLabelChars.ReplaceAllString(k, "_")] = v
+	}
+	return labels
+}
+
+// podTargets returns the targets of the pod, none if it has no IP or it's terminated.
+func podTargets(cluster string, pod *v1.Pod) []targetGroup {
+	if pod.Status.PodIP == "" || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+		return nil
+	}
+
+	var groups []targetGroup
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if port.Protocol != "" && port.Protocol != v1.ProtocolTCP {
+				continue
+			}
+			labels := targetLabels(cluster, "pod", pod.Namespace, pod.Name, pod.Labels)
+			labels["__meta_robot_pod_node"] = pod.Spec.NodeName
+			labels["__meta_robot_pod_container"] = container.Name
+			labels["__meta_robot_pod_port_name"] = port.Name
+			target := net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(int(port.ContainerPort)))
+			groups = append(groups, targetGroup{Targets: []string{target}, Labels: labels})
+		}
+	}
+	if len(groups) == 0 {
+		labels := targetLabels(cluster, "pod", pod.Namespace, pod.Name, pod.Labels)
+		labels["__meta_robot_pod_node"] = pod.Spec.NodeName
+		groups = append(groups, targetGroup{Targets: []string{pod.Status.PodIP}, Labels: labels})
+	}
+	return groups
+}
+
+// serviceTargets returns the targets of the service at name.namespace.svc.
+func serviceTargets(cluster string, service *v1.Service) []targetGroup {
+	var groups []targetGroup
+	for _, port := range service.Spec.Ports {
+		if port.Protocol != "" && port.Protocol != v1.ProtocolTCP {
+			continue
+		}
+		labels := targetLabels(cluster, "service", service.Namespace, service.Name, service.Labels)
+		labels["__meta_robot_service_port_name"] = port.Name
+		target := net.JoinHostPort(service.Name+"."+service.Namespace+".svc", strconv.Itoa(int(port.Port)))
+		groups = append(groups, targetGroup{Targets: []string{target}, Labels: labels})
+	}
+	return groups
+}
@@ -0,0 +1,92 @@
+package robot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPrometheusSD(t *testing.T) {
+	m := &member{Cluster: Cluster{Name: "east", Resources: []RN{{RType: Pods}, {RType: Services}}}}
+	for range m.Resources {
+		m.indexers = append(m.indexers, m.newIndexer())
+	}
+	c := &controller{clusters: []*member{m}}
+
+	_ = m.indexers[0].Add(&v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Labels: map[string]string{"app.kubernetes.io/name": "web"}},
+		Spec: v1.PodSpec{NodeName: "node-1", Containers: []v1.Container{{
+			Name:  "app",
+			Ports: []v1.ContainerPort{{Name: "metrics", ContainerPort: 9090}, {Name: "dns", ContainerPort: 53, Protocol: v1.ProtocolUDP}},
+		}}},
+		Status: v1.PodStatus{Phase: v1.PodRunning, PodIP: "10.0.0.1"},
+	})
+	_ = m.indexers[0].Add(&v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "done"},
+		Status:     v1.PodStatus{Phase: v1.PodSucceeded, PodIP: "10.0.0.2"},
+	})
+	_ = m.indexers[1].Add(&v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec:       v1.ServiceSpec{Ports: []v1.ServicePort{{Name: "http", Port: 80}}},
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(c.servePrometheusSD))
+	defer server.Close()
+
+	get := func(query string) []targetGroup {
+		resp, err := http.Get(server.URL + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var groups []targetGroup
+		if err := json.NewDecoder(resp.Body).Decode(&groups); err != nil {
+			t.Fatal(err)
+		}
+		return groups
+	}
+
+	groups := get("?role=pod")
+	e := []targetGroup{{
+		Targets: []string{"10.0.0.1:9090"},
+		Labels: map[string]string{
+			"cluster":           "east",
+			"namespace":         "default",
+			"__meta_robot_role": "pod",
+			"__meta_robot_pod":  "web",
+			"__meta_robot_pod_label_app_kubernetes_io_name": "web",
+			"__meta_robot_pod_node":                         "node-1",
+			"__meta_robot_pod_container":                    "app",
+			"__meta_robot_pod_port_name":                    "metrics",
+		},
+	}}
+	if a := groups; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	groups = get("")
+	if e, a := 2, len(groups); e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	if e, a := []string{"web.default.svc:80"}, groups[1].Targets; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if e, a := 0, len(get("?cluster=west")); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	resp, err := http.Get(server.URL + "?role=node")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if e, a := http.StatusBadRequest, resp.StatusCode; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
@@ -14,12 +14,16 @@ type queue interface {
 	Pop() (QueueObject, error)
 
 	// ReQueue indicates if an object fails to execute, he should be rejoined in the queue.
-	// But it can`t be ReQueue for more than 3 times.
+	// But it can`t be ReQueue for more than 3 times, the object is finished then.
+	// Finish must not be called after ReQueue.
 	ReQueue(QueueObject) error
 
 	// Finish indicates that an object has been successfully processed.
 	Finish(QueueObject)
 
+	// len returns the number of objects waiting in the queue.
+	len() int
+
 	// Close will cause queue to ignore all new items added to it. As soon as the
 	// worker goroutines have drained the existing items in the queue, they will be
 	// instructed to exit.
@@ -45,7 +49,7 @@ func (c *wq) push(obj QueueObject) {
 func (c *wq) Pop() (QueueObject, error) {
 	obj, quit := c.Get()
 	if quit {
-		return QueueObject{}, errors.New("Controller has been stoped. ")
+		return QueueObject{}, ErrQueueClosed
 	}
 
 	return obj.(QueueObject), nil
@@ -61,6 +65,9 @@ func (c *wq) ReQueue(obj QueueObject) error {
 		// Re-enqueue the key rate limited. Based on the rate limiter on the
 		// queue and the re-enqueue history, the key will be processed later again.
 		c.AddRateLimited(obj)
+		// Done lets the workqueue hand the object out again, it stays in
+		// the processing set and is never popped again otherwise.
+		c.Done(obj)
 		return nil
 	}
 
@@ -71,6 +78,10 @@ func (c *wq) ReQueue(obj QueueObject) error {
 	return errors.New("This object has been requeued for many times, but still fails. ")
 }
 
+func (c *wq) len() int {
+	return c.Len()
+}
+
 func (c *wq) close() {
 	c.ShutDown()
 }
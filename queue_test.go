@@ -10,8 +10,8 @@ func TestQueue(t *testing.T) {
 
 	createTime := time.Now()
 
-	objOne := QueueObject{EventAdd, Endpoints, "one", createTime}
-	objTwo := QueueObject{EventAdd, Endpoints, "two", createTime}
+	objOne := QueueObject{Event: EventAdd, RType: Endpoints, Key: "one", CreateAt: createTime}
+	objTwo := QueueObject{Event: EventAdd, RType: Endpoints, Key: "two", CreateAt: createTime}
 
 	q.push(objOne)
 
@@ -0,0 +1,188 @@
+package robot
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/time/rate"
+)
+
+// Overflow is what to do with events over a rate limit.
+type Overflow int
+
+const (
+	// OverflowDrop drops events over the limit, and counts them.
+	OverflowDrop Overflow = iota
+
+	// OverflowCoalesce delays events over the limit, and only sends
+	// the last pending event of each key.
+	OverflowCoalesce
+)
+
+// RateLimit limits events by a token bucket.
+type RateLimit struct {
+	// Rate is the max events per second, no limit if zero.
+	Rate float64
+
+	// Burst is the size of the bucket, Rate rounded up if zero.
+	Burst int
+
+	Overflow Overflow
+}
+
+type pendingKey struct {
+	resource Resource
+	key      string
+}
+
+type pendingEvent struct {
+	item QueueObject
+	obj  interface{}
+	next emitFunc
+}
+
+// limiter limits events to the rate of RateLimit, a nil limiter doesn't limit.
+type limiter struct {
+	limiter  *rate.Limiter
+	overflow Overflow
+
+	// dropped counts events dropped or coalesced, accessed atomically.
+	dropped int64
+
+	mu      sync.Mutex
+	pending map[pendingKey]*pendingEvent
+	order   []pendingKey
+	wake    chan struct{}
+}
+
+func newLimiter(limit RateLimit, stop <-chan struct{}) *limiter {
+	if limit.Rate <= 0 {
+		return nil
+	}
+
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = int(limit.Rate)
+		if float64(burst) < limit.Rate {
+			burst++
+		}
+	}
+
+	l := &limiter{
+		limiter:  rate.NewLimiter(rate.Limit(limit.Rate), burst),
+		overflow: limit.Overflow,
+		pending:  make(map[pendingKey]*pendingEvent),
+		wake:     make(chan struct{}, 1),
+	}
+	if l.overflow == OverflowCoalesce {
+		go l.flush(stop)
+	}
+	return l
+}
+
+// wrap returns an emitFunc which sends events to next within the limit.
+func (l *limiter) wrap(next emitFunc) emitFunc {
+	if l == nil {
+		return next
+	}
+	return func(item QueueObject, obj interface{}) {
+		l.admit(item, obj, next)
+	}
+}
+
+func (l *limiter) admit(item QueueObject, obj interface{}, next emitFunc) {
+	if l.overflow != OverflowCoalesce {
+		if l.limiter.Allow() {
+			next(item, obj)
+		} else {
+			atomic.AddInt64(&l.dropped, 1)
+			metrics.Add(metricDropped, 1)
+		}
+		return
+	}
+
+	l.mu.Lock()
+	// Events must wait behind pending ones to keep the order.
+	if len(l.order) == 0 && l.limiter.Allow() {
+		l.mu.Unlock()
+		next(item, obj)
+		return
+	}
+
+	key := pendingKey{item.RType, item.Key}
+	if _, ok := l.pending[key]; ok {
+		atomic.AddInt64(&l.dropped, 1)
+		metrics.Add(metricDropped, 1)
+	} else {
+		l.order = append(l.order, key)
+	}
+	l.pending[key] = &pendingEvent{item, obj, next}
+	l.mu.Unlock()
+
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+// flush sends pending events as the limit allows, until stop is closed.
+func (l *limiter) flush(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-l.wake:
+		}
+
+		for l.hasPending() {
+			if err := l.limiter.Wait(ctx); err != nil {
+				return
+			}
+			event, ok := l.pop()
+			if !ok {
+				break
+			}
+			event.next(event.item, event.obj)
+		}
+	}
+}
+
+func (l *limiter) hasPending() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.order) > 0
+}
+
+func (l *limiter) pop() (*pendingEvent, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.order) == 0 {
+		return nil, false
+	}
+	key := l.order[0]
+	l.order = l.order[1:]
+
+	event := l.pending[key]
+	delete(l.pending, key)
+	return event, true
+}
+
+func (l *limiter) droppedCount() int64 {
+	if l == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&l.dropped)
+}
@@ -0,0 +1,66 @@
+package robot
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiterDrop(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	l := newLimiter(RateLimit{Rate: 1}, stop)
+
+	var sent []QueueObject
+	emit := l.wrap(func(item QueueObject, obj interface{}) {
+		sent = append(sent, item)
+	})
+	for i := 0; i < 3; i++ {
+		emit(QueueObject{Event: EventUpdate, RType: Pods, Key: "default/one"}, nil)
+	}
+
+	if e, a := 1, len(sent); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := int64(2), l.droppedCount(); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestLimiterCoalesce(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	l := newLimiter(RateLimit{Rate: 20, Burst: 1, Overflow: OverflowCoalesce}, stop)
+
+	var mu sync.Mutex
+	var sent []QueueObject
+	emit := l.wrap(func(item QueueObject, obj interface{}) {
+		mu.Lock()
+		sent = append(sent, item)
+		mu.Unlock()
+	})
+	emit(QueueObject{Event: EventAdd, RType: Pods, Key: "default/one"}, nil)
+	emit(QueueObject{Event: EventUpdate, RType: Pods, Key: "default/one"}, nil)
+	emit(QueueObject{Event: EventAdd, RType: Pods, Key: "default/two"}, nil)
+	emit(QueueObject{Event: EventDelete, RType: Pods, Key: "default/one"}, nil)
+
+	time.Sleep(300 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	expected := []event{EventAdd, EventDelete, EventAdd}
+	if e, a := len(expected), len(sent); e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	for i, e := range expected {
+		if a := sent[i].Event; e != a {
+			t.Errorf("%d: expected %v, got %v", i, e, a)
+		}
+	}
+	if e, a := int64(1), l.droppedCount(); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
@@ -0,0 +1,113 @@
+package robot
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay is how long to wait for a kubeconfig file to settle
+// before rebuilding clients, as it is usually written in several steps.
+const reloadDelay = time.Second
+
+// watchConfigs watches the kubeconfig files of clusters with HotReload,
+// and reloads those clusters when their files change, until the robot stops.
+func (c *controller) watchConfigs() {
+	files := make(map[string][]*member)
+	for _, m := range c.clusters {
+		if m.HotReload && m.ConfigPath != "" {
+			path := filepath.Clean(m.ConfigPath)
+			files[path] = append(files[path], m)
+		}
+	}
+	if len(files) == 0 {
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		c.report(fmt.Errorf("robot: watch kubeconfig files: %v", err))
+		return
+	}
+	defer watcher.Close()
+
+	// Watch the directories rather than the files, since kubeconfig files
+	// are often replaced by renaming, which would end a watch on the file.
+	dirs := make(map[string]bool)
+	for path := range files {
+		dir := filepath.Dir(path)
+		if dirs[dir] {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			c.report(fmt.Errorf("robot: watch kubeconfig files in %s: %v", dir, err))
+			continue
+		}
+		dirs[dir] = true
+	}
+
+	pending := make(map[string]bool)
+	var settle <-chan time.Time
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			path := filepath.Clean(event.Name)
+			if _, ok := files[path]; ok && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+				pending[path] = true
+				settle = time.After(reloadDelay)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			c.report(fmt.Errorf("robot: watch kubeconfig files: %v", err))
+		case <-settle:
+			for path := range pending {
+				for _, m := range files[path] {
+					c.reload(m)
+				}
+				delete(pending, path)
+			}
+		}
+	}
+}
+
+// reload rebuilds the client of the member and restarts its informers,
+// the store is kept so only the differences are sent as events.
+func (c *controller) reload(m *member) {
+	client, err := m.newClient()
+	if err != nil {
+		c.report(fmt.Errorf("robot: reload kubeconfig %s: %v", m.ConfigPath, err))
+		return
+	}
+
+	// Discovery may be slow, so it's done without c.mu held.
+	d := m.discover(client, c.report)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.stop:
+		return
+	default:
+	}
+
+	running := m.running
+	m.halt()
+
+	m.install(client, d, c.emitter(m), c.report)
+	if running {
+		m.run(c.report)
+	}
+
+	c.checkVersionSkew()
+}
@@ -0,0 +1,150 @@
+package robot
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ReplicaOptions are options of treating objects replicated to several
+// clusters intentionally as one logical object, see Options.Replicas.
+type ReplicaOptions struct {
+	// Resources are the resources whose objects of the same key in clusters
+	// are replicas of one object.
+	Resources []Resource
+
+	// Window is how long adds of an object are collected from clusters into
+	// one add, 5s if zero.
+	Window time.Duration
+}
+
+// Replicas is the context of events of replicated objects.
+type Replicas struct {
+	// Clusters are the clusters holding the object, sorted.
+	Clusters []string
+}
+
+type replicaKey struct {
+	resource Resource
+	key      string
+}
+
+// replicaObject is a logical object replicated to clusters.
+type replicaObject struct {
+	clusters map[string]bool
+
+	// pending is set while adds are collected, and item, obj and send are
+	// of the first add, which is sent once the window passes.
+	pending bool
+	item    QueueObject
+	obj     interface{}
+	send    emitFunc
+}
+
+func (o *replicaObject) replicas() *Replicas {
+	clusters := make([]string, 0, len(o.clusters))
+	for cluster := range o.clusters {
+		clusters = append(clusters, cluster)
+	}
+	sort.Strings(clusters)
+	return &Replicas{Clusters: clusters}
+}
+
+// replicaTracker consolidates events of replicas of objects: adds of an object
+// in clusters within the window are sent as one add, a later add or a delete of
+// a replica is sent as an update, and the object is deleted once it's deleted
+// in all clusters. A nil one sends events as they are.
+type replicaTracker struct {
+	window    time.Duration
+	resources map[Resource]bool
+
+	mu      sync.Mutex
+	objects map[replicaKey]*replicaObject
+}
+
+func newReplicaTracker(opts ReplicaOptions) *replicaTracker {
+	if opts.Window <= 0 {
+		opts.Window = 5 * time.Second
+	}
+	resources := make(map[Resource]bool, len(opts.Resources))
+	for _, r := range opts.Resources {
+		resources[r] = true
+	}
+	return &replicaTracker{window: opts.Window, resources: resources, objects: make(map[replicaKey]*replicaObject)}
+}
+
+// emit sends the event of obj by send, consolidated with events of the
+// replicas of the object.
+func (t *replicaTracker) emit(item QueueObject, obj interface{}, send emitFunc) {
+	if t == nil || !t.resources[item.RType] {
+		send(item, obj)
+		return
+	}
+	switch item.Event {
+	case EventAdd, EventUpdate, EventDelete:
+	default:
+		send(item, obj)
+		return
+	}
+
+	k := replicaKey{item.RType, item.Key}
+	event := item.Event
+	t.mu.Lock()
+	o := t.objects[k]
+	switch {
+	case o == nil && item.Event == EventDelete:
+	case o == nil:
+		o = &replicaObject{clusters: map[string]bool{item.Cluster: true}}
+		t.objects[k] = o
+		if item.Event == EventAdd {
+			o.pending, o.item, o.obj, o.send = true, item, obj, send
+			t.mu.Unlock()
+			time.AfterFunc(t.window, func() { t.flush(k, o) })
+			return
+		}
+	case item.Event == EventDelete:
+		delete(o.clusters, item.Cluster)
+		if len(o.clusters) == 0 {
+			delete(t.objects, k)
+			if o.pending {
+				// Added and deleted within the window, nothing is sent.
+				t.mu.Unlock()
+				return
+			}
+		} else {
+			item.Event = EventUpdate
+		}
+	default:
+		o.clusters[item.Cluster] = true
+		item.Event = EventUpdate
+	}
+	if o != nil && o.pending {
+		// The add sent is of the latest object of its cluster.
+		if o.item.Cluster == item.Cluster && event == EventUpdate {
+			o.obj = obj
+		}
+		t.mu.Unlock()
+		return
+	}
+	if o != nil {
+		item.Replicas = o.replicas()
+	}
+	t.mu.Unlock()
+
+	send(item, obj)
+}
+
+// flush sends the add of the object once the window passes.
+func (t *replicaTracker) flush(k replicaKey, o *replicaObject) {
+	t.mu.Lock()
+	if t.objects[k] != o || !o.pending {
+		t.mu.Unlock()
+		return
+	}
+	item, obj, send := o.item, o.obj, o.send
+	item.Replicas = o.replicas()
+	o.pending, o.item, o.obj, o.send = false, QueueObject{}, nil, nil
+	t.mu.Unlock()
+
+	send(item, obj)
+}
@@ -0,0 +1,92 @@
+package robot
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestReplicaTracker(t *testing.T) {
+	var sent []QueueObject
+	send := func(item QueueObject, obj interface{}) {
+		sent = append(sent, item)
+	}
+	tr := newReplicaTracker(ReplicaOptions{Resources: []Resource{ConfigMaps}, Window: time.Hour})
+	newEvent := func(e event, cluster string) QueueObject {
+		return QueueObject{Event: e, RType: ConfigMaps, Key: "default/one", Cluster: cluster}
+	}
+
+	tr.emit(newEvent(EventAdd, "east"), &v1.ConfigMap{}, send)
+	tr.emit(newEvent(EventAdd, "west"), &v1.ConfigMap{}, send)
+	if e, a := 0, len(sent); e != a {
+		t.Fatalf("expected adds collected, got %v", sent)
+	}
+
+	o := tr.objects[replicaKey{ConfigMaps, "default/one"}]
+	tr.flush(replicaKey{ConfigMaps, "default/one"}, o)
+	if e, a := 1, len(sent); e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	if sent[0].Event != EventAdd || !reflect.DeepEqual([]string{"east", "west"}, sent[0].Replicas.Clusters) {
+		t.Errorf("expected an add of both clusters, got %v %v", sent[0].Event, sent[0].Replicas)
+	}
+
+	tr.emit(newEvent(EventAdd, "north"), &v1.ConfigMap{}, send)
+	tr.emit(newEvent(EventDelete, "east"), &v1.ConfigMap{}, send)
+	tr.emit(newEvent(EventDelete, "west"), &v1.ConfigMap{}, send)
+	tr.emit(newEvent(EventDelete, "north"), &v1.ConfigMap{}, send)
+	expected := []struct {
+		event    event
+		clusters []string
+	}{
+		{EventUpdate, []string{"east", "north", "west"}},
+		{EventUpdate, []string{"north", "west"}},
+		{EventUpdate, []string{"north"}},
+		{EventDelete, []string{}},
+	}
+	if e, a := len(expected)+1, len(sent); e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	for i, e := range expected {
+		a := sent[i+1]
+		if a.Event != e.event || !reflect.DeepEqual(e.clusters, a.Replicas.Clusters) {
+			t.Errorf("%d: expected %v of %v, got %v of %v", i, e.event, e.clusters, a.Event, a.Replicas.Clusters)
+		}
+	}
+	if len(tr.objects) != 0 {
+		t.Errorf("expected the object forgotten, got %v", tr.objects)
+	}
+}
+
+func TestReplicaTrackerAddedAndDeleted(t *testing.T) {
+	var sent []QueueObject
+	send := func(item QueueObject, obj interface{}) {
+		sent = append(sent, item)
+	}
+	tr := newReplicaTracker(ReplicaOptions{Resources: []Resource{ConfigMaps}, Window: 10 * time.Millisecond})
+
+	tr.emit(QueueObject{Event: EventAdd, RType: ConfigMaps, Key: "default/one", Cluster: "east"}, &v1.ConfigMap{}, send)
+	tr.emit(QueueObject{Event: EventDelete, RType: ConfigMaps, Key: "default/one", Cluster: "east"}, &v1.ConfigMap{}, send)
+	tr.emit(QueueObject{Event: EventAdd, RType: Pods, Key: "default/one", Cluster: "east"}, &v1.Pod{}, send)
+	time.Sleep(50 * time.Millisecond)
+
+	if e, a := 1, len(sent); e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	if sent[0].RType != Pods || sent[0].Replicas != nil {
+		t.Errorf("expected the event of pods as is, got %v", sent[0])
+	}
+}
+
+func TestReplicasKeyFunc(t *testing.T) {
+	for _, keyFunc := range []KeyFunc{ClusterKey, UIDKey} {
+		if _, err := NewRobotWithOptions(Options{Replicas: &ReplicaOptions{}, KeyFunc: keyFunc}); err == nil {
+			t.Errorf("expected an error of keys unique in clusters")
+		}
+	}
+	if _, err := NewRobotWithOptions(Options{Replicas: &ReplicaOptions{}, KeyFunc: NamespaceKey}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
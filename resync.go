@@ -0,0 +1,45 @@
+package robot
+
+import (
+	"time"
+)
+
+func (c *controller) Resync(resource Resource, clusters ...string) error {
+	c.mu.Lock()
+	var members []*member
+	for _, name := range clusters {
+		found := false
+		for _, m := range c.clusters {
+			if m.is(name) {
+				members = append(members, m)
+				found = true
+			}
+		}
+		if !found {
+			c.mu.Unlock()
+			return &ErrClusterNotFound{name}
+		}
+	}
+	if len(clusters) == 0 {
+		members = c.clusters
+	}
+	c.mu.Unlock()
+
+	for _, m := range members {
+		for i, r := range m.Resources {
+			if resource != All && resource != r.RType {
+				continue
+			}
+
+			emit := c.replay(m, r)
+			for _, obj := range m.indexers[i].List() {
+				key, err := m.key(obj)
+				if err != nil {
+					continue
+				}
+				emit(QueueObject{Event: EventUpdate, RType: r.RType, Key: key, CreateAt: time.Now()}, obj)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package robot
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestResync(t *testing.T) {
+	var sent []QueueObject
+	c := &controller{queue: &recordQueue{sent: &sent}}
+
+	for _, url := range []string{"https://one.example.com", "https://two.example.com"} {
+		m := &member{Cluster: Cluster{MasterUrl: url, Resources: []RN{{RType: Pods}, {RType: Services}}}}
+		for range m.Resources {
+			m.indexers = append(m.indexers, cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, cache.Indexers{}))
+		}
+		_ = m.indexers[0].Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "one"}})
+		_ = m.indexers[1].Add(&v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "one"}})
+		c.clusters = append(c.clusters, m)
+	}
+
+	if err := c.Resync(Pods, "https://two.example.com"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := 1, len(sent); e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	if e, a := EventUpdate, sent[0].Event; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := "https://two.example.com", sent[0].Cluster; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if err := c.Resync(All); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := 5, len(sent); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if err := c.Resync(Pods, "unknown"); err == nil {
+		t.Errorf("expected an error for an unknown cluster")
+	}
+}
+
+func TestResyncSkipsBookkeeping(t *testing.T) {
+	var sent []QueueObject
+	c := &controller{queue: &recordQueue{sent: &sent}, modified: newModifiedTimes()}
+	m := &member{Cluster: Cluster{Name: "one", Resources: []RN{{RType: Pods}}}, history: newHistory(10)}
+	m.indexers = append(m.indexers, m.newIndexer())
+	c.clusters = append(c.clusters, m)
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "one", ResourceVersion: "1"}}
+	_ = m.indexers[0].Add(pod)
+	c.emit(m, RN{RType: Pods})(QueueObject{Event: EventAdd, RType: Pods, Key: "default/one"}, pod)
+	modified := c.modified.get(Pods, nil)
+
+	if err := c.Resync(Pods); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := 2, len(sent); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := 1, len(m.history.get(Pods, "default/one")); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := modified, c.modified.get(Pods, nil); e != a {
+		t.Errorf("expected objects replayed unmodified at %v, got %v", e, a)
+	}
+}
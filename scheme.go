@@ -0,0 +1,94 @@
+package robot
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// schemeKind returns the kind of the resource if its objects are typed by
+// Options.Scheme, which applies to resources not built in.
+func (m *member) schemeKind(r Resource) (schema.GroupVersionKind, bool) {
+	if m.scheme == nil || r.typed() {
+		return schema.GroupVersionKind{}, false
+	}
+	resource, ok := m.apiResource(r)
+	if !ok {
+		return schema.GroupVersionKind{}, false
+	}
+	gvk := r.GroupVersionResource().GroupVersion().WithKind(resource.Kind)
+	return gvk, m.scheme.Recognizes(gvk)
+}
+
+// typedListWatch decodes unstructured objects listed and watched to typed
+// objects of the scheme, which are defaulted.
+func (m *member) typedListWatch(lw cache.ListerWatcher, gvk schema.GroupVersionKind) cache.ListerWatcher {
+	scheme := m.scheme
+	one := func(obj runtime.Object) (runtime.Object, error) {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			return obj, nil
+		}
+		out, err := scheme.New(gvk)
+		if err != nil {
+			return nil, err
+		}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, out); err != nil {
+			return nil, err
+		}
+		out.GetObjectKind().SetGroupVersionKind(gvk)
+		scheme.Default(out)
+		return out, nil
+	}
+
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			obj, err := lw.List(options)
+			if err != nil {
+				return nil, err
+			}
+			list, ok := obj.(*unstructured.UnstructuredList)
+			if !ok {
+				return obj, nil
+			}
+			out := &metav1.List{ListMeta: metav1.ListMeta{
+				ResourceVersion: list.GetResourceVersion(),
+				Continue:        list.GetContinue(),
+			}}
+			for i := range list.Items {
+				typed, err := one(&list.Items[i])
+				if err != nil {
+					return nil, fmt.Errorf("robot: decode %s as %s: %v", list.Items[i].GetName(), gvk, err)
+				}
+				out.Items = append(out.Items, runtime.RawExtension{Object: typed})
+			}
+			return out, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			w, err := lw.Watch(options)
+			if err != nil {
+				return nil, err
+			}
+			return watch.Filter(w, func(in watch.Event) (watch.Event, bool) {
+				if in.Type == watch.Error || in.Object == nil {
+					return in, true
+				}
+				out, err := one(in.Object)
+				if err != nil {
+					// Let the reflector relist.
+					return watch.Event{Type: watch.Error, Object: &metav1.Status{
+						Status:  metav1.StatusFailure,
+						Message: fmt.Sprintf("robot: decode as %s: %v", gvk, err),
+					}}, true
+				}
+				in.Object = out
+				return in, true
+			}), nil
+		},
+	}
+}
@@ -0,0 +1,89 @@
+package robot
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+type testWidget struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Size int `json:"size"`
+}
+
+func (w *testWidget) DeepCopyObject() runtime.Object {
+	out := *w
+	w.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	return &out
+}
+
+func TestSchemeTypedObjects(t *testing.T) {
+	widgets := Resource{Group: "example.com", Version: "v1", Resource: "widgets"}
+	gv := schema.GroupVersion{Group: "example.com", Version: "v1"}
+
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(gv, &testWidget{})
+	scheme.AddTypeDefaultingFunc(&testWidget{}, func(obj interface{}) {
+		if w := obj.(*testWidget); w.Size == 0 {
+			w.Size = 1
+		}
+	})
+
+	m := &member{
+		scheme: scheme,
+		served: map[Resource]metav1.APIResource{widgets: {Name: "widgets", Namespaced: true, Kind: "testWidget"}},
+	}
+	if _, ok := m.object(widgets).(*testWidget); !ok {
+		t.Fatalf("expected a typed object, got %T", m.object(widgets))
+	}
+
+	u := unstructured.Unstructured{}
+	u.SetAPIVersion("example.com/v1")
+	u.SetKind("testWidget")
+	u.SetNamespace("default")
+	u.SetName("one")
+	gvk, _ := m.schemeKind(widgets)
+	w := watch.NewFake()
+	lw := m.typedListWatch(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return &unstructured.UnstructuredList{Items: []unstructured.Unstructured{u}}, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return w, nil
+		},
+	}, gvk)
+
+	list, err := lw.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	widget, ok := items[0].(*testWidget)
+	if !ok {
+		t.Fatalf("expected a typed object, got %T", items[0])
+	}
+	if e, a := 1, widget.Size; e != a {
+		t.Errorf("expected defaulted %v, got %v", e, a)
+	}
+
+	typed, err := lw.Watch(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer typed.Stop()
+	go w.Add(u.DeepCopy())
+	if event := <-typed.ResultChan(); event.Object.(*testWidget).Name != "one" {
+		t.Errorf("expected widget one, got %v", event.Object)
+	}
+}
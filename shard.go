@@ -0,0 +1,278 @@
+package robot
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+const (
+	// shardLabel labels Leases of robot instances with their group.
+	shardLabel = "robot.servicemesh/shard-group"
+
+	// shardReplicas is the number of virtual nodes of each instance on the hash ring.
+	shardReplicas = 100
+
+	defaultShardLeaseDuration = 15 * time.Second
+)
+
+// Sharding splits clusters among robot instances by consistent hashing.
+// Each instance holds a Lease as its membership, and clusters are rebalanced
+// when instances join or leave. Clusters are the unit of sharding, namespaces
+// of a cluster are never split among instances. Once a cluster is released
+// to another instance, deletes of its cached objects are sent, and the
+// instance taking it over sends adds of them.
+type Sharding struct {
+	// Client accesses the cluster where Leases are kept.
+	Client kubernetes.Interface
+
+	Namespace string
+
+	// Group of instances splitting the same clusters.
+	Group string
+
+	// Identity of this instance, the hostname if empty.
+	Identity string
+
+	// LeaseDuration is how long an instance is considered alive
+	// after renewing its Lease, 15s if zero.
+	LeaseDuration time.Duration
+}
+
+func (s *Sharding) identity() string {
+	if s.Identity != "" {
+		return s.Identity
+	}
+	hostname, _ := os.Hostname()
+	return hostname
+}
+
+func (s *Sharding) leaseDuration() time.Duration {
+	if s.LeaseDuration > 0 {
+		return s.LeaseDuration
+	}
+	return defaultShardLeaseDuration
+}
+
+// shard renews the Lease of this instance and rebalances clusters periodically,
+// until the robot stops.
+func (c *controller) shard() {
+	s := c.opts.Sharding
+	if s == nil {
+		return
+	}
+
+	ticker := time.NewTicker(s.leaseDuration() / 3)
+	defer ticker.Stop()
+
+	for {
+		c.rebalance()
+
+		select {
+		case <-c.stop:
+			// Leave the group, so others take over without waiting for the Lease to expire.
+			err := s.Client.CoordinationV1().Leases(s.Namespace).Delete(s.leaseName(), &metav1.DeleteOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				c.report(fmt.Errorf("robot: delete shard lease: %v", err))
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Sharding) leaseName() string {
+	return s.Group + "-" + s.identity()
+}
+
+// rebalance renews the Lease, and runs the clusters owned by this instance.
+func (c *controller) rebalance() {
+	s := c.opts.Sharding
+
+	if err := s.renew(); err != nil {
+		c.report(fmt.Errorf("robot: renew shard lease: %v", err))
+		return
+	}
+	instances, err := s.instances()
+	if err != nil {
+		c.report(fmt.Errorf("robot: list shard leases: %v", err))
+		return
+	}
+	ring := newHashRing(instances)
+
+	// Clusters assigned are discovered without c.mu held, as discovery of
+	// many clusters may be slow, and installed and run after.
+	var assigned, released []*member
+	clients := make(map[*member]kubernetes.Interface)
+
+	c.mu.Lock()
+	select {
+	case <-c.stop:
+		c.mu.Unlock()
+		return
+	default:
+	}
+	for _, m := range c.clusters {
+		owned := ring.owner(m.String()) == s.identity()
+		if owned && !m.running && !m.degraded {
+			klog.Infof("robot: cluster %s is assigned to this instance", m)
+			if m.informers != nil {
+				m.run(c.report)
+				continue
+			}
+			assigned = append(assigned, m)
+			clients[m] = m.client
+		}
+		if !owned && m.running {
+			klog.Infof("robot: cluster %s is released to another instance", m)
+			m.halt()
+			released = append(released, m)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, m := range released {
+		c.release(m)
+	}
+
+	discovered := make(map[*member]*discovered, len(assigned))
+	for _, m := range assigned {
+		discovered[m] = m.discover(clients[m], c.report)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.stop:
+		return
+	default:
+	}
+	for _, m := range assigned {
+		// It may be run or degraded meanwhile.
+		if m.running || m.degraded || m.informers != nil {
+			continue
+		}
+		m.install(clients[m], discovered[m], c.emitter(m), c.report)
+		m.run(c.report)
+	}
+}
+
+// release deletes the cached objects of the member halted, and sends deletes
+// of them, so consumers don't keep objects watched by another instance.
+func (c *controller) release(m *member) {
+	for i, r := range m.Resources {
+		emit := c.emit(m, r)
+		for _, key := range m.indexers[i].ListKeys() {
+			obj, exists, err := m.indexers[i].GetByKey(key)
+			if err != nil || !exists {
+				continue
+			}
+			if err := m.indexers[i].Delete(obj); err != nil {
+				continue
+			}
+			emit(QueueObject{Event: EventDelete, RType: r.RType, Key: key, CreateAt: time.Now()}, obj)
+		}
+	}
+}
+
+func (s *Sharding) renew() error {
+	leases := s.Client.CoordinationV1().Leases(s.Namespace)
+
+	identity := s.identity()
+	seconds := int32(s.leaseDuration() / time.Second)
+	now := metav1.NewMicroTime(time.Now())
+	spec := coordinationv1.LeaseSpec{
+		HolderIdentity:       &identity,
+		LeaseDurationSeconds: &seconds,
+		RenewTime:            &now,
+	}
+
+	lease, err := leases.Get(s.leaseName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = leases.Create(&coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   s.leaseName(),
+				Labels: map[string]string{shardLabel: s.Group},
+			},
+			Spec: spec,
+		})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	lease.Spec = spec
+	_, err = leases.Update(lease)
+	return err
+}
+
+// instances returns identities of alive instances of the group.
+func (s *Sharding) instances() ([]string, error) {
+	list, err := s.Client.CoordinationV1().Leases(s.Namespace).List(metav1.ListOptions{
+		LabelSelector: shardLabel + "=" + s.Group,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	now := time.Now()
+	for _, lease := range list.Items {
+		spec := lease.Spec
+		if spec.HolderIdentity == nil || spec.RenewTime == nil || spec.LeaseDurationSeconds == nil {
+			continue
+		}
+		if spec.RenewTime.Add(time.Duration(*spec.LeaseDurationSeconds) * time.Second).After(now) {
+			out = append(out, *spec.HolderIdentity)
+		}
+	}
+	return out, nil
+}
+
+// hashRing is a consistent hash ring of instances.
+type hashRing struct {
+	hashes []uint32
+	owners map[uint32]string
+}
+
+func newHashRing(instances []string) *hashRing {
+	r := &hashRing{owners: make(map[uint32]string)}
+	for _, instance := range instances {
+		for i := 0; i < shardReplicas; i++ {
+			h := hash(instance + "#" + strconv.Itoa(i))
+			r.hashes = append(r.hashes, h)
+			r.owners[h] = instance
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+	return r
+}
+
+// owner returns the instance owning the key, empty if there is no instance.
+func (r *hashRing) owner(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+	h := hash(key)
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[r.hashes[i]]
+}
+
+func hash(s string) uint32 {
+	sum := sha1.Sum([]byte(s))
+	return binary.BigEndian.Uint32(sum[:4])
+}
@@ -0,0 +1,96 @@
+package robot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestHashRing(t *testing.T) {
+	ring := newHashRing([]string{"a", "b", "c"})
+
+	owned := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		owned[ring.owner(fmt.Sprintf("cluster-%d", i))]++
+	}
+	for _, instance := range []string{"a", "b", "c"} {
+		if owned[instance] < 50 {
+			t.Errorf("expected a fair share of %s, got %v", instance, owned[instance])
+		}
+	}
+
+	// keys of remaining instances don't move when an instance leaves
+	smaller := newHashRing([]string{"a", "b"})
+	for i := 0; i < 300; i++ {
+		key := fmt.Sprintf("cluster-%d", i)
+		if owner := ring.owner(key); owner != "c" && owner != smaller.owner(key) {
+			t.Errorf("expected %s to stay with %s, got %s", key, owner, smaller.owner(key))
+		}
+	}
+
+	if e, a := "", newHashRing(nil).owner("cluster"); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestRebalance(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	other, seconds, now := "other", int32(60), metav1.NewMicroTime(time.Now())
+	_, err := client.CoordinationV1().Leases("robot").Create(&coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: "group-other", Labels: map[string]string{shardLabel: "group"}},
+		Spec:       coordinationv1.LeaseSpec{HolderIdentity: &other, LeaseDurationSeconds: &seconds, RenewTime: &now},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &controller{
+		opts: Options{Sharding: &Sharding{Client: client, Namespace: "robot", Group: "group", Identity: "self"}},
+		stop: make(chan struct{}),
+		errs: make(chan error, 10),
+	}
+	defer close(c.stop)
+	for i := 0; i < 10; i++ {
+		c.clusters = append(c.clusters, &member{
+			Cluster:   Cluster{MasterUrl: fmt.Sprintf("https://%d.example.com", i)},
+			informers: informerSet{newInformer(Pods, fakeInformer{})},
+			stop:      make(chan struct{}),
+		})
+	}
+
+	c.rebalance()
+
+	ring := newHashRing([]string{"other", "self"})
+	for _, m := range c.clusters {
+		if e, a := ring.owner(m.String()) == "self", m.running; e != a {
+			t.Errorf("%s: expected running %v, got %v", m, e, a)
+		}
+	}
+
+	if _, err := client.CoordinationV1().Leases("robot").Get("group-self", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the lease of this instance: %v", err)
+	}
+}
+
+func TestRelease(t *testing.T) {
+	var sent []QueueObject
+	c := &controller{queue: &recordQueue{sent: &sent}, modified: newModifiedTimes()}
+	m := &member{Cluster: Cluster{Name: "one", Resources: []RN{{RType: Pods}}}}
+	m.indexers = append(m.indexers, m.newIndexer())
+	_ = m.indexers[0].Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}})
+
+	c.release(m)
+
+	if e, a := 0, len(m.indexers[0].ListKeys()); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if len(sent) != 1 || sent[0].Event != EventDelete || sent[0].Key != "default/web" {
+		t.Errorf("expected a delete of default/web, got %v", sent)
+	}
+}
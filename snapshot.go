@@ -0,0 +1,40 @@
+package robot
+
+import (
+	"errors"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// SnapshotFunc receives all objects of the resource in the cluster once its cache
+// has synced, see Options.OnInitialSnapshot.
+type SnapshotFunc func(resource Resource, cluster string, objs []runtime.Object)
+
+// snapshot calls m.onSnapshot with the objects of the informer once it has synced,
+// unless it stops before that.
+func (m *member) snapshot(one *informer, stop <-chan struct{}, report func(error)) {
+	defer handleCrash(report, "initial snapshot of %s in cluster %s", one.resource, m)
+
+	err := wait.PollImmediateUntil(100*time.Millisecond, func() (bool, error) {
+		select {
+		case <-one.exited:
+			return false, errors.New("exited")
+		default:
+		}
+		return one.HasSynced(), nil
+	}, stop)
+	if err != nil || one.indexer == nil {
+		return
+	}
+
+	list := one.indexer.List()
+	objs := make([]runtime.Object, 0, len(list))
+	for _, obj := range list {
+		if o, ok := obj.(runtime.Object); ok {
+			objs = append(objs, o)
+		}
+	}
+	m.onSnapshot(one.rn.RType, m.String(), objs)
+}
@@ -0,0 +1,53 @@
+package robot
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestInitialSnapshot(t *testing.T) {
+	type snapshot struct {
+		resource Resource
+		cluster  string
+		objs     []runtime.Object
+	}
+	snapshots := make(chan snapshot, 1)
+
+	indexer := cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, cache.Indexers{})
+	_ = indexer.Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "one"}})
+	_ = indexer.Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "two"}})
+
+	one := newInformer(Pods, fakeInformer{})
+	one.rn = RN{RType: Pods}
+	one.indexer = indexer
+	m := &member{
+		Cluster:   Cluster{MasterUrl: "https://one.example.com"},
+		informers: informerSet{one},
+		stop:      make(chan struct{}),
+		onSnapshot: func(resource Resource, cluster string, objs []runtime.Object) {
+			snapshots <- snapshot{resource, cluster, objs}
+		},
+	}
+	m.run(func(error) {})
+	defer m.halt()
+
+	select {
+	case s := <-snapshots:
+		if e, a := Pods, s.resource; e != a {
+			t.Errorf("expected %v, got %v", e, a)
+		}
+		if e, a := "https://one.example.com", s.cluster; e != a {
+			t.Errorf("expected %v, got %v", e, a)
+		}
+		if e, a := 2, len(s.objs); e != a {
+			t.Errorf("expected %v, got %v", e, a)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected a snapshot")
+	}
+}
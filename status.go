@@ -0,0 +1,123 @@
+package robot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/version"
+)
+
+// ClusterStatus is the status of a cluster monitored by the robot.
+type ClusterStatus struct {
+	Cluster string
+
+	// Version of the api server, nil if unknown.
+	Version *version.Info
+
+	// Observed is the number of events of each resource counted in dry run mode.
+	Observed map[Resource]int64
+
+	// Dropped is the number of events dropped or coalesced by rate limits.
+	Dropped int64
+
+	// Healthy is false when health checks of the cluster fail.
+	Healthy bool
+
+	// Degraded is true while watching the cluster is paused by the circuit breaker.
+	Degraded bool
+
+	// Suppressed is true if the cluster is a standby of a healthy primary.
+	Suppressed bool
+
+	// Latency is the histogram of latencies from receiving events of each
+	// resource to finishing them.
+	Latency map[Resource]Histogram
+
+	// ClockSkew is how far the clock of the api server is estimated ahead of
+	// the local clock, see QueueObject.ClockSkew.
+	ClockSkew time.Duration
+
+	// Capacity is the capacity of the nodes of the cluster, nil if Nodes are not watched.
+	Capacity *Capacity
+}
+
+func (c *controller) Status() []ClusterStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := make([]ClusterStatus, 0, len(c.clusters))
+	for _, m := range c.clusters {
+		dropped := m.limiter.droppedCount()
+		for _, l := range m.limiters {
+			dropped += l.droppedCount()
+		}
+
+		out = append(out, ClusterStatus{
+			Cluster:    m.String(),
+			Version:    m.version,
+			Observed:   m.observed.counts(),
+			Dropped:    dropped,
+			Healthy:    m.isHealthy(),
+			Degraded:   m.degraded,
+			Suppressed: m.primary != nil && m.primary.isHealthy(),
+			Latency:    m.latency.snapshot(),
+			ClockSkew:  m.clock.get(),
+			Capacity:   m.capacity(),
+		})
+	}
+	return out
+}
+
+// checkVersionSkew reports an error if minor versions of the api servers
+// differ more than opts.MaxVersionSkew.
+func (c *controller) checkVersionSkew() {
+	if c.opts.MaxVersionSkew <= 0 {
+		return
+	}
+
+	var oldest, newest *member
+	for _, m := range c.clusters {
+		if _, ok := minorVersion(m.version); !ok {
+			continue
+		}
+		if oldest == nil || lessVersion(m.version, oldest.version) {
+			oldest = m
+		}
+		if newest == nil || lessVersion(newest.version, m.version) {
+			newest = m
+		}
+	}
+	if oldest == nil {
+		return
+	}
+
+	oldMinor, _ := minorVersion(oldest.version)
+	newMinor, _ := minorVersion(newest.version)
+	if oldest.version.Major != newest.version.Major || newMinor-oldMinor > c.opts.MaxVersionSkew {
+		c.report(fmt.Errorf("robot: version skew between cluster %s (%s) and cluster %s (%s) exceeds %d minor versions",
+			oldest, oldest.version, newest, newest.version, c.opts.MaxVersionSkew))
+	}
+}
+
+// minorVersion parses the minor version, e.g. "14+" of GKE.
+func minorVersion(v *version.Info) (int, bool) {
+	if v == nil {
+		return 0, false
+	}
+	minor, err := strconv.Atoi(strings.TrimRight(v.Minor, "+"))
+	if err != nil {
+		return 0, false
+	}
+	return minor, true
+}
+
+func lessVersion(a, b *version.Info) bool {
+	if a.Major != b.Major {
+		return a.Major < b.Major
+	}
+	aMinor, _ := minorVersion(a)
+	bMinor, _ := minorVersion(b)
+	return aMinor < bMinor
+}
@@ -0,0 +1,35 @@
+package robot
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/version"
+)
+
+func TestCheckVersionSkew(t *testing.T) {
+	tests := []struct {
+		minors   []string
+		skew     int
+		expected int
+	}{
+		{[]string{"14", "13"}, 1, 0},
+		{[]string{"14+", "12", "13"}, 1, 1},
+		{[]string{"14", "11"}, 0, 0},
+		{[]string{"14", "unknown"}, 1, 0},
+	}
+
+	for i, test := range tests {
+		c := &controller{
+			opts: Options{MaxVersionSkew: test.skew},
+			errs: make(chan error, 10),
+		}
+		for _, minor := range test.minors {
+			c.clusters = append(c.clusters, &member{version: &version.Info{Major: "1", Minor: minor}})
+		}
+
+		c.checkVersionSkew()
+		if e, a := test.expected, len(c.errs); e != a {
+			t.Errorf("%d: expected %v errors, got %v", i, e, a)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package robot
 
 import (
+	"strings"
+
 	"k8s.io/client-go/tools/cache"
 )
 
@@ -9,6 +11,8 @@ type store interface {
 
 	ListKeys(Resource) []string
 
+	// GetByKey returns the objects of the key in all clusters, keys of cluster
+	// scoped resources are names, a namespace in them is ignored.
 	GetByKey(r Resource, key string) (items []interface{}, exists bool)
 }
 
@@ -16,59 +20,29 @@ var _ store = mapIndexerSet{}
 
 type mapIndexerSet map[Resource][]cache.Indexer
 
+// indexers returns the indexers of the resource, all indexers for All.
+func (mt mapIndexerSet) indexers(r Resource) []cache.Indexer {
+	if r != All {
+		return mt[r]
+	}
+
+	var all []cache.Indexer
+	for _, set := range mt {
+		all = append(all, set...)
+	}
+	return all
+}
+
 func (mt mapIndexerSet) List(r Resource) (l []interface{}) {
-	switch r {
-	case All:
-		for _, set := range mt {
-			for _, indexer := range set {
-				l = append(l, indexer.List()...)
-			}
-		}
-	case Services:
-		for _, indexer := range mt[Services] {
-			l = append(l, indexer.List()...)
-		}
-	case Pods:
-		for _, indexer := range mt[Pods] {
-			l = append(l, indexer.List()...)
-		}
-	case Endpoints:
-		for _, indexer := range mt[Endpoints] {
-			l = append(l, indexer.List()...)
-		}
-	case ConfigMaps:
-		for _, indexer := range mt[ConfigMaps] {
-			l = append(l, indexer.List()...)
-		}
+	for _, indexer := range mt.indexers(r) {
+		l = append(l, indexer.List()...)
 	}
 	return
 }
 
 func (mt mapIndexerSet) ListKeys(r Resource) (keys []string) {
-	switch r {
-	case All:
-		for _, set := range mt {
-			for _, indexer := range set {
-				keys = append(keys, indexer.ListKeys()...)
-			}
-		}
-	case Services:
-		for _, indexer := range mt[Services] {
-			keys = append(keys, indexer.ListKeys()...)
-
-		}
-	case Pods:
-		for _, indexer := range mt[Pods] {
-			keys = append(keys, indexer.ListKeys()...)
-		}
-	case Endpoints:
-		for _, indexer := range mt[Endpoints] {
-			keys = append(keys, indexer.ListKeys()...)
-		}
-	case ConfigMaps:
-		for _, indexer := range mt[ConfigMaps] {
-			keys = append(keys, indexer.ListKeys()...)
-		}
+	for _, indexer := range mt.indexers(r) {
+		keys = append(keys, indexer.ListKeys()...)
 	}
 	return
 }
@@ -77,63 +51,17 @@ func (mt mapIndexerSet) GetByKey(r Resource, key string) ([]interface{}, bool) {
 	var iterms []interface{}
 	ok := false
 
-	switch r {
-	case All:
-		for _, set := range mt {
-			for _, indexer := range set {
-				item, exists, err := indexer.GetByKey(key)
-				if err != nil {
-					continue
-				}
-				if exists {
-					ok = true
-					iterms = append(iterms, item)
-				}
-			}
-		}
-	case Services:
-		for _, indexer := range mt[Services] {
-			item, exists, err := indexer.GetByKey(key)
-			if err != nil {
-				continue
-			}
-			if exists {
-				ok = true
-				iterms = append(iterms, item)
-			}
-		}
-	case Pods:
-		for _, indexer := range mt[Pods] {
-			item, exists, err := indexer.GetByKey(key)
-			if err != nil {
-				continue
-			}
-			if exists {
-				ok = true
-				iterms = append(iterms, item)
-			}
+	for _, indexer := range mt.indexers(r) {
+		item, exists, err := indexer.GetByKey(key)
+		if err == nil && !exists && r.clusterScoped() {
+			item, exists, err = indexer.GetByKey(key[strings.LastIndex(key, "/")+1:])
 		}
-	case Endpoints:
-		for _, indexer := range mt[Endpoints] {
-			item, exists, err := indexer.GetByKey(key)
-			if err != nil {
-				continue
-			}
-			if exists {
-				ok = true
-				iterms = append(iterms, item)
-			}
+		if err != nil {
+			continue
 		}
-	case ConfigMaps:
-		for _, indexer := range mt[ConfigMaps] {
-			item, exists, err := indexer.GetByKey(key)
-			if err != nil {
-				continue
-			}
-			if exists {
-				ok = true
-				iterms = append(iterms, item)
-			}
+		if exists {
+			ok = true
+			iterms = append(iterms, item)
 		}
 	}
 
@@ -0,0 +1,62 @@
+package robot
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestStoreScopes(t *testing.T) {
+	pods := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	pods.Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "one"}})
+	nodes := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	nodes.Add(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "one"}})
+	roles := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	clusterRoles := Resource{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles"}
+	roles.Add(&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "admin"}})
+
+	s := mapIndexerSet{Pods: {pods}, Nodes: {nodes}, clusterRoles: {roles}}
+	if e, a := 3, len(s.ListKeys(All)); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := []string{"admin"}, s.ListKeys(clusterRoles); len(a) != 1 || e[0] != a[0] {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	for _, test := range []struct {
+		r      Resource
+		key    string
+		exists bool
+	}{
+		{r: Pods, key: "default/one", exists: true},
+		{r: Pods, key: "one"},
+		{r: Nodes, key: "one", exists: true},
+		{r: Nodes, key: "default/one", exists: true},
+		{r: clusterRoles, key: "admin", exists: true},
+	} {
+		if _, a := s.GetByKey(test.r, test.key); test.exists != a {
+			t.Errorf("expected %v of %s %s, got %v", test.exists, test.r, test.key, a)
+		}
+	}
+}
+
+func TestNamespaced(t *testing.T) {
+	clusterRoles := Resource{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles"}
+	m := &member{served: map[Resource]metav1.APIResource{
+		clusterRoles: {Name: "clusterroles"},
+		Pods:         {Name: "pods", Namespaced: true},
+	}}
+	for r, e := range map[Resource]bool{
+		Pods:         true,
+		ConfigMaps:   true,
+		Nodes:        false,
+		Namespaces:   false,
+		clusterRoles: false,
+	} {
+		if a := m.namespaced(r); e != a {
+			t.Errorf("expected %v of %s, got %v", e, r, a)
+		}
+	}
+}
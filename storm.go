@@ -0,0 +1,170 @@
+package robot
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// AlertRestartStorm is the reason of alerts of restart storms, see RestartStorms.
+const AlertRestartStorm = "RestartStorm"
+
+// Alert is the context of an EventAlert, aggregated from objects.
+type Alert struct {
+	Reason string
+
+	Namespace string
+
+	// Owner is the controller owning the pods as "Kind/name",
+	// empty if pods are grouped by namespace.
+	Owner string
+
+	// Restarts is the number of container restarts within Window.
+	Restarts int
+	Window   time.Duration
+
+	// Pods are the names of pods which restarted within Window.
+	Pods []string
+}
+
+// RestartStormOptions are options of RestartStorms.
+type RestartStormOptions struct {
+	// Threshold is how many container restarts within Window are a storm, 5 if zero.
+	Threshold int
+
+	// Window is how long restarts are counted, 10 minutes if zero.
+	Window time.Duration
+
+	// ByOwner counts restarts of the pods of each controller, e.g. a ReplicaSet,
+	// instead of the pods of each namespace. Pods without one count alone.
+	ByOwner bool
+}
+
+// RestartStorms returns a HandlerFunc of Pods, which counts restarts of
+// containers of pods by their updates, and sends an EventAlert keyed by the
+// namespace, or namespace/Kind/name of the owner, once they are over the
+// threshold within the window. Restarts alerted are not counted again.
+//
+//	Options{Handlers: map[Resource]HandlerFunc{Pods: RestartStorms(RestartStormOptions{})}}
+func RestartStorms(opts RestartStormOptions) HandlerFunc {
+	return func(cluster string, r RN, def cache.ResourceEventHandler, send func(QueueObject, interface{})) cache.ResourceEventHandler {
+		detector := newStormDetector(opts)
+		return cache.ResourceEventHandlerFuncs{
+			AddFunc: def.OnAdd,
+			UpdateFunc: func(old, new interface{}) {
+				def.OnUpdate(old, new)
+
+				oldPod, ok1 := podOf(old)
+				curPod, ok2 := podOf(new)
+				if !ok1 || !ok2 {
+					return
+				}
+				if key, alert := detector.observe(oldPod, curPod, time.Now()); alert != nil {
+					send(QueueObject{Event: EventAlert, RType: r.RType, Key: key, CreateAt: time.Now(), Alert: alert}, alert)
+				}
+			},
+			DeleteFunc: def.OnDelete,
+		}
+	}
+}
+
+type restart struct {
+	at  time.Time
+	pod string
+	n   int
+}
+
+// stormDetector counts restarts of pods in groups within a sliding window.
+type stormDetector struct {
+	opts RestartStormOptions
+
+	mu       sync.Mutex
+	restarts map[string][]restart
+}
+
+func newStormDetector(opts RestartStormOptions) *stormDetector {
+	if opts.Threshold <= 0 {
+		opts.Threshold = 5
+	}
+	if opts.Window <= 0 {
+		opts.Window = 10 * time.Minute
+	}
+	return &stormDetector{opts: opts, restarts: make(map[string][]restart)}
+}
+
+// observe counts the restarts of the update of the pod at now,
+// it returns the key of the group and an alert if they are a storm.
+func (d *stormDetector) observe(old, new *v1.Pod, now time.Time) (string, *Alert) {
+	n := restartCount(new) - restartCount(old)
+	if n <= 0 {
+		return "", nil
+	}
+
+	key, owner := new.Namespace, ""
+	if d.opts.ByOwner {
+		if ref := metav1.GetControllerOf(new); ref != nil {
+			owner = ref.Kind + "/" + ref.Name
+		} else {
+			owner = "Pod/" + new.Name
+		}
+		key += "/" + owner
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Restarts out of the window of all groups are dropped, so groups gone
+	// quiet don't pile up.
+	for k, restarts := range d.restarts {
+		i := 0
+		for i < len(restarts) && now.Sub(restarts[i].at) > d.opts.Window {
+			i++
+		}
+		if i == len(restarts) {
+			delete(d.restarts, k)
+		} else if i > 0 {
+			d.restarts[k] = restarts[i:]
+		}
+	}
+
+	restarts := append(d.restarts[key], restart{now, new.Name, n})
+	total := 0
+	pods := make(map[string]bool)
+	for _, one := range restarts {
+		total += one.n
+		pods[one.pod] = true
+	}
+	if total < d.opts.Threshold {
+		d.restarts[key] = restarts
+		return "", nil
+	}
+	delete(d.restarts, key)
+
+	alert := &Alert{
+		Reason:    AlertRestartStorm,
+		Namespace: new.Namespace,
+		Owner:     owner,
+		Restarts:  total,
+		Window:    d.opts.Window,
+	}
+	for pod := range pods {
+		alert.Pods = append(alert.Pods, pod)
+	}
+	sort.Strings(alert.Pods)
+	return key, alert
+}
+
+// restartCount returns the sum of restarts of the containers of the pod.
+func restartCount(pod *v1.Pod) int {
+	n := 0
+	for _, statuses := range [][]v1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			n += int(status.RestartCount)
+		}
+	}
+	return n
+}
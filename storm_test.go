@@ -0,0 +1,91 @@
+package robot
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// restartedPod returns a pod of the ReplicaSet whose container restarted n times.
+func restartedPod(name, rs string, n int32) *v1.Pod {
+	controller := true
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "default",
+			Name:            name,
+			OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: rs, Controller: &controller}},
+		},
+		Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{{Name: "app", RestartCount: n}}},
+	}
+}
+
+func TestRestartStorms(t *testing.T) {
+	var sent []QueueObject
+	m := &member{
+		Cluster:  Cluster{Name: "east"},
+		handlers: map[Resource]HandlerFunc{Pods: RestartStorms(RestartStormOptions{Threshold: 3})},
+	}
+	h := m.handler(RN{RType: Pods}, func(item QueueObject, obj interface{}) {
+		sent = append(sent, item)
+	}, func(err error) {
+		t.Errorf("unexpected error: %v", err)
+	})
+
+	h.OnUpdate(restartedPod("a", "web-1", 0), restartedPod("a", "web-1", 1))
+	h.OnUpdate(restartedPod("b", "web-1", 4), restartedPod("b", "web-1", 4))
+	h.OnUpdate(restartedPod("b", "web-1", 4), restartedPod("b", "web-1", 6))
+
+	if e, a := 4, len(sent); e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	item := sent[3]
+	if e, a := EventAlert, item.Event; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := "default", item.Key; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	e := &Alert{Reason: AlertRestartStorm, Namespace: "default", Restarts: 3, Window: 10 * time.Minute, Pods: []string{"a", "b"}}
+	if a := item.Alert; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %+v, got %+v", e, a)
+	}
+
+	// Restarts alerted are not counted again.
+	h.OnUpdate(restartedPod("b", "web-1", 6), restartedPod("b", "web-1", 7))
+	if e, a := 5, len(sent); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestStormDetector(t *testing.T) {
+	d := newStormDetector(RestartStormOptions{Threshold: 2, Window: time.Minute, ByOwner: true})
+	now := time.Now()
+
+	if _, alert := d.observe(restartedPod("a", "web-1", 0), restartedPod("a", "web-1", 1), now); alert != nil {
+		t.Errorf("unexpected alert %+v", alert)
+	}
+	// Another owner counts alone.
+	if _, alert := d.observe(restartedPod("c", "api-1", 0), restartedPod("c", "api-1", 1), now); alert != nil {
+		t.Errorf("unexpected alert %+v", alert)
+	}
+	// The first restart is out of the window.
+	if _, alert := d.observe(restartedPod("a", "web-1", 1), restartedPod("a", "web-1", 2), now.Add(2*time.Minute)); alert != nil {
+		t.Errorf("unexpected alert %+v", alert)
+	}
+	key, alert := d.observe(restartedPod("b", "web-1", 0), restartedPod("b", "web-1", 1), now.Add(2*time.Minute))
+	if e, a := "default/ReplicaSet/web-1", key; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if alert == nil {
+		t.Fatal("expected an alert")
+	}
+	if e, a := "ReplicaSet/web-1", alert.Owner; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := 2, alert.Restarts; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
@@ -0,0 +1,142 @@
+package robot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// initialEventsEnd annotates the bookmark ending initial events of a watch stream.
+	initialEventsEnd = "k8s.io/initial-events-end"
+
+	// streamingListTimeout bounds receiving the initial events.
+	streamingListTimeout = 5 * time.Minute
+)
+
+// supportsStreamingList reports whether the api server may serve watch streams
+// with initial events, which was introduced in 1.27.
+func supportsStreamingList(v *version.Info) bool {
+	minor, ok := minorVersion(v)
+	return ok && v.Major == "1" && minor >= 27
+}
+
+// streamingListWatch lists by a watch stream with initial events,
+// and falls back to list if the api server doesn't support it.
+type streamingListWatch struct {
+	cache.ListerWatcher
+
+	client rest.Interface
+	rn     RN
+
+	// unsupported is set once the api server rejected a watch stream with
+	// initial events, accessed atomically.
+	unsupported int32
+}
+
+func newStreamingListWatch(client rest.Interface, rn RN, fallback cache.ListerWatcher) *streamingListWatch {
+	return &streamingListWatch{ListerWatcher: fallback, client: client, rn: rn}
+}
+
+func (s *streamingListWatch) List(options metav1.ListOptions) (runtime.Object, error) {
+	if atomic.LoadInt32(&s.unsupported) == 0 {
+		list, err := s.stream(options)
+		if err == nil {
+			return list, nil
+		}
+		// Other errors, e.g. of the network, fall back to list this time only.
+		if rejected(err) {
+			atomic.StoreInt32(&s.unsupported, 1)
+		}
+	}
+	return s.ListerWatcher.List(options)
+}
+
+// rejected reports whether err is the api server rejecting the parameters of
+// a watch stream with initial events, as it doesn't support them.
+func rejected(err error) bool {
+	return apierrors.IsBadRequest(err) || apierrors.IsInvalid(err) ||
+		apierrors.IsMethodNotSupported(err) || apierrors.IsNotAcceptable(err)
+}
+
+// streamEvent is an event of a watch stream.
+type streamEvent struct {
+	Type   watch.EventType `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// stream receives the initial events of a watch, and returns them as a list.
+func (s *streamingListWatch) stream(options metav1.ListOptions) (runtime.Object, error) {
+	body, err := s.client.Get().
+		Namespace(s.rn.Namespace).
+		Resource(s.rn.RType.Resource).
+		Param("watch", "true").
+		Param("sendInitialEvents", "true").
+		Param("allowWatchBookmarks", "true").
+		Param("resourceVersionMatch", "NotOlderThan").
+		Param("labelSelector", options.LabelSelector).
+		Param("fieldSelector", options.FieldSelector).
+		Timeout(streamingListTimeout).
+		Stream()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	decoder := json.NewDecoder(body)
+	decode := scheme.Codecs.UniversalDeserializer()
+
+	var items []runtime.Object
+	for {
+		var event streamEvent
+		if err := decoder.Decode(&event); err != nil {
+			if err == io.EOF {
+				return nil, fmt.Errorf("robot: watch stream of %s ended before initial events", s.rn.RType)
+			}
+			return nil, err
+		}
+
+		obj, _, err := decode.Decode(event.Object, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		switch event.Type {
+		case watch.Added:
+			items = append(items, obj)
+		case "BOOKMARK":
+			m, err := meta.Accessor(obj)
+			if err != nil {
+				return nil, err
+			}
+			if m.GetAnnotations()[initialEventsEnd] != "true" {
+				continue
+			}
+
+			list := s.rn.RType.list()
+			if err := meta.SetList(list, items); err != nil {
+				return nil, err
+			}
+			listMeta, err := meta.ListAccessor(list)
+			if err != nil {
+				return nil, err
+			}
+			listMeta.SetResourceVersion(m.GetResourceVersion())
+			return list, nil
+		default:
+			return nil, fmt.Errorf("robot: unexpected %s event in initial events of %s", event.Type, s.rn.RType)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package robot
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+const streamResponse = `{"type":"ADDED","object":{"kind":"Pod","apiVersion":"v1","metadata":{"name":"one","namespace":"default","resourceVersion":"5"}}}
+{"type":"ADDED","object":{"kind":"Pod","apiVersion":"v1","metadata":{"name":"two","namespace":"default","resourceVersion":"6"}}}
+{"type":"BOOKMARK","object":{"kind":"Pod","apiVersion":"v1","metadata":{"resourceVersion":"9","annotations":{"k8s.io/initial-events-end":"true"}}}}
+`
+
+func TestStreamingList(t *testing.T) {
+	supported, failing := true, false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !supported || r.URL.Query().Get("sendInitialEvents") != "true" {
+			http.Error(w, "unsupported", http.StatusBadRequest)
+			return
+		}
+		if failing {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, streamResponse)
+	}))
+	defer server.Close()
+
+	client := kubernetes.NewForConfigOrDie(&rest.Config{Host: server.URL})
+
+	fallbacks := 0
+	fallback := &cache.ListWatch{
+		ListFunc: func(metav1.ListOptions) (runtime.Object, error) {
+			fallbacks++
+			return &v1.PodList{}, nil
+		},
+		WatchFunc: func(metav1.ListOptions) (watch.Interface, error) {
+			return watch.NewEmptyWatch(), nil
+		},
+	}
+	lw := newStreamingListWatch(client.CoreV1().RESTClient(), RN{RType: Pods}, fallback)
+
+	obj, err := lw.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	list := obj.(*v1.PodList)
+	if e, a := 2, len(list.Items); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := "9", list.ResourceVersion; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	// Transient errors fall back to list once.
+	failing = true
+	if _, err := lw.List(metav1.ListOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	failing = false
+	if _, err := lw.List(metav1.ListOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := 1, fallbacks; e != a {
+		t.Errorf("expected %v fallbacks, got %v", e, a)
+	}
+
+	supported = false
+	if _, err := lw.List(metav1.ListOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	supported = true
+	if _, err := lw.List(metav1.ListOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := 3, fallbacks; e != a {
+		t.Errorf("expected %v fallbacks, got %v", e, a)
+	}
+}
@@ -1,34 +1,173 @@
 package robot
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
 
-type Resource int
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
 
-const (
-	All Resource = iota
+// Resource is a kind of objects watched by the robot, identified by its group,
+// version and plural name. The resources below are built in, other resources
+// are watched as unstructured objects.
+type Resource struct {
+	Group    string
+	Version  string
+	Resource string
+}
+
+var (
+	// All matches every resource.
+	All = Resource{}
+
+	Services = Resource{Version: "v1", Resource: "services"}
+
+	Endpoints = Resource{Version: "v1", Resource: "endpoints"}
+
+	Pods = Resource{Version: "v1", Resource: "pods"}
+
+	ConfigMaps = Resource{Version: "v1", Resource: "configmaps"}
 
-	Services
+	Secrets = Resource{Version: "v1", Resource: "secrets"}
 
-	Endpoints
+	// Nodes, PersistentVolumes and Namespaces are cluster scoped,
+	// their keys are names without namespaces.
+	Nodes = Resource{Version: "v1", Resource: "nodes"}
 
-	Pods
+	PersistentVolumes = Resource{Version: "v1", Resource: "persistentvolumes"}
 
-	ConfigMaps
+	Namespaces = Resource{Version: "v1", Resource: "namespaces"}
 )
 
+// ParseResource parses a resource from "version/resource" of the core group,
+// e.g. "v1/pods", or "group/version/resource", e.g. "apps/v1/deployments".
+func ParseResource(s string) (Resource, error) {
+	var r Resource
+	parts := strings.Split(s, "/")
+	switch len(parts) {
+	case 2:
+		r = Resource{Version: parts[0], Resource: parts[1]}
+	case 3:
+		r = Resource{Group: parts[0], Version: parts[1], Resource: parts[2]}
+	default:
+		return r, fmt.Errorf("robot: invalid resource %q, expected [group/]version/resource", s)
+	}
+	if err := r.Validate(); err != nil {
+		return Resource{}, err
+	}
+	return r, nil
+}
+
+// Validate returns an error if the resource can't be watched.
+func (t Resource) Validate() error {
+	var errs []string
+	if t.Group != "" {
+		errs = append(errs, validation.IsDNS1123Subdomain(t.Group)...)
+	}
+	errs = append(errs, validation.IsDNS1035Label(t.Version)...)
+	errs = append(errs, validation.IsDNS1123Label(t.Resource)...)
+	if len(errs) > 0 {
+		return fmt.Errorf("robot: invalid resource %s/%s/%s: %s", t.Group, t.Version, t.Resource, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// String returns the plural name of the resource,
+// suffixed by its group if it's not in the core group.
 func (t Resource) String() string {
-	out := "unknown"
+	switch {
+	case t == All:
+		return "all"
+	case t.Group == "":
+		return t.Resource
+	}
+	return t.Resource + "." + t.Group
+}
+
+// GroupVersionResource returns the resource as a schema.GroupVersionResource.
+func (t Resource) GroupVersionResource() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: t.Group, Version: t.Version, Resource: t.Resource}
+}
+
+// groupVersion returns the api group version of the resource, as in discovery.
+func (t Resource) groupVersion() string {
+	return t.GroupVersionResource().GroupVersion().String()
+}
+
+// typed reports whether the resource is built in,
+// they are watched as typed objects by the core client.
+func (t Resource) typed() bool {
 	switch t {
+	case Services, Endpoints, Pods, ConfigMaps, Secrets, Nodes, PersistentVolumes, Namespaces:
+		return true
+	}
+	return false
+}
+
+// clusterScoped reports whether the resource is built in and cluster scoped,
+// the scope of other resources is discovered.
+func (t Resource) clusterScoped() bool {
+	switch t {
+	case Nodes, PersistentVolumes, Namespaces:
+		return true
+	}
+	return false
+}
+
+// object returns an empty object of the resource, nil for All.
+func (t Resource) object() runtime.Object {
+	switch t {
+	case All:
+		return nil
 	case Services:
-		out = "services"
+		return &v1.Service{}
 	case Endpoints:
-		out = "endpoints"
+		return &v1.Endpoints{}
 	case Pods:
-		out = "pods"
+		return &v1.Pod{}
 	case ConfigMaps:
-		out = "configmaps"
+		return &v1.ConfigMap{}
+	case Secrets:
+		return &v1.Secret{}
+	case Nodes:
+		return &v1.Node{}
+	case PersistentVolumes:
+		return &v1.PersistentVolume{}
+	case Namespaces:
+		return &v1.Namespace{}
 	}
-	return out
+	return &unstructured.Unstructured{}
+}
+
+// list returns an empty list of the resource, nil for All.
+func (t Resource) list() runtime.Object {
+	switch t {
+	case All:
+		return nil
+	case Services:
+		return &v1.ServiceList{}
+	case Endpoints:
+		return &v1.EndpointsList{}
+	case Pods:
+		return &v1.PodList{}
+	case ConfigMaps:
+		return &v1.ConfigMapList{}
+	case Secrets:
+		return &v1.SecretList{}
+	case Nodes:
+		return &v1.NodeList{}
+	case PersistentVolumes:
+		return &v1.PersistentVolumeList{}
+	case Namespaces:
+		return &v1.NamespaceList{}
+	}
+	return &unstructured.UnstructuredList{}
 }
 
 // Event represents a registry update event
@@ -45,6 +184,26 @@ const (
 	// EventDelete is sent when an object is deleted
 	// Captures the object at the last known state
 	EventDelete
+
+	// EventOrphan is sent when an object is found orphaned,
+	// e.g. Endpoints without a Service or Pods whose owner is gone
+	EventOrphan
+
+	// EventAlert is sent when an analyzer detects something wrong across
+	// objects, e.g. a restart storm of pods, see QueueObject.Alert
+	EventAlert
+
+	// EventReachability is sent when a Service is probed first, and when the
+	// reachability of its endpoints changes, see QueueObject.Reachability
+	EventReachability
+
+	// EventInvalid is sent when an object violates the schema of its resource,
+	// once until its violations change, see QueueObject.Validation
+	EventInvalid
+
+	// EventPolicy is sent when an object violates policies, once until its
+	// violations change, see QueueObject.Policy
+	EventPolicy
 )
 
 func (e event) String() string {
@@ -56,13 +215,62 @@ func (e event) String() string {
 		out = "update"
 	case EventDelete:
 		out = "delete"
+	case EventOrphan:
+		out = "orphan"
+	case EventAlert:
+		out = "alert"
+	case EventReachability:
+		out = "reachability"
+	case EventInvalid:
+		out = "invalid"
+	case EventPolicy:
+		out = "policy"
 	}
 	return out
 }
 
 type QueueObject struct {
-	Event    	event
-	RType 		Resource
-	Key      	string
-	CreateAt    time.Time
+	Event    event
+	RType    Resource
+	Key      string
+	CreateAt time.Time
+
+	// Cluster is where the event comes from.
+	Cluster string
+
+	// Labels are the labels of the cluster in the form of "env=prod,region=eu",
+	// see ClusterLabels.
+	Labels string
+
+	// ClockSkew is how far the clock of the api server of the cluster is
+	// estimated ahead of the local clock when the event is received at CreateAt,
+	// so CreateAt.Add(ClockSkew) is the time of the event by the api server,
+	// e.g. to order events across clusters. It's estimated by the Date headers
+	// of responses of the api server, in about a second.
+	ClockSkew time.Duration
+
+	// Alert is the context of an EventAlert, nil for other events.
+	Alert *Alert
+
+	// Reachability is the context of an EventReachability, nil for other events.
+	Reachability *Reachability
+
+	// Validation is the context of an EventInvalid, nil for other events.
+	Validation *Validation
+
+	// Policy is the context of an EventPolicy, nil for other events.
+	Policy *PolicyResult
+
+	// Replicas are the clusters holding the object, for events of resources
+	// of Options.Replicas, nil for other events.
+	Replicas *Replicas
+}
+
+// ClusterLabels returns the labels of the cluster where the event comes from.
+func (o QueueObject) ClusterLabels() labels.Set {
+	set, err := labels.ConvertSelectorToLabelsMap(o.Labels)
+	if err != nil {
+		return nil
+	}
+	return set
 }
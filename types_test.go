@@ -0,0 +1,40 @@
+package robot
+
+import (
+	"testing"
+)
+
+func TestParseResource(t *testing.T) {
+	for _, test := range []struct {
+		in     string
+		out    Resource
+		name   string
+		failed bool
+	}{
+		{in: "v1/pods", out: Pods, name: "pods"},
+		{in: "apps/v1/deployments", out: Resource{Group: "apps", Version: "v1", Resource: "deployments"}, name: "deployments.apps"},
+		{in: "networking.istio.io/v1alpha3/virtualservices", out: Resource{Group: "networking.istio.io", Version: "v1alpha3", Resource: "virtualservices"}, name: "virtualservices.networking.istio.io"},
+		{in: "pods", failed: true},
+		{in: "v1/", failed: true},
+		{in: "apps/v1/Deployments", failed: true},
+		{in: "a/b/c/d", failed: true},
+	} {
+		r, err := ParseResource(test.in)
+		if test.failed {
+			if err == nil {
+				t.Errorf("expected error of %s, got %v", test.in, r)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error of %s: %v", test.in, err)
+			continue
+		}
+		if e, a := test.out, r; e != a {
+			t.Errorf("expected %v, got %v", e, a)
+		}
+		if e, a := test.name, r.String(); e != a {
+			t.Errorf("expected %v, got %v", e, a)
+		}
+	}
+}
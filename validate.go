@@ -0,0 +1,301 @@
+package robot
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+// Violation is a field of an object violating its schema.
+type Violation struct {
+	// Field is the path of the field, e.g. "spec.containers[0].image".
+	Field   string
+	Message string
+}
+
+func (v Violation) String() string {
+	if v.Field == "" {
+		return v.Message
+	}
+	return v.Field + ": " + v.Message
+}
+
+// Validation is the context of an EventInvalid.
+type Validation struct {
+	Violations []Violation
+}
+
+// jsonSchema is the subset of JSON Schema, and the OpenAPI v3 schemas of CRDs,
+// which objects are validated by: type, properties, required,
+// additionalProperties, items, enum, pattern, minimum, maximum, minLength,
+// maxLength, minItems, maxItems and nullable. Other keywords are ignored.
+type jsonSchema struct {
+	Type       interface{}            `json:"type"`
+	Properties map[string]*jsonSchema `json:"properties"`
+	Required   []string               `json:"required"`
+	Items      *jsonSchema            `json:"items"`
+	Enum       []interface{}          `json:"enum"`
+	Pattern    string                 `json:"pattern"`
+	Minimum    *float64               `json:"minimum"`
+	Maximum    *float64               `json:"maximum"`
+	MinLength  *int                   `json:"minLength"`
+	MaxLength  *int                   `json:"maxLength"`
+	MinItems   *int                   `json:"minItems"`
+	MaxItems   *int                   `json:"maxItems"`
+	Nullable   bool                   `json:"nullable"`
+
+	// AdditionalProperties is false, or a schema.
+	AdditionalProperties json.RawMessage `json:"additionalProperties"`
+
+	pattern    *regexp.Regexp
+	noMore     bool
+	additional *jsonSchema
+}
+
+// parseSchema parses a JSON Schema.
+func parseSchema(data []byte) (*jsonSchema, error) {
+	s := &jsonSchema{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if err := s.compile(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// compile compiles patterns and additional properties of s and its subschemas.
+func (s *jsonSchema) compile() error {
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return err
+		}
+		s.pattern = pattern
+	}
+	switch raw := strings.TrimSpace(string(s.AdditionalProperties)); raw {
+	case "", "true":
+	case "false":
+		s.noMore = true
+	default:
+		s.additional = &jsonSchema{}
+		if err := json.Unmarshal(s.AdditionalProperties, s.additional); err != nil {
+			return err
+		}
+	}
+
+	var subschemas []*jsonSchema
+	for _, one := range s.Properties {
+		subschemas = append(subschemas, one)
+	}
+	subschemas = append(subschemas, s.Items, s.additional)
+	for _, one := range subschemas {
+		if one == nil {
+			continue
+		}
+		if err := one.compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate appends the violations of the decoded JSON value v at the field.
+func (s *jsonSchema) validate(field string, v interface{}, violations []Violation) []Violation {
+	violate := func(format string, args ...interface{}) {
+		violations = append(violations, Violation{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if v == nil {
+		if s.Type != nil && !s.Nullable && !s.allows("null") {
+			violate("must not be null")
+		}
+		return violations
+	}
+	if s.Type != nil && !s.allows(jsonType(v)) {
+		violate("must be of type %v", s.Type)
+		return violations
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, one := range s.Enum {
+			if reflect.DeepEqual(one, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			violate("must be one of %v", s.Enum)
+		}
+	}
+
+	switch v := v.(type) {
+	case string:
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			violate("must match %s", s.Pattern)
+		}
+		if s.MinLength != nil && len(v) < *s.MinLength {
+			violate("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && len(v) > *s.MaxLength {
+			violate("must be at most %d characters", *s.MaxLength)
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			violate("must be >= %v", *s.Minimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			violate("must be <= %v", *s.Maximum)
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			violate("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			violate("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				violations = s.Items.validate(fmt.Sprintf("%s[%d]", field, i), item, violations)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				violations = append(violations, Violation{Field: fieldPath(field, name), Message: "is required"})
+			}
+		}
+		for _, name := range sortedKeys(v) {
+			if property, ok := s.Properties[name]; ok {
+				violations = property.validate(fieldPath(field, name), v[name], violations)
+			} else if s.noMore {
+				violations = append(violations, Violation{Field: fieldPath(field, name), Message: "is not allowed"})
+			} else if s.additional != nil {
+				violations = s.additional.validate(fieldPath(field, name), v[name], violations)
+			}
+		}
+	}
+	return violations
+}
+
+// allows reports whether the type of s allows the JSON type t,
+// integers are numbers.
+func (s *jsonSchema) allows(t string) bool {
+	var types []string
+	switch one := s.Type.(type) {
+	case string:
+		types = []string{one}
+	case []interface{}:
+		for _, v := range one {
+			if t, ok := v.(string); ok {
+				types = append(types, t)
+			}
+		}
+	}
+	for _, one := range types {
+		if one == t || (one == "number" && t == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType returns the JSON type of the decoded value.
+func jsonType(v interface{}) string {
+	switch v := v.(type) {
+	case bool:
+		return "boolean"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "null"
+}
+
+// fieldPath returns the path of the property of the field.
+func fieldPath(field, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
+
+// validator validates objects of a resource by its schema, and remembers the
+// violations of objects invalid, so that an object is reported once until its
+// violations change.
+type validator struct {
+	schema *jsonSchema
+
+	mu      sync.Mutex
+	invalid map[string]string
+}
+
+// check returns the violations of the event of obj, if they changed.
+func (v *validator) check(item QueueObject, obj interface{}) ([]Violation, bool) {
+	if v == nil || (item.Event != EventAdd && item.Event != EventUpdate && item.Event != EventDelete) {
+		return nil, false
+	}
+	key := item.Cluster + "|" + item.Key
+
+	var violations []Violation
+	if item.Event != EventDelete {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return nil, false
+		}
+		var doc interface{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, false
+		}
+		violations = v.schema.validate("", doc, nil)
+	}
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].Field < violations[j].Field })
+
+	var s []string
+	for _, one := range violations {
+		s = append(s, one.String())
+	}
+	summary := strings.Join(s, "\n")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if summary == v.invalid[key] {
+		return nil, false
+	}
+	if len(violations) == 0 {
+		delete(v.invalid, key)
+		return nil, false
+	}
+	v.invalid[key] = summary
+	return violations, true
+}
+
+// newValidators parses the schemas of resources.
+func newValidators(schemas map[Resource][]byte) (map[Resource]*validator, error) {
+	validators := make(map[Resource]*validator, len(schemas))
+	for r, data := range schemas {
+		schema, err := parseSchema(data)
+		if err != nil {
+			return nil, fmt.Errorf("robot: invalid schema of %s: %v", r, err)
+		}
+		validators[r] = &validator{schema: schema, invalid: make(map[string]string)}
+	}
+	return validators, nil
+}
@@ -0,0 +1,108 @@
+package robot
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const podSchema = `{
+	"type": "object",
+	"required": ["metadata", "spec"],
+	"properties": {
+		"metadata": {
+			"type": "object",
+			"properties": {
+				"labels": {"type": "object", "required": ["team"], "additionalProperties": {"type": "string", "maxLength": 8}}
+			}
+		},
+		"spec": {
+			"type": "object",
+			"properties": {
+				"containers": {
+					"type": "array",
+					"minItems": 1,
+					"items": {
+						"type": "object",
+						"properties": {"image": {"type": "string", "pattern": "^registry\\.example\\.com/"}}
+					}
+				},
+				"restartPolicy": {"enum": ["Always", "OnFailure"]},
+				"priority": {"type": "integer", "minimum": 0}
+			}
+		}
+	}
+}`
+
+func TestSchemaValidate(t *testing.T) {
+	schema, err := parseSchema([]byte(podSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "a-long-name"}},
+		"spec": map[string]interface{}{
+			"containers":    []interface{}{map[string]interface{}{"image": "nginx"}},
+			"restartPolicy": "Never",
+			"priority":      1.5,
+		},
+	}
+	e := []Violation{
+		{Field: "metadata.labels.team", Message: "is required"},
+		{Field: "metadata.labels.app", Message: "must be at most 8 characters"},
+		{Field: "spec.containers[0].image", Message: `must match ^registry\.example\.com/`},
+		{Field: "spec.priority", Message: "must be of type integer"},
+		{Field: "spec.restartPolicy", Message: "must be one of [Always OnFailure]"},
+	}
+	if a := schema.validate("", doc, nil); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if _, err := parseSchema([]byte(`{"pattern": "("}`)); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestInvalidEvents(t *testing.T) {
+	robot, err := NewRobotWithOptions(Options{Schemas: map[Resource][]byte{Pods: []byte(podSchema)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := robot.(*controller)
+	var sent []QueueObject
+	c.queue = &recordQueue{sent: &sent}
+	m := &member{Cluster: Cluster{Name: "east"}}
+	emit := c.emit(m, RN{RType: Pods})
+
+	pod := func(team, image string) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Labels: map[string]string{"team": team}},
+			Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "app", Image: image}}},
+		}
+	}
+	emit(QueueObject{Event: EventAdd, RType: Pods, Key: "default/web"}, pod("a", "nginx"))
+	emit(QueueObject{Event: EventUpdate, RType: Pods, Key: "default/web"}, pod("b", "nginx"))
+	emit(QueueObject{Event: EventUpdate, RType: Pods, Key: "default/web"}, pod("b", "registry.example.com/nginx"))
+	emit(QueueObject{Event: EventUpdate, RType: Pods, Key: "default/web"}, pod("b", "nginx"))
+
+	var events []event
+	for _, item := range sent {
+		events = append(events, item.Event)
+	}
+	e := []event{EventAdd, EventInvalid, EventUpdate, EventUpdate, EventUpdate, EventInvalid}
+	if a := events; !reflect.DeepEqual(e, a) {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	if e, a := []Violation{{Field: "spec.containers[0].image", Message: `must match ^registry\.example\.com/`}}, sent[1].Validation.Violations; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := "east", sent[1].Cluster; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if _, err := NewRobotWithOptions(Options{Schemas: map[Resource][]byte{Pods: []byte("{")}}); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,239 @@
+package robot
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ViewFilter restricts a view to objects of clusters, namespaces and labels,
+// see Robot.View. Empty fields restrict nothing.
+type ViewFilter struct {
+	// Clusters are the names of the clusters of objects.
+	Clusters []string
+
+	// Namespaces are the namespaces of objects, cluster scoped objects are
+	// excluded if it's set.
+	Namespaces []string
+
+	// Selector selects objects by labels, e.g. "app=web".
+	Selector string
+}
+
+// View is a read-only slice of the queue and store of a robot, see Robot.View.
+type View interface {
+	// Pop returns an object of the queue of the view, or an error once it's closed.
+	Pop() (QueueObject, error)
+
+	// ReQueue requeues an object failed, see Robot.
+	ReQueue(QueueObject) error
+
+	// Finish indicates that an object has been successfully processed.
+	Finish(QueueObject)
+
+	// Close stops sending events to the view and closes its queue,
+	// it's safe to call Close multiple times.
+	Close()
+
+	store
+}
+
+// view is a View of a controller, whose queue receives the events delivered
+// to the controller which match the filter.
+type view struct {
+	c *controller
+
+	clusters   map[string]bool
+	namespaces map[string]bool
+	selector   labels.Selector
+
+	*wq
+	closeOnce sync.Once
+}
+
+var _ View = &view{}
+
+// View returns a view of the objects of the filter, so that components of an
+// application consume their slices of the same informers. Events are sent to
+// the queue of the view besides the queue of the robot, from the call on.
+func (c *controller) View(filter ViewFilter) (View, error) {
+	selector, err := labels.Parse(filter.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("robot: invalid view selector: %v", err)
+	}
+	v := &view{c: c, selector: selector, wq: newWorkQueue()}
+
+	if len(filter.Clusters) > 0 {
+		v.clusters = make(map[string]bool, len(filter.Clusters))
+		c.mu.Lock()
+		for _, name := range filter.Clusters {
+			m := c.memberOf(name)
+			if m == nil {
+				c.mu.Unlock()
+				return nil, &ErrClusterNotFound{name}
+			}
+			// Events are of the name of the cluster, which may be given by its URL.
+			v.clusters[m.String()] = true
+		}
+		c.mu.Unlock()
+	}
+	if len(filter.Namespaces) > 0 {
+		v.namespaces = make(map[string]bool, len(filter.Namespaces))
+		for _, ns := range filter.Namespaces {
+			v.namespaces[ns] = true
+		}
+	}
+
+	c.views.add(v)
+	return v, nil
+}
+
+// matches reports whether obj of the cluster is in the view.
+func (v *view) matches(cluster string, obj interface{}) bool {
+	if v.clusters != nil && !v.clusters[cluster] {
+		return false
+	}
+	if v.namespaces == nil && v.selector.Empty() {
+		return true
+	}
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+	if v.namespaces != nil && !v.namespaces[accessor.GetNamespace()] {
+		return false
+	}
+	return v.selector.Matches(labels.Set(accessor.GetLabels()))
+}
+
+func (v *view) Close() {
+	v.closeOnce.Do(func() {
+		v.c.views.remove(v)
+		v.close()
+	})
+}
+
+// each calls fn with the cached objects of the resource in the view, and
+// their keys.
+func (v *view) each(r Resource, fn func(key string, obj interface{})) {
+	v.c.mu.Lock()
+	defer v.c.mu.Unlock()
+
+	for _, m := range v.c.clusters {
+		if v.clusters != nil && !v.clusters[m.String()] {
+			continue
+		}
+		for i, rn := range m.Resources {
+			if r != All && rn.RType != r {
+				continue
+			}
+			for _, key := range m.indexers[i].ListKeys() {
+				obj, ok, err := m.indexers[i].GetByKey(key)
+				if err == nil && ok && v.matches(m.String(), obj) {
+					fn(key, obj)
+				}
+			}
+		}
+	}
+}
+
+func (v *view) List(r Resource) (l []interface{}) {
+	v.each(r, func(key string, obj interface{}) {
+		l = append(l, obj)
+	})
+	return
+}
+
+func (v *view) ListKeys(r Resource) (keys []string) {
+	v.each(r, func(key string, obj interface{}) {
+		keys = append(keys, key)
+	})
+	return
+}
+
+func (v *view) GetByKey(r Resource, key string) (items []interface{}, exists bool) {
+	v.c.mu.Lock()
+	defer v.c.mu.Unlock()
+
+	for _, m := range v.c.clusters {
+		if v.clusters != nil && !v.clusters[m.String()] {
+			continue
+		}
+		var indexers []cache.Indexer
+		for i, rn := range m.Resources {
+			if r == All || rn.RType == r {
+				indexers = append(indexers, m.indexers[i])
+			}
+		}
+		found, ok := mapIndexerSet{r: indexers}.GetByKey(r, key)
+		if !ok {
+			continue
+		}
+		for _, obj := range found {
+			if v.matches(m.String(), obj) {
+				items = append(items, obj)
+				exists = true
+			}
+		}
+	}
+	return
+}
+
+// viewSet are the views of a controller, a nil one has none.
+type viewSet struct {
+	mu    sync.Mutex
+	views map[*view]bool
+}
+
+func newViewSet() *viewSet {
+	return &viewSet{views: make(map[*view]bool)}
+}
+
+func (s *viewSet) add(v *view) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.views[v] = true
+}
+
+func (s *viewSet) remove(v *view) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.views, v)
+}
+
+// push sends the event of obj to the views it matches.
+func (s *viewSet) push(item QueueObject, obj interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for v := range s.views {
+		if v.matches(item.Cluster, obj) {
+			v.push(item)
+		}
+	}
+}
+
+// close closes the queues of all views once the robot stops.
+func (s *viewSet) close() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	views := make([]*view, 0, len(s.views))
+	for v := range s.views {
+		views = append(views, v)
+	}
+	s.mu.Unlock()
+
+	for _, v := range views {
+		v.Close()
+	}
+}
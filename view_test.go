@@ -0,0 +1,73 @@
+package robot
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newViewPod(ns, name string, labels map[string]string) *v1.Pod {
+	return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name, Labels: labels}}
+}
+
+func TestView(t *testing.T) {
+	var sent []QueueObject
+	c := &controller{queue: &recordQueue{sent: &sent}, views: newViewSet()}
+	for _, name := range []string{"east", "west"} {
+		m := &member{Cluster: Cluster{Name: name, Resources: []RN{{RType: Pods}}}}
+		m.indexers = append(m.indexers, m.newIndexer())
+		m.indexers[0].Add(newViewPod("default", "web", map[string]string{"app": "web"}))
+		m.indexers[0].Add(newViewPod("default", "db", map[string]string{"app": "db"}))
+		m.indexers[0].Add(newViewPod("kube-system", "dns", map[string]string{"app": "web"}))
+		c.clusters = append(c.clusters, m)
+	}
+
+	if _, err := c.View(ViewFilter{Clusters: []string{"north"}}); err == nil {
+		t.Errorf("expected an error of an unknown cluster")
+	}
+	if _, err := c.View(ViewFilter{Selector: "a b"}); err == nil {
+		t.Errorf("expected an error of an invalid selector")
+	}
+
+	v, err := c.View(ViewFilter{Clusters: []string{"east"}, Namespaces: []string{"default"}, Selector: "app=web"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := []string{"default/web"}, v.ListKeys(Pods); len(a) != 1 || a[0] != e[0] {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if items, ok := v.GetByKey(Pods, "default/web"); !ok || len(items) != 1 {
+		t.Errorf("expected the object of east, got %v", items)
+	}
+	if _, ok := v.GetByKey(Pods, "default/db"); ok {
+		t.Errorf("expected no object not selected")
+	}
+
+	emit := func(cluster int, pod *v1.Pod) {
+		c.emit(c.clusters[cluster], RN{RType: Pods})(QueueObject{Event: EventUpdate, RType: Pods, Key: pod.Namespace + "/" + pod.Name}, pod)
+	}
+	emit(1, newViewPod("default", "web", map[string]string{"app": "web"}))
+	emit(0, newViewPod("default", "db", map[string]string{"app": "db"}))
+	emit(0, newViewPod("kube-system", "dns", map[string]string{"app": "web"}))
+	emit(0, newViewPod("default", "web", map[string]string{"app": "web"}))
+	if e, a := 4, len(sent); e != a {
+		t.Errorf("expected all events in the queue of the robot, got %v", a)
+	}
+
+	item, err := v.Pop()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	v.Finish(item)
+	if item.Cluster != "east" || item.Key != "default/web" {
+		t.Errorf("expected the event of default/web of east, got %v", item)
+	}
+
+	v.Close()
+	v.Close()
+	if _, err := v.Pop(); err == nil {
+		t.Errorf("expected the queue closed")
+	}
+	emit(0, newViewPod("default", "web", map[string]string{"app": "web"}))
+}
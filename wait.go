@@ -0,0 +1,182 @@
+package robot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Predicate reports whether a cached object is as expected, see WaitForObject.
+type Predicate func(obj interface{}) bool
+
+// ConditionTrue returns a Predicate of objects whose condition of the type in
+// status.conditions is True, e.g. ConditionTrue("Available") of Deployments.
+func ConditionTrue(conditionType string) Predicate {
+	return func(obj interface{}) bool {
+		var content map[string]interface{}
+		switch o := obj.(type) {
+		case *unstructured.Unstructured:
+			content = o.Object
+		case runtime.Object:
+			var err error
+			if content, err = runtime.DefaultUnstructuredConverter.ToUnstructured(o); err != nil {
+				return false
+			}
+		default:
+			return false
+		}
+		conditions, _, _ := unstructured.NestedSlice(content, "status", "conditions")
+		for _, one := range conditions {
+			condition, ok := one.(map[string]interface{})
+			if ok && condition["type"] == conditionType && condition["status"] == "True" {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+type waitKey struct {
+	cluster  string
+	resource Resource
+	key      string
+}
+
+// storeWaiters are waiting for changes of objects in the store.
+type storeWaiters struct {
+	mu      sync.Mutex
+	waiters map[waitKey][]chan struct{}
+}
+
+func newStoreWaiters() *storeWaiters {
+	return &storeWaiters{waiters: make(map[waitKey][]chan struct{})}
+}
+
+// wait returns a channel closed once the object changes.
+func (w *storeWaiters) wait(cluster string, resource Resource, key string) <-chan struct{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	ch := make(chan struct{})
+	k := waitKey{cluster, resource, key}
+	w.waiters[k] = append(w.waiters[k], ch)
+	return ch
+}
+
+// cancel stops waiting by the channel of wait.
+func (w *storeWaiters) cancel(cluster string, resource Resource, key string, ch <-chan struct{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	k := waitKey{cluster, resource, key}
+	waiters := w.waiters[k]
+	for i, one := range waiters {
+		if one == ch {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) == 0 {
+		delete(w.waiters, k)
+	} else {
+		w.waiters[k] = waiters
+	}
+}
+
+// notify wakes up the waiters of the object of the event, a nil one has none.
+func (w *storeWaiters) notify(item QueueObject) {
+	if w == nil {
+		return
+	}
+	switch item.Event {
+	case EventAdd, EventUpdate, EventDelete:
+	default:
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	k := waitKey{item.Cluster, item.RType, item.Key}
+	for _, ch := range w.waiters[k] {
+		close(ch)
+	}
+	delete(w.waiters, k)
+}
+
+// waitPollInterval is how often objects waited are checked besides changes
+// of them, as changes filtered by handlers, e.g. RN.GenerationChanged, are
+// not notified.
+const waitPollInterval = time.Second
+
+// WaitForObject blocks until the object of the key of the resource is in the
+// store of the cluster, and the predicate is true of it if not nil, and then
+// returns the object. It returns an error if ctx is done before that, or the
+// resource is not watched in the cluster.
+func (c *controller) WaitForObject(ctx context.Context, cluster string, resource Resource, key string, predicate Predicate) (interface{}, error) {
+	c.mu.Lock()
+	m := c.memberOf(cluster)
+	c.mu.Unlock()
+	if m == nil {
+		return nil, &ErrClusterNotFound{cluster}
+	}
+	// Events are of the name of the cluster, which may be given by its URL.
+	cluster = m.String()
+
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+
+	for {
+		ch := c.waiters.wait(cluster, resource, key)
+		obj, ok, err := c.cachedObject(cluster, resource, key)
+		if err != nil || (ok && (predicate == nil || predicate(obj))) {
+			c.waiters.cancel(cluster, resource, key, ch)
+			return obj, err
+		}
+
+		select {
+		case <-ch:
+		case <-ticker.C:
+			c.waiters.cancel(cluster, resource, key, ch)
+		case <-ctx.Done():
+			c.waiters.cancel(cluster, resource, key, ch)
+			return nil, fmt.Errorf("robot: waiting for %s %s in cluster %s: %v", resource, key, cluster, ctx.Err())
+		case <-c.stop:
+			c.waiters.cancel(cluster, resource, key, ch)
+			return nil, fmt.Errorf("robot: waiting for %s %s in cluster %s: robot stopped", resource, key, cluster)
+		}
+	}
+}
+
+// cachedObject returns the object of the key of the resource in the store of the cluster.
+func (c *controller) cachedObject(cluster string, resource Resource, key string) (interface{}, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m := c.memberOf(cluster)
+	if m == nil {
+		return nil, false, &ErrClusterNotFound{cluster}
+	}
+	watched := false
+	for i, r := range m.Resources {
+		if r.RType != resource {
+			continue
+		}
+		watched = true
+		obj, ok, err := m.indexers[i].GetByKey(key)
+		if err != nil {
+			return nil, false, err
+		}
+		if ok {
+			return obj, true, nil
+		}
+	}
+	if !watched {
+		return nil, false, fmt.Errorf("robot: %s is not watched in cluster %s", resource, cluster)
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,84 @@
+package robot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWaitForObject(t *testing.T) {
+	robot, err := NewRobotWithOptions(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := robot.(*controller)
+	c.queue = &recordQueue{sent: &[]QueueObject{}}
+	m := &member{Cluster: Cluster{Name: "east", Resources: []RN{{RType: Pods}}}}
+	m.indexers = append(m.indexers, m.newIndexer())
+	c.clusters = []*member{m}
+	emit := c.emit(m, RN{RType: Pods})
+
+	running := func(pod interface{}) bool {
+		return pod.(*v1.Pod).Status.Phase == v1.PodRunning
+	}
+	done := make(chan interface{})
+	go func() {
+		obj, err := c.WaitForObject(context.Background(), "east", Pods, "default/web", running)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- obj
+	}()
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}, Status: v1.PodStatus{Phase: v1.PodPending}}
+	_ = m.indexers[0].Add(pod)
+	emit(QueueObject{Event: EventAdd, RType: Pods, Key: "default/web"}, pod)
+	select {
+	case <-done:
+		t.Fatal("expected waiting for the pod running")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	pod = pod.DeepCopy()
+	pod.Status.Phase = v1.PodRunning
+	_ = m.indexers[0].Update(pod)
+	emit(QueueObject{Event: EventUpdate, RType: Pods, Key: "default/web"}, pod)
+	select {
+	case obj := <-done:
+		if obj != pod {
+			t.Errorf("expected %v, got %v", pod, obj)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the pod")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.WaitForObject(ctx, "east", Pods, "default/other", nil); err == nil {
+		t.Error("expected an error")
+	}
+	if _, err := c.WaitForObject(context.Background(), "east", Services, "default/web", nil); err == nil {
+		t.Error("expected an error")
+	}
+	if e, a := 0, len(c.waiters.waiters); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestConditionTrue(t *testing.T) {
+	deployment := &appsv1.Deployment{Status: appsv1.DeploymentStatus{Conditions: []appsv1.DeploymentCondition{
+		{Type: appsv1.DeploymentProgressing, Status: v1.ConditionTrue},
+		{Type: appsv1.DeploymentAvailable, Status: v1.ConditionFalse},
+	}}}
+	if ConditionTrue("Available")(deployment) {
+		t.Error("expected not available")
+	}
+	deployment.Status.Conditions[1].Status = v1.ConditionTrue
+	if !ConditionTrue("Available")(deployment) {
+		t.Error("expected available")
+	}
+}
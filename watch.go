@@ -0,0 +1,98 @@
+package robot
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ObjectEvent is an event of an object watched by WatchObject.
+type ObjectEvent struct {
+	Event  event
+	Object interface{}
+}
+
+func (c *controller) WatchObject(ctx context.Context, cluster string, resource Resource, namespace, name string) (<-chan ObjectEvent, error) {
+	if resource == All {
+		return nil, fmt.Errorf("robot: can't watch object of %s", resource)
+	}
+
+	selector := fields.OneTermEqualSelector("metadata.name", name)
+
+	c.mu.Lock()
+	m := c.memberOf(cluster)
+	var lw cache.ListerWatcher
+	switch {
+	case m == nil:
+	case m.typed(resource):
+		lw = cache.NewListWatchFromClient(m.client.CoreV1().RESTClient(), resource.Resource, namespace, selector)
+	case m.dynamic != nil:
+		all := dynamicListWatch(m.dynamic, RN{RType: resource, Namespace: namespace})
+		lw = &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				options.FieldSelector = selector.String()
+				return all.List(options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				options.FieldSelector = selector.String()
+				return all.Watch(options)
+			},
+		}
+	}
+	var obj runtime.Object
+	if m != nil {
+		obj = m.object(resource)
+	}
+	c.mu.Unlock()
+	if m == nil {
+		return nil, &ErrClusterNotFound{cluster}
+	}
+	if lw == nil {
+		return nil, fmt.Errorf("robot: can't watch object of %s in cluster %s", resource, cluster)
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		defer close(stop)
+		select {
+		case <-ctx.Done():
+		case <-c.stop:
+		}
+	}()
+
+	out := make(chan ObjectEvent)
+	send := func(e event, obj interface{}) {
+		select {
+		case out <- ObjectEvent{e, obj}:
+		case <-stop:
+		}
+	}
+
+	_, informer := cache.NewInformer(lw, obj, 0, cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			send(EventAdd, obj)
+		},
+		UpdateFunc: func(old, new interface{}) {
+			if changed(resource, old, new) {
+				send(EventUpdate, new)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			send(EventDelete, obj)
+		},
+	})
+
+	go func() {
+		defer close(out)
+		defer handleCrash(c.report, "watch of %s %s/%s", resource, namespace, name)
+
+		informer.Run(stop)
+	}()
+
+	return out, nil
+}
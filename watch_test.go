@@ -0,0 +1,84 @@
+package robot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestWatchObject(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	watcher := watch.NewFake()
+	client.PrependWatchReactor("pods", k8stesting.DefaultWatchReactor(watcher, nil))
+
+	c := &controller{
+		stop: make(chan struct{}),
+		errs: make(chan error, 10),
+		clusters: []*member{{
+			Cluster:      Cluster{MasterUrl: "https://one.example.com"},
+			dynamic:      client,
+			unstructured: true,
+		}},
+	}
+	defer close(c.stop)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := c.WatchObject(ctx, "https://one.example.com", Pods, "default", "web")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pod := func(rv string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion("v1")
+		u.SetKind("Pod")
+		u.SetNamespace("default")
+		u.SetName("web")
+		u.SetResourceVersion(rv)
+		return u
+	}
+	next := func() ObjectEvent {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				t.Fatal("unexpected close of the events")
+			}
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for an event")
+		}
+		return ObjectEvent{}
+	}
+
+	// The fake watcher is unbuffered, so each event is sent once the informer watches.
+	watcher.Add(pod("1"))
+	if e, a := EventAdd, next().Event; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	watcher.Modify(pod("2"))
+	e := next()
+	if e.Event != EventUpdate || e.Object.(*unstructured.Unstructured).GetResourceVersion() != "2" {
+		t.Errorf("expected an update to version 2, got %v", e)
+	}
+	watcher.Delete(pod("3"))
+	if e, a := EventDelete, next().Event; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("expected no event after cancel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected the events closed once the context is canceled")
+	}
+}
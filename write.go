@@ -0,0 +1,148 @@
+package robot
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/retry"
+)
+
+const defaultFieldManager = "robot"
+
+// WriteOptions are options of Apply, Patch and Delete.
+type WriteOptions struct {
+	// DryRun sends the write to the api server without persisting it.
+	DryRun bool
+}
+
+func (o WriteOptions) dryRun() []string {
+	if o.DryRun {
+		return []string{metav1.DryRunAll}
+	}
+	return nil
+}
+
+func (c *controller) fieldManager() string {
+	if c.opts.FieldManager != "" {
+		return c.opts.FieldManager
+	}
+	return defaultFieldManager
+}
+
+// resourceClient returns the client of the resource in the namespace of the cluster.
+func (c *controller) resourceClient(cluster string, resource Resource, namespace string) (dynamic.ResourceInterface, error) {
+	if err := resource.Validate(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m := c.memberOf(cluster)
+	if m == nil {
+		return nil, &ErrClusterNotFound{cluster}
+	}
+	if m.dynamic == nil {
+		client, err := m.newDynamicClient()
+		if err != nil {
+			return nil, fmt.Errorf("robot: create dynamic client of cluster %s: %v", m, err)
+		}
+		m.dynamic = client
+	}
+	return m.dynamic.Resource(resource.GroupVersionResource()).Namespace(namespace), nil
+}
+
+func (c *controller) Apply(cluster string, resource Resource, obj runtime.Object, opts WriteOptions) (*unstructured.Unstructured, error) {
+	desired, err := toUnstructured(obj)
+	if err != nil {
+		return nil, fmt.Errorf("robot: apply %s in cluster %s: %v", resource, cluster, err)
+	}
+	client, err := c.resourceClient(cluster, resource, desired.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+
+	var out *unstructured.Unstructured
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := client.Get(desired.GetName(), metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			desired.SetResourceVersion("")
+			out, err = client.Create(desired, metav1.CreateOptions{DryRun: opts.dryRun(), FieldManager: c.fieldManager()})
+			if errors.IsAlreadyExists(err) {
+				// Created concurrently, update it on retry.
+				return errors.NewConflict(resource.GroupVersionResource().GroupResource(), desired.GetName(), err)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		desired.SetResourceVersion(current.GetResourceVersion())
+		out, err = client.Update(desired, metav1.UpdateOptions{DryRun: opts.dryRun(), FieldManager: c.fieldManager()})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("robot: apply %s %s/%s in cluster %s: %v", resource, desired.GetNamespace(), desired.GetName(), cluster, err)
+	}
+	return out, nil
+}
+
+func (c *controller) Patch(cluster string, resource Resource, namespace, name string, pt types.PatchType, data []byte, opts WriteOptions) (*unstructured.Unstructured, error) {
+	client, err := c.resourceClient(cluster, resource, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var out *unstructured.Unstructured
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() (err error) {
+		out, err = client.Patch(name, pt, data, metav1.PatchOptions{DryRun: opts.dryRun(), FieldManager: c.fieldManager()})
+		return
+	})
+	if err != nil {
+		return nil, fmt.Errorf("robot: patch %s %s/%s in cluster %s: %v", resource, namespace, name, cluster, err)
+	}
+	return out, nil
+}
+
+func (c *controller) Delete(cluster string, resource Resource, namespace, name string, opts WriteOptions) error {
+	client, err := c.resourceClient(cluster, resource, namespace)
+	if err != nil {
+		return err
+	}
+
+	err = client.Delete(name, &metav1.DeleteOptions{DryRun: opts.dryRun()})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("robot: delete %s %s/%s in cluster %s: %v", resource, namespace, name, cluster, err)
+	}
+	return nil
+}
+
+// toUnstructured converts obj to an unstructured object,
+// the apiVersion and kind of typed objects are filled if missing.
+func toUnstructured(obj runtime.Object) (*unstructured.Unstructured, error) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u.DeepCopy(), nil
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	if u.GetKind() == "" {
+		kinds, _, err := scheme.Scheme.ObjectKinds(obj)
+		if err != nil {
+			return nil, err
+		}
+		u.SetAPIVersion(kinds[0].GroupVersion().String())
+		u.SetKind(kinds[0].Kind)
+	}
+	return u, nil
+}
@@ -0,0 +1,55 @@
+package robot
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestWrite(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	c := &controller{clusters: []*member{{Cluster: Cluster{MasterUrl: "https://one.example.com"}, dynamic: client}}}
+	const cluster = "https://one.example.com"
+
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "one"},
+		Data:       map[string]string{"a": "1"},
+	}
+	out, err := c.Apply(cluster, ConfigMaps, cm, WriteOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := "ConfigMap", out.GetKind(); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	cm.Data["a"] = "2"
+	if _, err := c.Apply(cluster, ConfigMaps, cm, WriteOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out, err = c.Patch(cluster, ConfigMaps, "default", "one", types.MergePatchType, []byte(`{"data":{"b":"3"}}`), WriteOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _, _ := unstructured.NestedStringMap(out.Object, "data")
+	if e, a := map[string]string{"a": "2", "b": "3"}, data; len(a) != 2 || e["a"] != a["a"] || e["b"] != a["b"] {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if err := c.Delete(cluster, ConfigMaps, "default", "one", WriteOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := c.Delete(cluster, ConfigMaps, "default", "one", WriteOptions{}); err != nil {
+		t.Errorf("unexpected error of deleting again: %v", err)
+	}
+
+	if _, err := c.Apply("https://two.example.com", ConfigMaps, cm, WriteOptions{}); err == nil {
+		t.Errorf("expected error of unknown cluster")
+	}
+}
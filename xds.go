@@ -0,0 +1,338 @@
+package robot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Paths and type URLs of the REST-JSON xDS API of Envoy, version 3.
+const (
+	xdsClustersPath  = "/v3/discovery:clusters"
+	xdsEndpointsPath = "/v3/discovery:endpoints"
+
+	xdsClusterType    = "type.googleapis.com/envoy.config.cluster.v3.Cluster"
+	xdsAssignmentType = "type.googleapis.com/envoy.config.endpoint.v3.ClusterLoadAssignment"
+)
+
+// xdsRequest is the part of a DiscoveryRequest used.
+type xdsRequest struct {
+	VersionInfo   string   `json:"version_info"`
+	ResourceNames []string `json:"resource_names"`
+	TypeURL       string   `json:"type_url"`
+}
+
+type xdsResponse struct {
+	VersionInfo string        `json:"version_info"`
+	Resources   []interface{} `json:"resources"`
+	TypeURL     string        `json:"type_url"`
+}
+
+// xdsCluster is an Envoy cluster of a port of a Service, whose endpoints are
+// discovered by EDS from the same server.
+type xdsCluster struct {
+	Type             string `json:"@type"`
+	Name             string `json:"name"`
+	DiscoveryType    string `json:"type"`
+	ConnectTimeout   string `json:"connect_timeout"`
+	EDSClusterConfig struct {
+		EDSConfig struct {
+			ResourceAPIVersion string   `json:"resource_api_version"`
+			Self               struct{} `json:"self"`
+		} `json:"eds_config"`
+	} `json:"eds_cluster_config"`
+}
+
+// xdsAssignment is the endpoints of an Envoy cluster, in a locality of each
+// Kubernetes cluster.
+type xdsAssignment struct {
+	Type        string        `json:"@type"`
+	ClusterName string        `json:"cluster_name"`
+	Endpoints   []xdsLocality `json:"endpoints"`
+}
+
+type xdsLocality struct {
+	Locality struct {
+		Zone string `json:"zone"`
+	} `json:"locality"`
+	LBEndpoints []xdsEndpoint `json:"lb_endpoints"`
+}
+
+type xdsEndpoint struct {
+	Endpoint struct {
+		Address struct {
+			SocketAddress struct {
+				Address   string `json:"address"`
+				PortValue int32  `json:"port_value"`
+			} `json:"socket_address"`
+		} `json:"address"`
+	} `json:"endpoint"`
+	HealthStatus string `json:"health_status"`
+}
+
+// xdsService is what a Service is rendered into.
+type xdsService struct {
+	clusters    []xdsCluster
+	assignments []xdsAssignment
+}
+
+// xdsCache renders Envoy clusters and endpoints of Services of all clusters.
+// Events of Services and Endpoints invalidate the Services of their names,
+// which are rendered again on the next request, so a change re-renders only
+// the Service changed. A nil one invalidates nothing.
+type xdsCache struct {
+	mu       sync.Mutex
+	version  uint64
+	all      bool
+	dirty    map[string]bool
+	services map[string]xdsService
+}
+
+func newXDSCache() *xdsCache {
+	return &xdsCache{all: true, dirty: make(map[string]bool), services: make(map[string]xdsService)}
+}
+
+// invalidate invalidates the Service of obj, if it's a Service or Endpoints.
+func (x *xdsCache) invalidate(r Resource, obj interface{}) {
+	if x == nil || (r != Services && r != Endpoints) {
+		return
+	}
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.dirty[accessor.GetNamespace()+"/"+accessor.GetName()] = true
+}
+
+// refresh renders the Services invalidated, and returns the version of
+// the rendered ones. c.mu must be held.
+func (x *xdsCache) refresh(clusters []*member) string {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.all {
+		x.all = false
+		x.services = make(map[string]xdsService)
+		for _, m := range clusters {
+			for i, r := range m.Resources {
+				if r.RType != Services {
+					continue
+				}
+				for _, obj := range m.indexers[i].List() {
+					if accessor, err := meta.Accessor(obj); err == nil {
+						x.dirty[accessor.GetNamespace()+"/"+accessor.GetName()] = true
+					}
+				}
+			}
+		}
+	}
+
+	if len(x.dirty) > 0 {
+		for key := range x.dirty {
+			namespace, name, _ := cache.SplitMetaNamespaceKey(key)
+			if service, ok := renderXDS(clusters, namespace, name); ok {
+				x.services[key] = service
+			} else {
+				delete(x.services, key)
+			}
+		}
+		x.dirty = make(map[string]bool)
+		x.version++
+	}
+	return strconv.FormatUint(x.version, 10)
+}
+
+// renderXDS renders the Service of the namespace and name of all clusters into
+// a cluster of each TCP port, false if it's in no cluster.
+func renderXDS(clusters []*member, namespace, name string) (xdsService, bool) {
+	found := false
+	assignments := make(map[string]*xdsAssignment)
+	var names []string
+	for _, m := range clusters {
+		service, ok := m.cachedService(namespace, name)
+		if !ok {
+			continue
+		}
+		found = true
+
+		endpoints := m.cachedEndpoints(namespace, name)
+		for _, port := range service.Spec.Ports {
+			if port.Protocol != "" && port.Protocol != v1.ProtocolTCP {
+				continue
+			}
+			clusterName := fmt.Sprintf("%s.%s:%d", name, namespace, port.Port)
+			assignment, ok := assignments[clusterName]
+			if !ok {
+				assignment = &xdsAssignment{Type: xdsAssignmentType, ClusterName: clusterName, Endpoints: []xdsLocality{}}
+				assignments[clusterName] = assignment
+				names = append(names, clusterName)
+			}
+			if endpoints == nil {
+				continue
+			}
+
+			var locality xdsLocality
+			locality.Locality.Zone = m.String()
+			for _, subset := range endpoints.Subsets {
+				for _, endpointPort := range subset.Ports {
+					// Ports of Endpoints are named by ports of the Service,
+					// the only port may be unnamed.
+					if endpointPort.Name != port.Name {
+						continue
+					}
+					for _, addresses := range []struct {
+						addresses []v1.EndpointAddress
+						status    string
+					}{{subset.Addresses, "HEALTHY"}, {subset.NotReadyAddresses, "UNHEALTHY"}} {
+						for _, address := range addresses.addresses {
+							var endpoint xdsEndpoint
+							endpoint.Endpoint.Address.SocketAddress.Address = address.IP
+							endpoint.Endpoint.Address.SocketAddress.PortValue = endpointPort.Port
+							endpoint.HealthStatus = addresses.status
+							locality.LBEndpoints = append(locality.LBEndpoints, endpoint)
+						}
+					}
+				}
+			}
+			if len(locality.LBEndpoints) > 0 {
+				assignment.Endpoints = append(assignment.Endpoints, locality)
+			}
+		}
+	}
+	if !found {
+		return xdsService{}, false
+	}
+
+	sort.Strings(names)
+	var service xdsService
+	for _, clusterName := range names {
+		cluster := xdsCluster{Type: xdsClusterType, Name: clusterName, DiscoveryType: "EDS", ConnectTimeout: "5s"}
+		cluster.EDSClusterConfig.EDSConfig.ResourceAPIVersion = "V3"
+		service.clusters = append(service.clusters, cluster)
+		service.assignments = append(service.assignments, *assignments[clusterName])
+	}
+	return service, true
+}
+
+// cachedService returns the cached Service of the namespace and name.
+func (m *member) cachedService(namespace, name string) (*v1.Service, bool) {
+	for i, r := range m.Resources {
+		if r.RType != Services {
+			continue
+		}
+		obj, exists, err := getByName(m.indexers[i], namespace, name)
+		if err != nil || !exists {
+			continue
+		}
+		if service, ok := serviceOf(obj); ok {
+			return service, true
+		}
+	}
+	return nil, false
+}
+
+// cachedEndpoints returns the cached Endpoints of the namespace and name, nil if none.
+func (m *member) cachedEndpoints(namespace, name string) *v1.Endpoints {
+	for i, r := range m.Resources {
+		if r.RType != Endpoints {
+			continue
+		}
+		obj, exists, err := getByName(m.indexers[i], namespace, name)
+		if err != nil || !exists {
+			continue
+		}
+		if endpoints, ok := endpointsOf(obj); ok {
+			return endpoints
+		}
+	}
+	return nil
+}
+
+// runXDS runs the xDS server on opts.XDSAddr until the robot stops.
+func (c *controller) runXDS() {
+	if c.opts.XDSAddr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(xdsClustersPath, c.serveXDS(xdsClusterType))
+	mux.HandleFunc(xdsEndpointsPath, c.serveXDS(xdsAssignmentType))
+	c.listenAndServe("xds", c.opts.XDSAddr, mux)
+}
+
+// serveXDS serves DiscoveryRequests of the type by the REST-JSON protocol,
+// requests of the current version get 304 Not Modified.
+func (c *controller) serveXDS(typeURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var request xdsRequest
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		c.mu.Lock()
+		version := c.xds.refresh(c.clusters)
+		c.mu.Unlock()
+		if request.VersionInfo == version {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		response := xdsResponse{VersionInfo: version, Resources: c.xds.resources(typeURL, request.ResourceNames), TypeURL: typeURL}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			c.report(fmt.Errorf("robot: write xds response: %v", err))
+		}
+	}
+}
+
+// resources returns the rendered resources of the type, of the names if any,
+// sorted by names.
+func (x *xdsCache) resources(typeURL string, names []string) []interface{} {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	keys := make([]string, 0, len(x.services))
+	for key := range x.services {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	resources := []interface{}{}
+	for _, key := range keys {
+		service := x.services[key]
+		for i, cluster := range service.clusters {
+			if len(wanted) > 0 && !wanted[cluster.Name] {
+				continue
+			}
+			if typeURL == xdsClusterType {
+				resources = append(resources, cluster)
+			} else {
+				resources = append(resources, service.assignments[i])
+			}
+		}
+	}
+	return resources
+}
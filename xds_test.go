@@ -0,0 +1,97 @@
+package robot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// discover posts a DiscoveryRequest to the handler.
+func discover(t *testing.T, h http.HandlerFunc, request string) (int, xdsResponse, string) {
+	recorder := httptest.NewRecorder()
+	h(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(request)))
+
+	var response xdsResponse
+	if recorder.Code == http.StatusOK {
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return recorder.Code, response, recorder.Body.String()
+}
+
+func TestXDS(t *testing.T) {
+	c := &controller{xds: newXDSCache()}
+	for _, name := range []string{"east", "west"} {
+		m := &member{Cluster: Cluster{Name: name, Resources: []RN{{RType: Services}, {RType: Endpoints}}}}
+		for range m.Resources {
+			m.indexers = append(m.indexers, m.newIndexer())
+		}
+		c.clusters = append(c.clusters, m)
+	}
+	east, west := c.clusters[0], c.clusters[1]
+
+	web := metav1.ObjectMeta{Namespace: "default", Name: "web"}
+	service := &v1.Service{ObjectMeta: web, Spec: v1.ServiceSpec{Ports: []v1.ServicePort{{Name: "http", Port: 80}, {Name: "dns", Port: 53, Protocol: v1.ProtocolUDP}}}}
+	_ = east.indexers[0].Add(service)
+	_ = west.indexers[0].Add(service)
+	_ = east.indexers[1].Add(&v1.Endpoints{ObjectMeta: web, Subsets: []v1.EndpointSubset{{
+		Addresses:         []v1.EndpointAddress{{IP: "10.0.0.1"}},
+		NotReadyAddresses: []v1.EndpointAddress{{IP: "10.0.0.2"}},
+		Ports:             []v1.EndpointPort{{Name: "http", Port: 8080}, {Name: "dns", Port: 53, Protocol: v1.ProtocolUDP}},
+	}}})
+
+	code, response, _ := discover(t, c.serveXDS(xdsClusterType), `{"type_url": "`+xdsClusterType+`"}`)
+	if e, a := http.StatusOK, code; e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	if e, a := 1, len(response.Resources); e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	if e, a := "web.default:80", response.Resources[0].(map[string]interface{})["name"]; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	version := response.VersionInfo
+
+	code, _, _ = discover(t, c.serveXDS(xdsClusterType), `{"version_info": "`+version+`"}`)
+	if e, a := http.StatusNotModified, code; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	endpoints := &v1.Endpoints{ObjectMeta: web, Subsets: []v1.EndpointSubset{{
+		Addresses: []v1.EndpointAddress{{IP: "10.1.0.1"}},
+		Ports:     []v1.EndpointPort{{Name: "http", Port: 8080}},
+	}}}
+	_ = west.indexers[1].Add(endpoints)
+	c.xds.invalidate(Endpoints, endpoints)
+
+	code, response, body := discover(t, c.serveXDS(xdsAssignmentType), `{"version_info": "`+version+`", "resource_names": ["web.default:80"]}`)
+	if e, a := http.StatusOK, code; e != a {
+		t.Fatalf("expected %v, got %v", e, a)
+	}
+	if response.VersionInfo == version {
+		t.Errorf("expected a new version than %s", version)
+	}
+	for _, s := range []string{
+		`"cluster_name":"web.default:80"`,
+		`{"locality":{"zone":"east"},"lb_endpoints":[{"endpoint":{"address":{"socket_address":{"address":"10.0.0.1","port_value":8080}}},"health_status":"HEALTHY"},{"endpoint":{"address":{"socket_address":{"address":"10.0.0.2","port_value":8080}}},"health_status":"UNHEALTHY"}]}`,
+		`{"locality":{"zone":"west"},"lb_endpoints":[{"endpoint":{"address":{"socket_address":{"address":"10.1.0.1","port_value":8080}}},"health_status":"HEALTHY"}]}`,
+	} {
+		if !strings.Contains(body, s) {
+			t.Errorf("expected %s in %s", s, body)
+		}
+	}
+
+	_ = east.indexers[0].Delete(service)
+	_ = west.indexers[0].Delete(service)
+	c.xds.invalidate(Services, service)
+	_, response, _ = discover(t, c.serveXDS(xdsClusterType), `{}`)
+	if e, a := 0, len(response.Resources); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}